@@ -0,0 +1,96 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Principal is the identity behind a request along with its roles or scopes
+type Principal struct {
+	ID    string
+	Roles []string
+}
+
+// HasRole tells whether the principal holds one of the roles
+func (p *Principal) HasRole(roles ...string) bool {
+	for _, held := range p.Roles {
+		for _, role := range roles {
+			if held == role {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// PrincipalFunc identifies the principal of a request, returning nil or an error when anonymous
+type PrincipalFunc func(r *http.Request) (*Principal, error)
+
+// JWTPrincipal identifies principals by the subject of their JSON Web Token, their roles
+// being read from roleClaim (e.g. "roles" or "scope")
+func JWTPrincipal(auth *JWTAuth, roleClaim string) PrincipalFunc {
+	return func(r *http.Request) (*Principal, error) {
+		claims, err := auth.Claims(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Principal{ID: claims.Subject(), Roles: claims.Strings(roleClaim)}, nil
+	}
+}
+
+// accessError is the JSON body of requests denied by the access control
+type accessError struct {
+	Error string   `json:"error"`
+	Alias string   `json:"alias"`
+	Roles []string `json:"required_roles"`
+}
+
+// authorize checks that the principal of a request holds one of the roles of the resource,
+// writing the error response and returning false otherwise
+func (c *ResourceCacher) authorize(w http.ResponseWriter, r *http.Request, res *Resource) bool {
+	status, reason := c.accessDenied(r, res)
+	if status == 0 {
+		return true
+	}
+
+	if c.opts.ErrorHandler != nil {
+		c.opts.ErrorHandler(w, status)
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(accessError{Error: reason, Alias: res.Alias, Roles: res.Roles})
+
+	return false
+}
+
+// accessDenied returns the status and reason denying a request access to the resource, 0 when granted
+func (c *ResourceCacher) accessDenied(r *http.Request, res *Resource) (int, string) {
+	if len(res.Roles) == 0 {
+		return 0, ""
+	}
+
+	var principal *Principal
+	if c.opts.PrincipalFunc != nil {
+		p, err := c.opts.PrincipalFunc(r)
+		if err != nil {
+			c.opts.Logger.Debug("anonymous request", F("alias", res.Alias), F("error", err))
+		}
+		principal = p
+	}
+
+	if principal == nil {
+		return http.StatusUnauthorized, "unauthenticated"
+	}
+
+	if principal.HasRole(res.Roles...) {
+		return 0, ""
+	}
+
+	c.opts.Logger.Debug("access denied", F("alias", res.Alias), F("principal", principal.ID))
+
+	return http.StatusForbidden, "forbidden"
+}
@@ -0,0 +1,207 @@
+package routing
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// adminResource is the admin API view of a resource, without its secrets
+type adminResource struct {
+	Resource resourceConfig `json:"resource"`
+	Status   ResourceStatus `json:"status"`
+}
+
+// adminView returns the admin API view of a resource, upstream credentials and API keys
+// being left out
+func (c *ResourceCacher) adminView(res *Resource) adminResource {
+	cfg := res.config().redacted()
+	cfg.Auth = nil
+
+	return adminResource{cfg, c.resourceStatus(res)}
+}
+
+// AdminHandler returns a JSON API to manage resources at runtime, to be mounted with
+// http.StripPrefix so that it sees the following paths:
+//
+//	GET    /resources                 lists resources with their status
+//	POST   /resources                 adds a resource from its JSON definition
+//	GET    /resources/{alias}         returns a resource with its status
+//	PUT    /resources/{alias}         replaces a resource with a new JSON definition
+//	DELETE /resources/{alias}         removes a resource
+//	POST   /resources/{alias}/pause   stops fetching a resource
+//	POST   /resources/{alias}/resume  restarts fetching a resource
+//	POST   /resources/{alias}/refresh fetches a resource immediately
+//
+// Requests must carry the token as a bearer token. An empty token disables the API, all
+// requests being refused.
+func (c *ResourceCacher) AdminHandler(token string) http.Handler {
+	if token == "" {
+		c.opts.Logger.Warn("admin API disabled, no token given")
+	}
+
+	api := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if segments[0] != "resources" || len(segments) > 3 {
+			writeAdminError(w, http.StatusNotFound, errors.New("not found"))
+			return
+		}
+
+		switch len(segments) {
+		case 1:
+			c.adminResources(w, r)
+		case 2:
+			c.adminResource(w, r, segments[1])
+		default:
+			c.adminAction(w, r, segments[1], segments[2])
+		}
+	})
+
+	return requireToken(token, api)
+}
+
+// requireToken only lets through the requests carrying token as a bearer token, refusing
+// all of them when token is empty
+func requireToken(token string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			writeAdminError(w, http.StatusForbidden, errors.New("admin API disabled"))
+			return
+		}
+
+		authorization := r.Header.Get("Authorization")
+		provided := strings.TrimPrefix(authorization, "Bearer ")
+		if provided == authorization || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			writeAdminError(w, http.StatusUnauthorized, errors.New("invalid token"))
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// adminToken returns the token of the admin API mounted by RegisterResourceCacher
+func (c *ResourceCacher) adminToken() string {
+	return c.opts.AdminToken
+}
+
+func (c *ResourceCacher) adminResources(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		resources := c.ListResources()
+		views := make([]adminResource, len(resources))
+		for i, res := range resources {
+			views[i] = c.adminView(res)
+		}
+
+		writeAdminJSON(w, http.StatusOK, views)
+	case http.MethodPost:
+		res := &Resource{}
+		if err := json.NewDecoder(r.Body).Decode(res); err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if _, err := c.AddResource(res, nil); err != nil {
+			writeAdminError(w, adminErrorStatus(err), err)
+			return
+		}
+
+		writeAdminJSON(w, http.StatusCreated, c.adminView(res))
+	default:
+		writeAdminError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+func (c *ResourceCacher) adminResource(w http.ResponseWriter, r *http.Request, alias string) {
+	current, ok := c.GetResource(alias)
+	if !ok {
+		writeAdminError(w, http.StatusNotFound, ErrResourceNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeAdminJSON(w, http.StatusOK, c.adminView(current))
+	case http.MethodPut:
+		res := &Resource{}
+		if err := json.NewDecoder(r.Body).Decode(res); err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+		res.Alias = alias
+
+		if _, err := c.ReplaceResource(res, nil); err != nil {
+			writeAdminError(w, adminErrorStatus(err), err)
+			return
+		}
+
+		writeAdminJSON(w, http.StatusOK, c.adminView(res))
+	case http.MethodDelete:
+		if _, err := c.RemoveResource(alias); err != nil {
+			writeAdminError(w, adminErrorStatus(err), err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeAdminError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+func (c *ResourceCacher) adminAction(w http.ResponseWriter, r *http.Request, alias, action string) {
+	if r.Method != http.MethodPost {
+		writeAdminError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	res, ok := c.GetResource(alias)
+	if !ok {
+		writeAdminError(w, http.StatusNotFound, ErrResourceNotFound)
+		return
+	}
+
+	switch action {
+	case "pause":
+		res.StopFetcher()
+	case "resume":
+		if _, err := c.ResumeResource(alias); err != nil {
+			writeAdminError(w, adminErrorStatus(err), err)
+			return
+		}
+	case "refresh":
+		if err := c.ForceRefreshContext(r.Context(), alias); err != nil {
+			writeAdminError(w, http.StatusBadGateway, err)
+			return
+		}
+	default:
+		writeAdminError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, c.adminView(res))
+}
+
+func adminErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrResourceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrDuplicateResource):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAdminError(w http.ResponseWriter, status int, err error) {
+	writeAdminJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,145 @@
+package routing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestAdminHandler(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"path": "` + r.URL.Path + `"}`))
+	}))
+	defer srv.Close()
+
+	var removed int32
+	c := routing.NewResourceCacher()
+	c.OnResourceRemoved = func(res *routing.Resource) {
+		atomic.AddInt32(&removed, 1)
+	}
+	defer c.Stop()
+
+	h := http.StripPrefix("/admin", c.AdminHandler("t0ken"))
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		token    string
+		expected int
+	}{
+		{"unauthorized", http.MethodGet, "/admin/resources", "", "wrong", http.StatusUnauthorized},
+		{"add", http.MethodPost, "/admin/resources", `{"alias": "first", "method": "GET", "url": "` + srv.URL + `/first", "interval": "1h"}`, "t0ken", http.StatusCreated},
+		{"add duplicate", http.MethodPost, "/admin/resources", `{"alias": "first", "method": "GET", "url": "` + srv.URL + `/first", "interval": "1h"}`, "t0ken", http.StatusConflict},
+		{"add invalid", http.MethodPost, "/admin/resources", `{"alias": "second", "method": "GET"}`, "t0ken", http.StatusBadRequest},
+		{"list", http.MethodGet, "/admin/resources", "", "t0ken", http.StatusOK},
+		{"get", http.MethodGet, "/admin/resources/first", "", "t0ken", http.StatusOK},
+		{"get unknown", http.MethodGet, "/admin/resources/unknown", "", "t0ken", http.StatusNotFound},
+		{"update invalid", http.MethodPut, "/admin/resources/first", `{"method": "GET"}`, "t0ken", http.StatusBadRequest},
+		{"update", http.MethodPut, "/admin/resources/first", `{"method": "GET", "url": "` + srv.URL + `/updated", "interval": "1h"}`, "t0ken", http.StatusOK},
+		{"pause", http.MethodPost, "/admin/resources/first/pause", "", "t0ken", http.StatusOK},
+		{"resume", http.MethodPost, "/admin/resources/first/resume", "", "t0ken", http.StatusOK},
+		{"refresh", http.MethodPost, "/admin/resources/first/refresh", "", "t0ken", http.StatusOK},
+		{"unknown action", http.MethodPost, "/admin/resources/first/explode", "", "t0ken", http.StatusNotFound},
+		{"delete", http.MethodDelete, "/admin/resources/first", "", "t0ken", http.StatusNoContent},
+		{"delete unknown", http.MethodDelete, "/admin/resources/first", "", "t0ken", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+		req.Header.Set("Authorization", "Bearer "+test.token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Code != test.expected {
+			t.Errorf("<response> %s status code not equal. expected %v obtained %v (%s)\n", test.name, test.expected, w.Code, w.Body)
+		}
+
+		if test.name == "update invalid" {
+			if _, ok := c.GetResource("first"); !ok {
+				t.Errorf("<admin> resource lost by an invalid update\n")
+			}
+		}
+
+		if test.name == "update" {
+			// Replaced in place, its clients staying connected
+			if n := atomic.LoadInt32(&removed); n != 0 {
+				t.Errorf("<admin> removed events not equal. expected %v obtained %v\n", 0, n)
+			}
+
+			res, _ := c.GetResource("first")
+			if content := string(res.Snapshot().Content); content != `{"path": "/updated"}` {
+				t.Errorf("<admin> content not equal. expected %v obtained %v\n", `{"path": "/updated"}`, content)
+			}
+		}
+
+		if test.name == "pause" {
+			res, _ := c.GetResource("first")
+			if res.Running() {
+				t.Errorf("<admin> expected resource to be paused\n")
+			}
+		}
+	}
+
+	if len(c.ListResources()) != 0 {
+		t.Errorf("<admin> expected no resources left\n")
+	}
+
+	// The token is only accepted as a bearer token
+	req := httptest.NewRequest(http.MethodGet, "/admin/resources", nil)
+	req.Header.Set("Authorization", "t0ken")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("<response> status code without bearer scheme not equal. expected %v obtained %v\n", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestAdminHandlerSecrets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher()
+	defer c.Stop()
+
+	if _, err := c.AddResource(&routing.Resource{
+		Alias:    "private",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Hour,
+		Auth:     &routing.UpstreamAuth{Username: "user", Password: "pass", BearerToken: "token"},
+		APIKeys:  []string{"client-key"},
+	}, nil); err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/resources/private", nil)
+	req.Header.Set("Authorization", "Bearer t0ken")
+	w := httptest.NewRecorder()
+	c.AdminHandler("t0ken").ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("<response> status code not equal. expected %v obtained %v\n", http.StatusOK, w.Code)
+	}
+
+	for _, secret := range []string{"user", "pass", "token", "client-key"} {
+		if strings.Contains(w.Body.String(), `"`+secret) {
+			t.Errorf("<admin> response leaks %q: %s\n", secret, w.Body)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	c.AdminHandler("").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/resources", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("<response> status code not equal. expected %v obtained %v\n", http.StatusForbidden, w.Code)
+	}
+}
@@ -0,0 +1,111 @@
+package routing
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrUnauthenticated is returned by an Authenticator when the request carries no valid credentials
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrForbidden is returned by an Authenticator when the credentials do not grant access to the resource
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Authenticator controls the access to resources, answering 403 on ErrForbidden and 401 on other errors
+type Authenticator interface {
+	Authenticate(r *http.Request, res *Resource) error
+}
+
+// AuthenticatorFunc is a function implementing Authenticator
+type AuthenticatorFunc func(r *http.Request, res *Resource) error
+
+// Authenticate calls f(r, res)
+func (f AuthenticatorFunc) Authenticate(r *http.Request, res *Resource) error {
+	return f(r, res)
+}
+
+// APIKeyHeader is the default header carrying API keys
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuth accepts requests carrying one of the API keys in a header or query parameter,
+// along with the APIKeys of the requested resource
+type APIKeyAuth struct {
+	// Keys granting access to all resources
+	Keys []string
+	// Header carrying the key (defaults to X-API-Key)
+	Header string
+	// Query parameter carrying the key when the header is missing (empty = disabled)
+	Query string
+}
+
+// Authenticate implements Authenticator
+func (a *APIKeyAuth) Authenticate(r *http.Request, res *Resource) error {
+	header := a.Header
+	if header == "" {
+		header = APIKeyHeader
+	}
+
+	key := r.Header.Get(header)
+	if key == "" && a.Query != "" {
+		key = r.URL.Query().Get(a.Query)
+	}
+
+	if key == "" {
+		return ErrUnauthenticated
+	}
+
+	if matchKey(key, a.Keys) || matchKey(key, res.APIKeys) {
+		return nil
+	}
+
+	return ErrUnauthenticated
+}
+
+// matchKey compares a key to the allowed ones in constant time
+func matchKey(key string, keys []string) bool {
+	found := false
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+			found = true
+		}
+	}
+
+	return found
+}
+
+// authenticate checks the credentials of a request using the resource Authenticator, its APIKeys,
+// or Options.Authenticator, in that order
+func (c *ResourceCacher) authenticate(r *http.Request, res *Resource) error {
+	auth := res.Authenticator
+	if auth == nil && len(res.APIKeys) > 0 {
+		// Keep the header and query parameter of a global APIKeyAuth
+		if global, ok := c.opts.Authenticator.(*APIKeyAuth); ok {
+			auth = global
+		} else {
+			auth = &APIKeyAuth{}
+		}
+	}
+	if auth == nil {
+		auth = c.opts.Authenticator
+	}
+
+	if auth == nil {
+		return nil
+	}
+
+	return auth.Authenticate(r, res)
+}
+
+// rejectUnauthenticated answers a request denied by an Authenticator
+func (c *ResourceCacher) rejectUnauthenticated(w http.ResponseWriter, res *Resource, err error) {
+	c.opts.Logger.Debug("access denied", F("alias", res.Alias), F("error", err))
+
+	status := http.StatusUnauthorized
+	if errors.Is(err, ErrForbidden) {
+		status = http.StatusForbidden
+	}
+
+	c.writeError(w, status, http.StatusText(status))
+}
@@ -0,0 +1,200 @@
+package routing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(routing.WithAuthenticator(&routing.APIKeyAuth{Keys: []string{"global"}, Query: "api_key"}))
+	for _, res := range []*routing.Resource{
+		{Alias: "shared", Method: http.MethodGet, URL: srv.URL, Interval: time.Hour},
+		{Alias: "keyed", Method: http.MethodGet, URL: srv.URL, Interval: time.Hour, APIKeys: []string{"private"}},
+		{
+			Alias:    "custom",
+			Method:   http.MethodGet,
+			URL:      srv.URL,
+			Interval: time.Hour,
+			Authenticator: routing.AuthenticatorFunc(func(r *http.Request, res *routing.Resource) error {
+				switch r.Header.Get("Authorization") {
+				case "":
+					return routing.ErrUnauthenticated
+				case "Bearer admin":
+					return nil
+				default:
+					return routing.ErrForbidden
+				}
+			}),
+		},
+	} {
+		if _, err := c.AddResource(res, nil); err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		target     string
+		header     http.Header
+		statusCode int
+	}{
+		{name: "missing key", target: "/resources/shared", statusCode: http.StatusUnauthorized},
+		{name: "wrong key", target: "/resources/shared", header: http.Header{"X-Api-Key": {"wrong"}}, statusCode: http.StatusUnauthorized},
+		{name: "global header", target: "/resources/shared", header: http.Header{"X-Api-Key": {"global"}}, statusCode: http.StatusOK},
+		{name: "global query", target: "/resources/shared?api_key=global", statusCode: http.StatusOK},
+		{name: "resource key", target: "/resources/keyed?api_key=private", statusCode: http.StatusOK},
+		{name: "resource key elsewhere", target: "/resources/shared?api_key=private", statusCode: http.StatusUnauthorized},
+		{name: "custom missing", target: "/resources/custom", statusCode: http.StatusUnauthorized},
+		{name: "custom forbidden", target: "/resources/custom", header: http.Header{"Authorization": {"Bearer user"}}, statusCode: http.StatusForbidden},
+		{name: "custom allowed", target: "/resources/custom", header: http.Header{"Authorization": {"Bearer admin"}}, statusCode: http.StatusOK},
+		{
+			name:       "preflight",
+			target:     "/resources/shared",
+			header:     http.Header{"Origin": {"https://app.example.com"}, "Access-Control-Request-Method": {"GET"}},
+			statusCode: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := http.MethodGet
+			if tt.header.Get("Access-Control-Request-Method") != "" {
+				method = http.MethodOptions
+			}
+
+			req := httptest.NewRequest(method, tt.target, nil)
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			c.ServeHTTP(w, req)
+
+			if w.Code != tt.statusCode {
+				t.Errorf("<response> statusCode not equal. expected %v obtained %v\n", tt.statusCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestSignedURLs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	signer := &routing.URLSigner{Secret: []byte("s3cret")}
+
+	c := routing.NewResourceCacher(routing.WithAuthenticator(signer))
+	for _, alias := range []string{"video1", "video2"} {
+		if _, err := c.AddResource(&routing.Resource{
+			Alias:    alias,
+			Method:   http.MethodGet,
+			URL:      srv.URL,
+			Interval: time.Hour,
+		}, nil); err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+	}
+
+	sign := func(rawURL, alias string, expires time.Time) string {
+		signed, err := signer.Sign(rawURL, alias, expires)
+		if err != nil {
+			t.Fatalf("sign error: %s", err)
+		}
+		return signed
+	}
+
+	valid := sign("/resources/video1", "video1", time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name       string
+		target     string
+		statusCode int
+	}{
+		{name: "unsigned", target: "/resources/video1", statusCode: http.StatusUnauthorized},
+		{name: "signed", target: valid, statusCode: http.StatusOK},
+		{name: "signed query alias", target: sign("/resources/?alias=video1", "video1", time.Now().Add(time.Hour)), statusCode: http.StatusOK},
+		{name: "expired", target: sign("/resources/video1", "video1", time.Now().Add(-time.Minute)), statusCode: http.StatusUnauthorized},
+		{name: "other alias", target: sign("/resources/video2", "video1", time.Now().Add(time.Hour)), statusCode: http.StatusUnauthorized},
+		{name: "tampered expiry", target: strings.Replace(valid, "expires=", "expires=9", 1), statusCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if w.Code != tt.statusCode {
+				t.Errorf("<response> statusCode not equal. expected %v obtained %v\n", tt.statusCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestRoles(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(routing.WithPrincipalFunc(func(r *http.Request) (*routing.Principal, error) {
+		user := r.Header.Get("X-User")
+		if user == "" {
+			return nil, nil
+		}
+		return &routing.Principal{ID: user, Roles: strings.Split(r.Header.Get("X-Roles"), ",")}, nil
+	}))
+
+	for _, res := range []*routing.Resource{
+		{Alias: "open", Method: http.MethodGet, URL: srv.URL, Interval: time.Hour},
+		{Alias: "reports", Method: http.MethodGet, URL: srv.URL, Interval: time.Hour, Roles: []string{"finance", "admin"}},
+	} {
+		if _, err := c.AddResource(res, nil); err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		alias      string
+		user       string
+		roles      string
+		statusCode int
+		body       string
+	}{
+		{name: "open", alias: "open", statusCode: http.StatusOK},
+		{name: "anonymous", alias: "reports", statusCode: http.StatusUnauthorized, body: `{"error":"unauthenticated","alias":"reports","required_roles":["finance","admin"]}`},
+		{name: "missing role", alias: "reports", user: "bob", roles: "sales", statusCode: http.StatusForbidden, body: `{"error":"forbidden","alias":"reports","required_roles":["finance","admin"]}`},
+		{name: "role", alias: "reports", user: "alice", roles: "sales,finance", statusCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/resources/"+tt.alias, nil)
+			if tt.user != "" {
+				req.Header.Set("X-User", tt.user)
+				req.Header.Set("X-Roles", tt.roles)
+			}
+			w := httptest.NewRecorder()
+			c.ServeHTTP(w, req)
+
+			if w.Code != tt.statusCode {
+				t.Errorf("<response> statusCode not equal. expected %v obtained %v\n", tt.statusCode, w.Code)
+			}
+
+			if tt.body != "" && strings.TrimSpace(w.Body.String()) != tt.body {
+				t.Errorf("<response> body not equal. expected %v obtained %v\n", tt.body, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package routing
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when a fetch is skipped because the circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// BreakerState represents the state of a circuit breaker
+type BreakerState int
+
+// Circuit breaker states
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitBreaker stops fetching from a failing upstream for a while
+type CircuitBreaker struct {
+	// FailureThreshold opens the breaker after that many consecutive failures
+	FailureThreshold int
+	// OpenDuration is how long the breaker stays open before letting probes through
+	OpenDuration time.Duration
+	// HalfOpenProbes is the number of successful probes needed to close the breaker (defaults to 1)
+	HalfOpenProbes int
+	// OnStateChange is called whenever the breaker changes state
+	OnStateChange func(res *Resource, from, to BreakerState)
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	successes int
+	openedAt  time.Time
+}
+
+// State returns the current state of the breaker
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// allow reports whether a fetch may go through, moving an expired open breaker to half-open
+func (b *CircuitBreaker) allow(res *Resource) bool {
+	b.mu.Lock()
+	if b.state != BreakerOpen {
+		b.mu.Unlock()
+		return true
+	}
+
+	if time.Since(b.openedAt) < b.OpenDuration {
+		b.mu.Unlock()
+		return false
+	}
+
+	b.successes = 0
+	b.mu.Unlock()
+
+	b.transition(res, BreakerHalfOpen)
+
+	return true
+}
+
+// record updates the breaker with the outcome of a fetch
+func (b *CircuitBreaker) record(res *Resource, failure error) {
+	b.mu.Lock()
+
+	var next BreakerState
+	switch {
+	case failure != nil && b.state == BreakerHalfOpen:
+		next = BreakerOpen
+	case failure != nil:
+		b.failures++
+		if b.FailureThreshold <= 0 || b.failures < b.FailureThreshold {
+			b.mu.Unlock()
+			return
+		}
+		next = BreakerOpen
+	case b.state == BreakerHalfOpen:
+		b.successes++
+		if b.successes < b.HalfOpenProbes {
+			b.mu.Unlock()
+			return
+		}
+		next = BreakerClosed
+	default:
+		b.failures = 0
+		b.mu.Unlock()
+		return
+	}
+
+	b.mu.Unlock()
+
+	b.transition(res, next)
+}
+
+func (b *CircuitBreaker) transition(res *Resource, to BreakerState) {
+	b.mu.Lock()
+	from := b.state
+	b.state = to
+	b.failures = 0
+	if to == BreakerOpen {
+		b.openedAt = time.Now()
+	}
+	b.mu.Unlock()
+
+	if from != to && b.OnStateChange != nil {
+		b.OnStateChange(res, from, to)
+	}
+}
+
+// BreakerState returns the state of the resource circuit breaker, closed when there is none
+func (r *Resource) BreakerState() BreakerState {
+	if r.Breaker == nil {
+		return BreakerClosed
+	}
+
+	return r.Breaker.State()
+}
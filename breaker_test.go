@@ -0,0 +1,77 @@
+package routing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var numRequests, healthy int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numRequests, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	var (
+		mu          sync.Mutex
+		transitions []string
+	)
+
+	res := &routing.Resource{
+		Alias:    "flapping",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: 10 * time.Millisecond,
+		Breaker: &routing.CircuitBreaker{
+			FailureThreshold: 2,
+			OpenDuration:     150 * time.Millisecond,
+			OnStateChange: func(res *routing.Resource, from, to routing.BreakerState) {
+				mu.Lock()
+				transitions = append(transitions, from.String()+">"+to.String())
+				mu.Unlock()
+			},
+		},
+	}
+
+	res.StartFetcher()
+	defer res.StopFetcher()
+
+	time.Sleep(80 * time.Millisecond)
+
+	if state := res.BreakerState(); state != routing.BreakerOpen {
+		t.Errorf("<breaker> state not equal. expected %v obtained %v\n", routing.BreakerOpen, state)
+	}
+
+	if n := atomic.LoadInt32(&numRequests); n != 2 {
+		t.Errorf("<breaker> upstream hits while open not equal. expected 2 obtained %d\n", n)
+	}
+
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(150 * time.Millisecond)
+
+	if state := res.BreakerState(); state != routing.BreakerClosed {
+		t.Errorf("<breaker> state not equal. expected %v obtained %v\n", routing.BreakerClosed, state)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := []string{"closed>open", "open>half-open", "half-open>closed"}
+	if !reflect.DeepEqual(expected, transitions) {
+		t.Errorf("<breaker> transitions not equal. expected %v obtained %v\n", expected, transitions)
+	}
+}
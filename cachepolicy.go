@@ -0,0 +1,85 @@
+package routing
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CachePolicy defines the Cache-Control directives sent to clients, tuning browser and
+// CDN caching independently of the fetch interval
+type CachePolicy struct {
+	// MaxAge of the content in browsers (0 = fetch interval, negative = max-age=0)
+	MaxAge time.Duration
+	// SMaxAge of the content in shared caches such as CDNs (0 = omitted)
+	SMaxAge time.Duration
+	// StaleWhileRevalidate lets caches serve stale content while revalidating in the background (0 = omitted)
+	StaleWhileRevalidate time.Duration
+	// StaleIfError lets caches serve stale content when revalidation fails (0 = omitted)
+	StaleIfError time.Duration
+
+	// NoStore forbids any caching, the other directives being ignored
+	NoStore bool
+	// NoCache requires caches to revalidate before each use
+	NoCache bool
+	// Private restricts caching to browsers, Public allows shared caches to store authorized responses
+	Private bool
+	Public  bool
+	// Immutable tells browsers the content never changes while fresh
+	Immutable bool
+}
+
+// cacheControl builds the Cache-Control header value, defaulting to max-age of the fetch interval
+func (p *CachePolicy) cacheControl(interval time.Duration) string {
+	if p == nil {
+		return "max-age=" + seconds(interval)
+	}
+
+	if p.NoStore {
+		return "no-store"
+	}
+
+	var directives []string
+	if p.Private {
+		directives = append(directives, "private")
+	} else if p.Public {
+		directives = append(directives, "public")
+	}
+
+	if p.NoCache {
+		directives = append(directives, "no-cache")
+	}
+
+	maxAge := p.MaxAge
+	if maxAge == 0 {
+		maxAge = interval
+	}
+	directives = append(directives, "max-age="+seconds(maxAge))
+
+	if p.SMaxAge > 0 {
+		directives = append(directives, "s-maxage="+seconds(p.SMaxAge))
+	}
+
+	if p.StaleWhileRevalidate > 0 {
+		directives = append(directives, "stale-while-revalidate="+seconds(p.StaleWhileRevalidate))
+	}
+
+	if p.StaleIfError > 0 {
+		directives = append(directives, "stale-if-error="+seconds(p.StaleIfError))
+	}
+
+	if p.Immutable {
+		directives = append(directives, "immutable")
+	}
+
+	return strings.Join(directives, ", ")
+}
+
+// seconds formats a duration as whole seconds, negative ones being 0
+func seconds(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+
+	return strconv.FormatInt(int64(d/time.Second), 10)
+}
@@ -0,0 +1,59 @@
+package routing
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCachePolicyCacheControl(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   *CachePolicy
+		expected string
+	}{
+		{name: "default", policy: nil, expected: "max-age=60"},
+		{name: "interval", policy: &CachePolicy{}, expected: "max-age=60"},
+		{name: "no store", policy: &CachePolicy{NoStore: true, MaxAge: time.Hour}, expected: "no-store"},
+		{name: "zero max age", policy: &CachePolicy{MaxAge: -1, NoCache: true}, expected: "no-cache, max-age=0"},
+		{
+			name: "cdn",
+			policy: &CachePolicy{
+				Public:               true,
+				MaxAge:               10 * time.Second,
+				SMaxAge:              5 * time.Minute,
+				StaleWhileRevalidate: 30 * time.Second,
+				StaleIfError:         time.Hour,
+			},
+			expected: "public, max-age=10, s-maxage=300, stale-while-revalidate=30, stale-if-error=3600",
+		},
+		{name: "private", policy: &CachePolicy{Private: true, Public: true, Immutable: true}, expected: "private, max-age=60, immutable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if obtained := tt.policy.cacheControl(time.Minute); obtained != tt.expected {
+				t.Errorf("<policy> Cache-Control not equal. expected %v obtained %v\n", tt.expected, obtained)
+			}
+		})
+	}
+}
+
+func TestCachePolicyJSON(t *testing.T) {
+	policy := &CachePolicy{SMaxAge: 5 * time.Minute, StaleWhileRevalidate: 30 * time.Second, Public: true}
+
+	b, err := json.Marshal(&Resource{Alias: "policy", CachePolicy: policy})
+	if err != nil {
+		t.Fatalf("marshal error: %s", err)
+	}
+
+	var res Resource
+	if err := json.Unmarshal(b, &res); err != nil {
+		t.Fatalf("unmarshal error: %s", err)
+	}
+
+	if !reflect.DeepEqual(policy, res.CachePolicy) {
+		t.Errorf("<policy> decoded policy not equal. expected %+v obtained %+v\n", policy, res.CachePolicy)
+	}
+}
@@ -1,20 +1,48 @@
 package routing
 
 import (
+	"context"
 	"crypto/sha1"
+	"crypto/tls"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+)
+
+const (
+	defaultTimeout      = 10 * time.Second
+	defaultRetryBackoff = time.Second
+)
 
-	"github.com/sirupsen/logrus"
+var (
+	// ErrMissingAlias is returned when adding a resource without alias
+	ErrMissingAlias = errors.New("missing alias")
+	// ErrInvalidAlias is returned when adding a resource whose alias cannot be routed
+	ErrInvalidAlias = errors.New("invalid alias")
+	// ErrMissingMethod is returned when adding a resource without method nor Fetcher
+	ErrMissingMethod = errors.New("missing method")
+	// ErrMissingURL is returned when adding a resource without url nor Fetcher
+	ErrMissingURL = errors.New("missing url")
+	// ErrInvalidInterval is returned when adding a resource without interval nor schedule, or with invalid bounds
+	ErrInvalidInterval = errors.New("invalid interval")
+	// ErrDuplicateResource is returned when adding a resource whose alias is already used
+	ErrDuplicateResource = errors.New("resource already exist")
+	// ErrResourceNotFound is returned when no resource has the given alias
+	ErrResourceNotFound = errors.New("no resource found")
 )
 
 // ResourceEvent represents a callback fn
 type ResourceEvent func(res *Resource)
 
+// RequestInterceptor alters an outgoing upstream request, an error aborts the fetch
+type RequestInterceptor func(req *http.Request) error
+
 // Resources is map of resources
 type Resources map[string]*Resource
 
@@ -24,6 +52,7 @@ type Resource struct {
 	Method         string
 	URL            string
 	Interval       time.Duration
+	Timeout        time.Duration
 	Content        []byte
 	Header         http.Header
 	StatusCode     int
@@ -31,46 +60,285 @@ type Resource struct {
 	OldHash        string
 	AllowedOrigins []string
 
-	onUpdateEvents []ResourceEvent
-	running        bool
-	stopFetcher    chan (struct{})
-	mu             sync.Mutex
+	// LastModified is when the content last changed, taken from upstream Last-Modified when given
+	LastModified time.Time
+	// ChangedAt is when a fetch last got content with a new hash
+	ChangedAt time.Time
+
+	// CachePolicy overrides the Cache-Control header sent to clients (defaults to max-age=Interval)
+	CachePolicy *CachePolicy
+
+	// CORS configures cross-origin requests from AllowedOrigins (defaults to Options.CORS)
+	CORS *CORSConfig
+
+	// Authenticator controls the access to the resource (defaults to Options.Authenticator)
+	Authenticator Authenticator
+	// APIKeys grant access to the resource through the X-API-Key header when no Authenticator is set
+	APIKeys []string
+	// Roles grant access to the resource to principals holding one of them, see Options.PrincipalFunc
+	Roles []string
+
+	// RequestHeaders are sent with the upstream request
+	RequestHeaders http.Header
+	// HTTPClient sends the upstream requests, overriding Timeout, Transport and TLSConfig (defaults to Options.HTTPClient)
+	HTTPClient *http.Client
+	// Transport sends the upstream requests, overriding TLSConfig (defaults to Options.Transport)
+	Transport http.RoundTripper
+	// TLSConfig for HTTPS upstreams with private CAs or client certificates (defaults to Options.TLSConfig)
+	TLSConfig *tls.Config
+	// Auth holds the credentials of protected upstreams
+	Auth *UpstreamAuth
+	// Body is sent with the upstream request, e.g. a JSON payload for POST resources
+	Body []byte
+	// BodyFunc builds the request body before each fetch, overriding Body
+	BodyFunc func() ([]byte, error)
+	// ContentType of the upstream request body
+	ContentType string
+
+	// MaxContentSize aborts fetches of larger content, keeping the previous one (defaults to Options.MaxContentSize)
+	MaxContentSize int64
+
+	// Fetcher obtains the content instead of the default HTTP request to URL
+	Fetcher Fetcher
+
+	// MaxVariants bounds the number of cached variants of a URL template like
+	// "https://api/x?id={id}", filled from the client request query (defaults to 100)
+	MaxVariants int
+	// VariantTTL is how long a variant is served before being fetched again (defaults to Interval)
+	VariantTTL time.Duration
+
+	// Languages lists the languages the content is cached in, the first being the default. Each
+	// one is fetched filling {lang} in the URL and forwarding it as Accept-Language, and served
+	// according to the client Accept-Language header
+	Languages []string
+
+	// MaxVersions is the number of past contents kept, served with ?version=<hash> or as
+	// a JSON Patch with ?from=<hash>[&to=<hash>]
+	MaxVersions int
+
+	// Representations are alternative media types of the content, served according to the Accept header
+	Representations []*Representation
+
+	// EventName is the type of the SSE events carrying the content, letting browsers
+	// addEventListener per resource (defaults to the alias)
+	EventName string
+
+	// JSONSchema validates the fetched content, which is rejected when invalid, the resource
+	// being degraded until valid content is fetched again
+	JSONSchema *JSONSchema
+
+	// Transformers rewrite the fetched content in order before it is cached, Hash, Etag
+	// and Content-Length being computed from their output
+	Transformers []Transformer
+
+	// Schedule is a cron expression (minute hour day-of-month month day-of-week, e.g.
+	// "*/5 8-18 * * MON-FRI") driving the fetches instead of Interval
+	Schedule string
+
+	// Adaptive schedules the next fetch from the upstream Cache-Control max-age or Expires
+	// headers, falling back to Interval when upstream gives none
+	Adaptive bool
+	// MinInterval and MaxInterval bound the adaptive interval (0 = unbounded)
+	MinInterval time.Duration
+	MaxInterval time.Duration
+
+	// Jitter randomly spreads each fetch by up to ± this duration around Interval
+	Jitter time.Duration
+	// JitterFactor is like Jitter as a fraction of Interval (e.g. 0.1 for ±10%), overriding Jitter
+	JitterFactor float64
+
+	// FallbackURLs are tried in order when fetching URL fails
+	FallbackURLs []string
+	// FallbackOnBadStatus also tries the fallbacks when upstream answers a non-2xx status
+	FallbackOnBadStatus bool
+	// FetchedURL is the URL the current content was fetched from
+	FetchedURL string
+
+	// RequestInterceptors are run in order on the upstream request just before it is sent
+	RequestInterceptors []RequestInterceptor
+
+	// MaxRetries is the number of retries of a failed fetch before waiting for the next tick
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, doubled on each attempt up to the interval
+	// or, following Schedule, the delay until the next run (defaults to 1s)
+	RetryBackoff time.Duration
+	// OnFetchError is called on every failed fetch attempt
+	OnFetchError func(res *Resource, err error)
+	// OnFetchSuccess is called on every successful fetch, whether the content changed or not
+	OnFetchSuccess ResourceEvent
+
+	// Breaker stops fetching from a failing upstream for a while
+	Breaker *CircuitBreaker
+
+	// UnhealthyThreshold is the number of consecutive failures marking the resource unhealthy (0 = never)
+	UnhealthyThreshold int
+	// OnUnhealthy is called once the failures reach UnhealthyThreshold, with their count and the last error
+	OnUnhealthy func(res *Resource, failures int, err error)
+	// OnRecovered is called when an unhealthy resource is fetched successfully again
+	OnRecovered ResourceEvent
+
+	// MaxConsecutiveFailures pauses the fetcher after that many failed fetches in a row (0 = never)
+	MaxConsecutiveFailures int
+	// RemoveOnGone removes the resource from its cacher when upstream answers 410 Gone
+	RemoveOnGone bool
+
+	snapshot             atomic.Value
+	prepareSnapshot      func(s *Snapshot)
+	onUpdateEvents       []ResourceEvent
+	onFollowEvents       []ResourceEvent
+	onError              func(res *Resource, err error)
+	onFetchError         func(res *Resource, err error)
+	onFetchSuccess       ResourceEvent
+	onPause              ResourceEvent
+	onGone               ResourceEvent
+	onUnhealthy          func(res *Resource, failures int, err error)
+	onRecovered          ResourceEvent
+	failures             int
+	unhealthy            bool
+	lastError            error
+	lastFetch            time.Time
+	fetchedAt            time.Time
+	nextFetch            time.Time
+	tlsTransport         *http.Transport
+	tracer               Tracer
+	fetchSem             chan struct{}
+	cron                 *cronSchedule
+	upstreamETag         string
+	upstreamLastModified string
+	upstreamFreshness    int64
+	stale                int32
+	params               []string
+	history              []*Snapshot
+	historyMu            sync.RWMutex
+	variants             variantCache
+	degraded             int32
+	runMu                sync.Mutex
+	done                 chan struct{}
+	stop                 func()
+	fetching             int32
+	fetchMu              sync.Mutex
+	stateMu              sync.Mutex
+	inflight             sync.WaitGroup
+	mu                   sync.Mutex
 }
 
 // Fetch makes the request to obtain the resource and caches the result
 func (r *Resource) Fetch() error {
+	return r.FetchContext(context.Background())
+}
+
+// FetchContext is like Fetch, the upstream request being bound to ctx
+func (r *Resource) FetchContext(ctx context.Context) error {
+	if r.fetchSem != nil {
+		// Wait for a fetch slot
+		select {
+		case r.fetchSem <- struct{}{}:
+			defer func() { <-r.fetchSem }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	cli := &http.Client{
-		Timeout: time.Second * 10,
+	if r.tracer == nil {
+		return r.fetch(ctx)
+	}
+
+	ctx, span := r.tracer.Start(ctx, "routing.fetch")
+	defer span.End()
+
+	span.SetAttribute("routing.alias", r.Alias)
+
+	err := r.fetch(ctx)
+	if r.FetchedURL != "" {
+		span.SetAttribute("http.url", r.FetchedURL)
 	}
 
-	req, err := http.NewRequest(r.Method, r.URL, nil)
 	if err != nil {
-		return err
+		span.RecordError(err)
+	} else {
+		span.SetAttribute("http.status_code", r.StatusCode)
+	}
+
+	return err
+}
+
+func (r *Resource) fetch(ctx context.Context) error {
+	fetcher := r.Fetcher
+	if fetcher == nil {
+		fetcher = FetcherFunc(r.fetchHTTP)
 	}
 
-	resp, err := cli.Do(req)
+	b, header, statusCode, err := fetcher.Fetch(ctx)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	b, err := ioutil.ReadAll(resp.Body)
+	// The HTTP fetcher aborts earlier, custom ones are checked once they return
+	if r.MaxContentSize > 0 && int64(len(b)) > r.MaxContentSize {
+		return ErrContentTooLarge
+	}
+
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	atomic.StoreInt64(&r.upstreamFreshness, int64(freshness(header, time.Now())))
+
+	if statusCode == http.StatusNotModified {
+		if r.Hash == "" {
+			return fmt.Errorf("unexpected status %d without cached content", statusCode)
+		}
+
+		// Upstream content unchanged, keep serving what we have
+		return nil
+	}
+
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+
+	if r.JSONSchema != nil && statusCode < http.StatusMultipleChoices {
+		if err := r.JSONSchema.Validate(b); err != nil {
+			// Keep serving the previous content
+			atomic.StoreInt32(&r.degraded, 1)
+			return err
+		}
+		atomic.StoreInt32(&r.degraded, 0)
+	}
+
+	b, header, err = r.transform(b, header)
 	if err != nil {
 		return err
 	}
 
+	// Upstream validators for conditional requests
+	r.upstreamETag = header.Get("Etag")
+	r.upstreamLastModified = header.Get("Last-Modified")
+
+	r.fetchedAt = time.Now()
 	r.OldHash = r.Hash
 	r.Hash = fmt.Sprintf("%x", sha1.Sum(b))
 	r.Content = b
-	r.StatusCode = resp.StatusCode
-	r.Header = resp.Header.Clone()
+	r.StatusCode = statusCode
+	r.Header = header
+
+	if r.Changed() {
+		r.ChangedAt = time.Now()
+	}
+
+	if r.Changed() || r.LastModified.IsZero() {
+		r.LastModified = lastModified(header, time.Now())
+	}
 
 	// Cache control headers
 	r.Header.Set("Etag", r.Hash)
-	r.Header.Set("Cache-Control", fmt.Sprintf("max-age=%d", r.Interval/time.Second))
+	r.Header.Set("Last-Modified", r.LastModified.Format(http.TimeFormat))
+	r.Header.Set("Cache-Control", r.CachePolicy.cacheControl(r.baseInterval()))
+
+	r.publish()
 
 	// Executing onUpdateEvents
 	r.executeUpdateEvents()
@@ -78,6 +346,11 @@ func (r *Resource) Fetch() error {
 	return nil
 }
 
+// Changed tells whether the last fetch got content with a new hash
+func (r *Resource) Changed() bool {
+	return r.Hash != r.OldHash
+}
+
 // IsOriginAllowed checks if origin is valid
 func (r *Resource) IsOriginAllowed(origin string) bool {
 	if !r.isOriginCheckEnabled() {
@@ -98,13 +371,26 @@ func (r *Resource) IsOriginAllowed(origin string) bool {
 	return false
 }
 
+// timeout returns the effective fetch timeout, defaulting to 10s
+func (r *Resource) timeout() time.Duration {
+	if r.Timeout > 0 {
+		return r.Timeout
+	}
+
+	return defaultTimeout
+}
+
 func (r *Resource) isOriginCheckEnabled() bool {
 	// Check if origin check enabled
 	return r.AllowedOrigins != nil && len(r.AllowedOrigins) != 0
 }
 
 func (r *Resource) executeUpdateEvents() {
-	for _, e := range r.onUpdateEvents {
+	r.executeEvents(r.onUpdateEvents)
+}
+
+func (r *Resource) executeEvents(events []ResourceEvent) {
+	for _, e := range events {
 		if e == nil {
 			continue
 		}
@@ -112,37 +398,237 @@ func (r *Resource) executeUpdateEvents() {
 	}
 }
 
-// StartFetcher starts the automatic fetcher
-func (r *Resource) StartFetcher() {
-	if r.running {
-		// Already running
-		return
+// fetchWithRetry fetches the resource, retrying failed requests and 5xx answers with exponential backoff
+func (r *Resource) fetchWithRetry(ctx context.Context) error {
+	backoff := r.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
 	}
 
-	r.running = true
-	ticker := time.NewTicker(r.Interval)
+	for attempt := 0; ; attempt++ {
+		if r.Breaker != nil && !r.Breaker.allow(r) {
+			return ErrCircuitOpen
+		}
 
-	if err := r.Fetch(); err != nil {
-		// First time fetch we still execute the onUpdateEvents
-		r.executeUpdateEvents()
+		err := r.FetchContext(ctx)
+		if ctx.Err() != nil {
+			return err
+		}
+
+		failure := err
+		if failure == nil && r.StatusCode >= http.StatusInternalServerError {
+			failure = fmt.Errorf("unexpected status %d", r.StatusCode)
+		}
+
+		if r.Breaker != nil {
+			r.Breaker.record(r, failure)
+		}
+
+		if failure == nil {
+			r.stateMu.Lock()
+			r.lastFetch = time.Now()
+			r.stateMu.Unlock()
+			if r.OnFetchSuccess != nil {
+				r.OnFetchSuccess(r)
+			}
+			if r.onFetchSuccess != nil {
+				r.onFetchSuccess(r)
+			}
+			return nil
+		}
+
+		if r.OnFetchError != nil {
+			r.OnFetchError(r, failure)
+		}
+		if r.onFetchError != nil {
+			r.onFetchError(r, failure)
+		}
+
+		if attempt >= r.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if max := r.maxBackoff(); max > 0 && backoff > max {
+			backoff = max
+		}
 	}
+}
 
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				r.Fetch()
-			case <-r.stopFetcher:
-				r.running = false
-				return
+// failureCount returns the number of consecutive failed fetches
+func (r *Resource) failureCount() int {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+
+	return r.failures
+}
+
+// track keeps count of consecutive failures and reports whether the fetcher should keep running
+func (r *Resource) track(err error) bool {
+	// Content from a previous fetch is kept when the request itself failed
+	stale := int32(0)
+	if err != nil && r.Hash != "" {
+		stale = 1
+	}
+	atomic.StoreInt32(&r.stale, stale)
+
+	if err == nil && r.StatusCode >= http.StatusBadRequest {
+		err = fmt.Errorf("unexpected status %d", r.StatusCode)
+	}
+
+	if err == nil {
+		r.stateMu.Lock()
+		recovered := r.unhealthy
+		r.failures = 0
+		r.lastError = nil
+		r.unhealthy = false
+		r.stateMu.Unlock()
+
+		if recovered {
+			if r.OnRecovered != nil {
+				r.OnRecovered(r)
+			}
+			if r.onRecovered != nil {
+				r.onRecovered(r)
 			}
 		}
-	}()
+		return true
+	}
+
+	r.stateMu.Lock()
+	r.failures++
+	r.lastError = err
+	failures := r.failures
+	unhealthy := r.UnhealthyThreshold > 0 && failures >= r.UnhealthyThreshold && !r.unhealthy
+	if unhealthy {
+		r.unhealthy = true
+	}
+	r.stateMu.Unlock()
+
+	if r.onError != nil {
+		r.onError(r, err)
+	}
+
+	if unhealthy {
+		if r.OnUnhealthy != nil {
+			r.OnUnhealthy(r, failures, err)
+		}
+		if r.onUnhealthy != nil {
+			r.onUnhealthy(r, failures, err)
+		}
+	}
+
+	if r.RemoveOnGone && r.StatusCode == http.StatusGone {
+		if r.onGone != nil {
+			r.onGone(r)
+		}
+		return false
+	}
+
+	if r.MaxConsecutiveFailures > 0 && failures >= r.MaxConsecutiveFailures {
+		if r.onPause != nil {
+			r.onPause(r)
+		}
+		return false
+	}
+
+	return true
 }
 
-// StopFetcher stops the automatic fetcher
+// cacheStatus returns the X-Cache value describing how a snapshot of the resource is being served
+func (r *Resource) cacheStatus(s *Snapshot) string {
+	switch {
+	case s.Hash == "":
+		return "MISS"
+	case atomic.LoadInt32(&r.stale) == 1:
+		return "STALE"
+	default:
+		return "HIT"
+	}
+}
+
+// StartFetcher starts the automatic fetcher, doing nothing when it is already running
+func (r *Resource) StartFetcher() {
+	if r.parameterized() {
+		// Variants are fetched on demand
+		return
+	}
+
+	r.runMu.Lock()
+	if r.isRunning() {
+		r.runMu.Unlock()
+		return
+	}
+
+	// Cancelling the context stops the fetcher and aborts any fetch in progress
+	ctx, cancel := context.WithCancel(context.Background())
+	item := &scheduleItem{res: r, ctx: ctx, index: -1}
+	done := make(chan struct{})
+
+	var once sync.Once
+	r.done = done
+	r.stop = func() {
+		once.Do(func() {
+			cancel()
+			fetchScheduler.unschedule(item)
+			close(done)
+		})
+	}
+	stop := r.stop
+	r.runMu.Unlock()
+
+	r.fetchMu.Lock()
+	err := r.fetchWithRetry(ctx)
+	keep := r.track(err)
+	r.fetchMu.Unlock()
+
+	if !keep {
+		stop()
+		return
+	}
+
+	if ctx.Err() == nil {
+		fetchScheduler.schedule(item, r.scheduleNext())
+	}
+}
+
+// StopFetcher stops the automatic fetcher, aborting any fetch in progress. It can be called
+// any number of times, whether the fetcher was started or not.
 func (r *Resource) StopFetcher() {
-	r.stopFetcher <- struct{}{}
+	r.runMu.Lock()
+	stop := r.stop
+	r.runMu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+}
+
+// Running reports whether the automatic fetcher is running
+func (r *Resource) Running() bool {
+	r.runMu.Lock()
+	defer r.runMu.Unlock()
+
+	return r.isRunning()
+}
+
+func (r *Resource) isRunning() bool {
+	if r.done == nil {
+		return false
+	}
+
+	select {
+	case <-r.done:
+		return false
+	default:
+		return true
+	}
 }
 
 // WriteHeaders write the header to a response writer
@@ -156,39 +642,158 @@ func (r *Resource) WriteHeaders(w http.ResponseWriter) {
 
 // Options represents a set of resource cacher options
 type Options struct {
-	// Defines a custom logger
-	Logger *logrus.Entry
+	// Defines a custom logger, see NewStdLogger, NewLogrusLogger and NewZapLogger (defaults to NopLogger)
+	Logger Logger
+
+	// Precompresses content with these encodings, in order of preference, served according to
+	// Accept-Encoding. Only gzip and deflate are built in, see Encoding for Brotli.
+	Encodings []Encoding
+	// Content smaller than this many bytes is not encoded
+	MinEncodingSize int
+
+	// Defines where fetched content is stored (defaults to memory), a FileStore
+	// persists it to disk for resources to be served right away after a restart
+	Store CacheStore
+
+	// Default HTTP client, transport and TLS configuration of upstream requests
+	HTTPClient *http.Client
+	Transport  http.RoundTripper
+	TLSConfig  *tls.Config
+
+	// Default maximum size in bytes of fetched content (0 = unlimited)
+	MaxContentSize int64
+
+	// Bounds the number of upstream fetches running at once across all resources (0 = unlimited)
+	MaxConcurrentFetches int
+
+	// Instruments fetches and serving with tracing spans, propagating the trace context upstream (nil = disabled)
+	Tracer Tracer
+
+	// Interpolates ${VAR} environment variables in resource URLs, request headers and auth when added
+	ExpandEnv bool
+
+	// Adds an X-Cache header (HIT, STALE or MISS) to served responses
+	DebugHeaders bool
+
+	// Fires OnResourceUpdated and publishes update events after every successful fetch, not only when the content changed
+	NotifyUnchanged bool
+
+	// Maps requests to resource aliases, e.g. from the hostname or token claims
+	// (defaults to the ?alias= query parameter, falling back to the last path segment)
+	AliasFunc func(r *http.Request) (string, error)
+
+	// Controls the access to resources without their own Authenticator or APIKeys (nil = public)
+	Authenticator Authenticator
+
+	// Identifies the principal of requests to resources restricted to Roles
+	PrincipalFunc PrincipalFunc
+
+	// Default cross-origin configuration of resources without their own
+	CORS *CORSConfig
+
+	// Invalidates downstream caches such as CDNs when the content of a resource changes (nil = disabled)
+	Purger Purger
+
+	// Posts an event to webhook URLs when the content of a resource changes (nil = disabled)
+	Webhooks *WebhookNotifier
+
+	// Receive the added, updated and removed events of resources
+	Publishers []Publisher
+
+	// Longest wait of long polling requests before answering 304 (defaults to 30s)
+	PollTimeout time.Duration
+
+	// Elects the instance of a cluster running the fetchers, the others serving the
+	// content of the shared Store, which notifies them of the updates when it implements
+	// CacheStoreWatcher like RedisStore (nil = every instance fetches)
+	Elector Elector
+
+	// Customizes the answer to requests from origins not allowed (defaults to 403 with a JSON error)
+	OriginRejection *OriginRejection
+
+	// Writes error responses (400, 401, 403, 404, 405) instead of the default plain text messages
+	ErrorHandler ErrorHandler
+
+	// Bearer token of the admin API mounted by RegisterResourceCacher, also required by its
+	// status endpoint (empty = admin API not mounted)
+	AdminToken string
 }
 
 // ResourceCacher creates a reverse proxy that caches the results
 type ResourceCacher struct {
-	OnResourceAdded   ResourceEvent
-	OnResourceUpdated ResourceEvent
-	OnResourceRemoved ResourceEvent
-	OnStarted         func()
-	OnStopped         func()
+	OnResourceAdded     ResourceEvent
+	OnResourceUpdated   ResourceEvent
+	OnResourceRemoved   ResourceEvent
+	OnResourceError     func(res *Resource, err error)
+	OnResourcePaused    ResourceEvent
+	OnResourceUnhealthy func(res *Resource, failures int, err error)
+	OnResourceRecovered ResourceEvent
+	OnFetchError        func(res *Resource, err error)
+	OnFetchSuccess      ResourceEvent
+	OnOriginRejected    func(res *Resource, origin string, r *http.Request)
+	OnStarted           func()
+	OnStopped           func()
 
 	resources Resources
-	mu        sync.Mutex
+	mu        sync.RWMutex
+	// resourcesMu serializes the changes of the resources, replacing them being read-modify-write
+	resourcesMu sync.Mutex
+	fetchSem    chan struct{}
+
+	publishers   []*publisherQueue
+	publishersMu sync.RWMutex
+
+	updated   chan struct{}
+	updatedMu sync.Mutex
+
+	leader       int32
+	stopCampaign func()
+	campaignMu   sync.Mutex
+
+	// clientCount returns the number of clients listening to an alias, set by the SSE variants
+	clientCount func(alias string) int
+	// sseStats returns the counters of the SSE events of an alias, set by the SSE variants
+	sseStats func(alias string) *SSEStats
 
 	opts *Options
 }
 
-// NewResourceCacher creates a new resource cacher
-func NewResourceCacher(opts *Options) *ResourceCacher {
+// NewResourceCacher creates a new resource cacher configured by functional options such as
+// WithLogger or WithStore, an *Options being accepted as well
+func NewResourceCacher(opts ...Option) *ResourceCacher {
 	rc := &ResourceCacher{
 		resources: make(Resources),
-		opts:      opts,
+		opts:      &Options{},
 	}
 
-	if rc.opts == nil {
-		rc.opts = &Options{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt.apply(rc.opts)
+		}
 	}
 
 	if rc.opts.Logger == nil {
-		logger := logrus.New()
-		logger.SetOutput(ioutil.Discard)
-		rc.opts.Logger = logrus.NewEntry(logger)
+		rc.opts.Logger = NopLogger()
+	}
+
+	if rc.opts.Store == nil {
+		rc.opts.Store = NewMemoryStore()
+	}
+
+	if s, ok := rc.opts.Store.(interface{ setLogger(Logger) }); ok {
+		s.setLogger(rc.opts.Logger)
+	}
+
+	if s, ok := rc.opts.Store.(CacheStoreWatcher); ok {
+		s.Watch(rc.storeUpdated)
+	}
+
+	if rc.opts.MaxConcurrentFetches > 0 {
+		rc.fetchSem = make(chan struct{}, rc.opts.MaxConcurrentFetches)
+	}
+
+	for _, p := range rc.opts.Publishers {
+		rc.AddPublisher(p)
 	}
 
 	return rc
@@ -196,64 +801,372 @@ func NewResourceCacher(opts *Options) *ResourceCacher {
 
 // AddResource adds a new resource to the resource cacher
 func (c *ResourceCacher) AddResource(res *Resource, onUpdate ResourceEvent) (*Resource, error) {
+	if err := c.validateResource(res); err != nil {
+		return nil, err
+	}
+
+	c.resourcesMu.Lock()
+	if _, ok := c.GetResource(res.Alias); ok {
+		c.resourcesMu.Unlock()
+		return nil, ErrDuplicateResource
+	}
+
+	c.prepareResource(res, onUpdate)
+	c.warmStart(res)
+
+	c.mu.Lock()
+	c.resources[res.Alias] = res
+	c.mu.Unlock()
+	c.resourcesMu.Unlock()
+
+	c.resourceAdded(res)
+	c.startFetcher(res)
+
+	return res, nil
+}
+
+func (c *ResourceCacher) validateResource(res *Resource) error {
+	if c.opts.ExpandEnv {
+		if err := expandResourceEnv(res); err != nil {
+			return err
+		}
+	}
+
 	if res.Alias == "" {
-		return nil, errors.New("missing alias")
+		return ErrMissingAlias
+	}
+
+	if err := validateAlias(res.Alias); err != nil {
+		return fmt.Errorf("%w %q", ErrInvalidAlias, res.Alias)
+	}
+
+	if res.Fetcher == nil && res.Method == "" {
+		return ErrMissingMethod
+	}
+
+	if res.Fetcher == nil && res.URL == "" {
+		return ErrMissingURL
+	}
+
+	if res.Fetcher == nil {
+		res.params = nil
+		for _, param := range urlParams(res.URL) {
+			// {lang} is filled with the negotiated language
+			if param != "lang" || len(res.Languages) == 0 {
+				res.params = append(res.params, param)
+			}
+		}
+	}
+
+	for _, rep := range res.Representations {
+		if err := rep.validate(); err != nil {
+			return err
+		}
 	}
 
-	_, ok := c.resources[res.Alias]
-	if ok {
-		return nil, errors.New("resource already exist")
+	if res.Schedule != "" {
+		cron, err := parseCron(res.Schedule)
+		if err != nil {
+			return err
+		}
+		res.cron = cron
+	} else if res.Interval == 0 {
+		return ErrInvalidInterval
 	}
 
-	if res.Method == "" {
-		return nil, errors.New("missing method")
+	if res.MaxInterval > 0 && res.MinInterval > res.MaxInterval {
+		return fmt.Errorf("%w: min interval above max interval", ErrInvalidInterval)
 	}
 
-	if res.URL == "" {
-		return nil, errors.New("missing url")
+	if res.Schedule == "" && res.timeout() > res.Interval {
+		c.opts.Logger.Warn("fetch timeout exceeds interval, slow fetches will skip ticks",
+			F("alias", res.Alias), F("timeout", res.timeout()), F("interval", res.Interval))
 	}
 
-	if res.Interval == 0 {
-		return nil, errors.New("invalid interval")
+	return nil
+}
+
+// prepareResource wires the resource events to the resource cacher
+func (c *ResourceCacher) prepareResource(res *Resource, onUpdate ResourceEvent) {
+	if res.MaxContentSize == 0 {
+		res.MaxContentSize = c.opts.MaxContentSize
 	}
 
-	res.onUpdateEvents = append(res.onUpdateEvents, onUpdate, c.OnResourceUpdated)
+	if res.HTTPClient == nil {
+		res.HTTPClient = c.opts.HTTPClient
+	}
 
-	if c.OnResourceAdded != nil {
-		c.OnResourceAdded(res)
+	if res.Transport == nil {
+		res.Transport = c.opts.Transport
 	}
 
-	res.StartFetcher()
+	if res.TLSConfig == nil {
+		res.TLSConfig = c.opts.TLSConfig
+	}
+
+	res.tracer = c.opts.Tracer
+	res.fetchSem = c.fetchSem
+	res.prepareSnapshot = c.prepareSnapshot
+	if f, ok := res.Fetcher.(*CompositeFetcher); ok {
+		f.cacher = c
+	}
+
+	res.onUpdateEvents = append(res.onUpdateEvents, onUpdate, c.storeEntry, c.purge, c.notifyWebhooks, c.refreshComposites, c.resourceUpdated)
+	// Content loaded from the store was already stored, purged and notified by the leader
+	res.onFollowEvents = append(res.onFollowEvents, onUpdate, c.refreshComposites, c.resourceUpdated)
+	res.onError = func(res *Resource, err error) {
+		c.opts.Logger.Warn("fetch failed", F("alias", res.Alias), F("url", res.URL), F("failures", res.failureCount()), F("error", err))
+
+		if c.OnResourceError != nil {
+			c.OnResourceError(res, err)
+		}
+	}
+	res.onFetchError = c.OnFetchError
+	res.onFetchSuccess = c.OnFetchSuccess
+	res.onPause = c.OnResourcePaused
+	res.onUnhealthy = c.OnResourceUnhealthy
+	res.onRecovered = c.OnResourceRecovered
+	res.onGone = func(res *Resource) {
+		c.RemoveResource(res.Alias)
+	}
+}
+
+// ReplaceResources atomically swaps the whole set of resources. Resources whose definition is
+// unchanged are kept along with their cached content.
+func (c *ResourceCacher) ReplaceResources(resources []*Resource, onUpdate ResourceEvent) error {
+	next := make(Resources, len(resources))
+	for _, res := range resources {
+		if err := c.validateResource(res); err != nil {
+			return fmt.Errorf("resource %s: %w", res.Alias, err)
+		}
+
+		if _, ok := next[res.Alias]; ok {
+			return fmt.Errorf("resource %s: %w", res.Alias, ErrDuplicateResource)
+		}
+
+		next[res.Alias] = res
+	}
+
+	c.resourcesMu.Lock()
+	c.mu.RLock()
+	current := c.resources
+	c.mu.RUnlock()
+
+	// Warm the new resources up before swapping so they are served right away
+	var started, added []*Resource
+	for alias, res := range next {
+		if old, ok := current[alias]; ok && sameDefinition(old, res) {
+			next[alias] = old
+			continue
+		}
+
+		c.prepareResource(res, onUpdate)
+		c.warmStart(res)
+		started = append(started, res)
+
+		if _, ok := current[alias]; !ok {
+			added = append(added, res)
+		}
+	}
+
+	var replaced, removed []*Resource
+	for alias, old := range current {
+		if res, ok := next[alias]; !ok {
+			removed = append(removed, old)
+		} else if res != old {
+			replaced = append(replaced, old)
+		}
+	}
+
+	c.mu.Lock()
+	c.resources = next
+	c.mu.Unlock()
+	c.resourcesMu.Unlock()
+
+	for _, res := range added {
+		c.resourceAdded(res)
+	}
+
+	for _, res := range started {
+		c.startFetcher(res)
+	}
+
+	for _, old := range replaced {
+		old.StopFetcher()
+	}
+
+	for _, old := range removed {
+		old.StopFetcher()
+		c.resourceRemoved(old)
+		c.deleteEntry(old)
+	}
+
+	return nil
+}
+
+// ReplaceResource swaps the resource of the same alias for res, returning the replaced one.
+// res is warmed up with the stored content before the swap and the alias is never removed,
+// so clients stay connected and keep being served.
+func (c *ResourceCacher) ReplaceResource(res *Resource, onUpdate ResourceEvent) (*Resource, error) {
+	if err := c.validateResource(res); err != nil {
+		return nil, err
+	}
+
+	c.resourcesMu.Lock()
+	old, ok := c.GetResource(res.Alias)
+	if !ok {
+		c.resourcesMu.Unlock()
+		return nil, ErrResourceNotFound
+	}
+
+	c.prepareResource(res, onUpdate)
+	c.warmStart(res)
 
 	c.mu.Lock()
 	c.resources[res.Alias] = res
 	c.mu.Unlock()
+	c.resourcesMu.Unlock()
 
-	return res, nil
+	c.startFetcher(res)
+	old.StopFetcher()
+
+	return old, nil
 }
 
-// RemoveResource removes an existing resource from the resource cacher
+// RemoveResource removes an existing resource from the resource cacher, stopping its fetcher
 func (c *ResourceCacher) RemoveResource(alias string) (*Resource, error) {
+	c.resourcesMu.Lock()
+	c.mu.Lock()
 	res, ok := c.resources[alias]
+	delete(c.resources, alias)
+	c.mu.Unlock()
+	c.resourcesMu.Unlock()
+
 	if !ok {
-		return nil, errors.New("no resource found")
+		return nil, ErrResourceNotFound
 	}
 
-	if c.OnResourceRemoved != nil {
-		c.OnResourceRemoved(res)
+	res.StopFetcher()
+	c.resourceRemoved(res)
+	c.deleteEntry(res)
+
+	return res, nil
+}
+
+// ResumeResource restarts the fetcher of a resource paused after too many failures
+func (c *ResourceCacher) ResumeResource(alias string) (*Resource, error) {
+	res, ok := c.GetResource(alias)
+	if !ok {
+		return nil, ErrResourceNotFound
 	}
 
-	c.mu.Lock()
-	delete(c.resources, alias)
-	c.mu.Unlock()
+	res.stateMu.Lock()
+	res.failures = 0
+	res.stateMu.Unlock()
+
+	c.startFetcher(res)
 
 	return res, nil
 }
 
-// Start autofetching/caching
+// ForceRefresh fetches a resource immediately and waits for the fetch to complete,
+// e.g. once application code knows upstream data changed
+func (c *ResourceCacher) ForceRefresh(alias string) error {
+	return c.ForceRefreshContext(context.Background(), alias)
+}
+
+// ForceRefreshContext is like ForceRefresh, the fetch being aborted when ctx is done
+func (c *ResourceCacher) ForceRefreshContext(ctx context.Context, alias string) error {
+	res, ok := c.GetResource(alias)
+	if !ok {
+		return ErrResourceNotFound
+	}
+
+	return res.refresh(ctx)
+}
+
+// ForceRefreshAsync triggers an immediate fetch of a resource without waiting for it,
+// failures being reported through OnResourceError
+func (c *ResourceCacher) ForceRefreshAsync(alias string) error {
+	res, ok := c.GetResource(alias)
+	if !ok {
+		return ErrResourceNotFound
+	}
+
+	res.inflight.Add(1)
+	go func() {
+		defer res.inflight.Done()
+		res.refresh(context.Background())
+	}()
+
+	return nil
+}
+
+// refresh fetches the resource out of its schedule, tracking the outcome like scheduled fetches.
+// A fetch in progress is waited for, so that the content is fetched after the call.
+func (r *Resource) refresh(ctx context.Context) error {
+	if r.parameterized() {
+		// Variants are fetched again on their next request
+		r.variants.clear()
+		return nil
+	}
+
+	keep, err := r.fetchAndTrack(ctx)
+	if !keep {
+		r.StopFetcher()
+	}
+
+	return err
+}
+
+// fetchAndTrack fetches the resource and tracks the outcome, one fetch running at a time.
+// It reports whether the fetcher should keep running.
+func (r *Resource) fetchAndTrack(ctx context.Context) (bool, error) {
+	r.fetchMu.Lock()
+	defer r.fetchMu.Unlock()
+
+	err := r.fetchWithRetry(ctx)
+	if ctx.Err() != nil || err == ErrCircuitOpen {
+		// Stopped while fetching or upstream given a break
+		return true, err
+	}
+
+	return r.track(err), err
+}
+
+// GetResource returns the resource of an alias
+func (c *ResourceCacher) GetResource(alias string) (*Resource, bool) {
+	c.mu.RLock()
+	res, ok := c.resources[alias]
+	c.mu.RUnlock()
+
+	return res, ok
+}
+
+// ListResources returns all resources sorted by alias
+func (c *ResourceCacher) ListResources() []*Resource {
+	c.mu.RLock()
+	resources := make([]*Resource, 0, len(c.resources))
+	for _, res := range c.resources {
+		resources = append(resources, res)
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].Alias < resources[j].Alias
+	})
+
+	return resources
+}
+
+// Start autofetching/caching, only once elected leader when an Elector is set
 func (c *ResourceCacher) Start() {
-	for _, resource := range c.resources {
-		resource.StartFetcher()
+	if c.opts.Elector != nil {
+		c.campaign()
+	} else {
+		for _, resource := range c.ListResources() {
+			resource.StartFetcher()
+		}
 	}
 
 	if c.OnStarted != nil {
@@ -261,9 +1174,26 @@ func (c *ResourceCacher) Start() {
 	}
 }
 
-// Stop autofetching/caching
+// Run starts autofetching/caching, blocks until ctx is done, then stops all fetchers (and
+// SSE clients of the SSE variants) and waits for in-flight fetches before returning
+func (c *ResourceCacher) Run(ctx context.Context) error {
+	c.Start()
+
+	<-ctx.Done()
+
+	c.Stop()
+	for _, res := range c.ListResources() {
+		res.inflight.Wait()
+	}
+
+	return nil
+}
+
+// Stop autofetching/caching, releasing leadership
 func (c *ResourceCacher) Stop() {
-	for _, resource := range c.resources {
+	c.resign()
+
+	for _, resource := range c.ListResources() {
 		resource.StopFetcher()
 	}
 
@@ -274,58 +1204,233 @@ func (c *ResourceCacher) Stop() {
 
 // ServeHTTP to implement net/http.Handler for ResourceCacher
 func (c *ResourceCacher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	alias, err := getAliasFromRequest(r)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(fmt.Sprintf("%v", err)))
+	if c.opts.Tracer != nil {
+		c.serveTraced(w, r)
 		return
 	}
 
-	resource, ok := c.resources[alias]
+	c.serveHTTP(w, r)
+}
+
+func (c *ResourceCacher) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	resource, ok := c.resolveRequest(w, r)
 	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Invalid alias"))
 		return
 	}
 
+	if isPreflight(r) {
+		writePreflight(w, r, c.corsFor(resource))
+		return
+	}
+
+	if from := r.URL.Query().Get("from"); from != "" {
+		c.serveDiff(w, r, resource, from)
+		return
+	}
+
+	snapshot, status := c.requestSnapshot(w, r, resource)
+	if snapshot == nil {
+		c.writeError(w, status, http.StatusText(status))
+		return
+	}
+
+	c.writeSnapshot(w, r, resource, snapshot)
+}
+
+// writeSnapshot answers a request with a snapshot, in the encoding negotiated with the client
+func (c *ResourceCacher) writeSnapshot(w http.ResponseWriter, r *http.Request, resource *Resource, snapshot *Snapshot) {
+	content, etag := snapshot.Content, snapshot.Hash
+	// Content which could not be encoded in the preferred encoding is served in another one
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), snapshot.encodings(c.opts.Encodings))
+	if encoding != "" {
+		content, etag = snapshot.encoded[encoding], snapshot.Hash+"-"+encoding
+	}
+
+	if notModified(r, snapshot, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	c.writeResponseHeaders(w, r, resource, snapshot, encoding)
+
+	w.WriteHeader(snapshot.StatusCode)
+	w.Write(content)
+}
+
+// requestSnapshot returns the snapshot answering a request: a past version, the variant
+// matching its query and language, or the representation matching its Accept header
+func (c *ResourceCacher) requestSnapshot(w http.ResponseWriter, r *http.Request, res *Resource) (*Snapshot, int) {
+	if version := r.URL.Query().Get("version"); version != "" {
+		if s, ok := res.Version(version); ok {
+			return s, http.StatusOK
+		}
+		return nil, http.StatusNotFound
+	}
+
+	snapshot, status := res.Snapshot(), http.StatusOK
+	if res.parameterized() {
+		if snapshot, status = c.variantSnapshot(r.Context(), r, res); snapshot == nil {
+			return nil, status
+		}
+	}
+
+	if len(res.Languages) != 0 {
+		w.Header().Add("Vary", "Accept-Language")
+	}
+
+	if len(res.Representations) != 0 {
+		w.Header().Add("Vary", "Accept")
+		return c.representationSnapshot(r, res, snapshot)
+	}
+
+	return snapshot, status
+}
+
+// allowedMethods lists the request methods answered by the cacher handlers
+const allowedMethods = "GET, HEAD, OPTIONS"
+
+// resolveRequest checks the method, alias and origin of a request, writing the
+// error response and returning false when the request cannot be served
+func (c *ResourceCacher) resolveRequest(w http.ResponseWriter, r *http.Request) (*Resource, bool) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		w.Header().Set("Allow", allowedMethods)
+		c.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return nil, false
+	}
+
+	alias, err := c.aliasFromRequest(r)
+	if err != nil {
+		c.opts.Logger.Debug("invalid request", F("path", r.URL.Path), F("error", err))
+		c.writeError(w, http.StatusBadRequest, fmt.Sprintf("%v", err))
+		return nil, false
+	}
+
+	resource, ok := c.GetResource(alias)
+	if !ok {
+		c.opts.Logger.Debug("unknown alias", F("alias", alias))
+		c.writeError(w, http.StatusNotFound, "Invalid alias")
+		return nil, false
+	}
+
 	origin := r.Header.Get("Origin")
 	if !resource.IsOriginAllowed(origin) {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("Invalid Origin"))
-		return
+		c.rejectOrigin(w, r, resource, origin)
+		return nil, false
 	}
 
-	if match := r.Header.Get("If-None-Match"); match != "" {
-		if resource.Hash == match {
-			w.WriteHeader(http.StatusNotModified)
-			return
+	// Preflight requests never carry credentials
+	if !isPreflight(r) {
+		if err := c.authenticate(r, resource); err != nil {
+			c.rejectUnauthenticated(w, resource, err)
+			return nil, false
+		}
+
+		if !c.authorize(w, r, resource) {
+			return nil, false
 		}
 	}
 
-	writeCommonHeaders(w, r)
+	return resource, true
+}
+
+// writeError answers with an error status, using ErrorHandler when set
+func (c *ResourceCacher) writeError(w http.ResponseWriter, status int, msg string) {
+	if c.opts.ErrorHandler != nil {
+		c.opts.ErrorHandler(w, status)
+		return
+	}
+
+	w.WriteHeader(status)
+	w.Write([]byte(msg))
+}
+
+// Handler returns an http.Handler serving only the exposed aliases, or all of them when none given
+func (c *ResourceCacher) Handler(exposed ...string) http.Handler {
+	if len(exposed) == 0 {
+		return c
+	}
 
-	resource.WriteHeaders(w)
+	allowed := make(map[string]bool, len(exposed))
+	for _, alias := range exposed {
+		allowed[alias] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if alias, err := c.aliasFromRequest(r); err == nil && !allowed[alias] {
+			c.writeError(w, http.StatusNotFound, "Invalid alias")
+			return
+		}
 
-	w.WriteHeader(resource.StatusCode)
-	w.Write(resource.Content)
+		c.ServeHTTP(w, r)
+	})
 }
 
 func writeCommonHeaders(w http.ResponseWriter, r *http.Request) {
-	w.Header().Add("Vary", "Origin")
-	w.Header().Add("Vary", "Access-Control-Request-Method")
-	w.Header().Add("Vary", "Access-Control-Request-Headers")
+	for _, v := range commonVaryHeaders {
+		w.Header().Add("Vary", v)
+	}
 	if origin := r.Header.Get("Origin"); origin != "" {
 		w.Header().Set("Access-Control-Allow-Origin", origin)
 	}
 }
 
+// aliasFromRequest resolves the alias of a request using AliasFunc when set
+func (c *ResourceCacher) aliasFromRequest(r *http.Request) (string, error) {
+	if c.opts.AliasFunc != nil {
+		return c.opts.AliasFunc(r)
+	}
+
+	return getAliasFromRequest(r)
+}
+
+// getAliasFromRequest reads the alias from the ?alias= query parameter, falling back to the last path segment
 func getAliasFromRequest(r *http.Request) (string, error) {
 	query := r.URL.Query()
 
 	aliases, ok := query["alias"]
 	if !ok {
-		return "", errors.New("Missing alias")
+		return getAliasFromPath(r)
+	}
+
+	if err := validateAlias(aliases[0]); err != nil {
+		return "", err
 	}
 
 	return aliases[0], nil
 }
+
+// getAliasFromPath uses the unescaped last segment of the request path as alias, so that
+// resources can be served as /resources/{alias}
+func getAliasFromPath(r *http.Request) (string, error) {
+	path := r.URL.EscapedPath()
+	segment := path[strings.LastIndex(path, "/")+1:]
+	if segment == "" {
+		return "", ErrMissingAlias
+	}
+
+	alias, err := url.PathUnescape(segment)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidAlias, err)
+	}
+
+	if err := validateAlias(alias); err != nil {
+		return "", err
+	}
+
+	return alias, nil
+}
+
+// validateAlias rejects aliases which would make path based routing ambiguous
+func validateAlias(alias string) error {
+	if alias == "" {
+		return ErrMissingAlias
+	}
+
+	if strings.Contains(alias, "/") || alias == "." || alias == ".." {
+		return fmt.Errorf("%w %q", ErrInvalidAlias, alias)
+	}
+
+	return nil
+}
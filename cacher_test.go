@@ -1,13 +1,22 @@
 package routing_test
 
 import (
+	"context"
+	"crypto/hmac"
 	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -16,12 +25,14 @@ import (
 
 func TestServeHTTP(t *testing.T) {
 	when := time.Now().Format(time.RFC1123)
+	modified := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
 	numRequests := 0
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/get", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Date", when)
+		w.Header().Set("Last-Modified", modified)
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{"status": "ok"}`))
 
@@ -65,6 +76,7 @@ func TestServeHTTP(t *testing.T) {
 					"Content-Length": []string{"16"},
 					"Content-Type":   []string{"application/json"},
 					"Date":           []string{when},
+					"Last-Modified":  []string{modified},
 					"Etag":           []string{fmt.Sprintf("%x", sha1.Sum([]byte(`{"status": "ok"}`)))},
 					"Cache-Control":  []string{fmt.Sprintf("max-age=%d", time.Second/time.Second)},
 					"Vary":           commonVaryHeaders,
@@ -89,6 +101,7 @@ func TestServeHTTP(t *testing.T) {
 					"Content-Length":              []string{"16"},
 					"Content-Type":                []string{"application/json"},
 					"Date":                        []string{when},
+					"Last-Modified":               []string{modified},
 					"Etag":                        []string{fmt.Sprintf("%x", sha1.Sum([]byte(`{"status": "ok"}`)))},
 					"Cache-Control":               []string{fmt.Sprintf("max-age=%d", time.Second/time.Second)},
 					"Access-Control-Allow-Origin": []string{"http://good.origin"},
@@ -121,10 +134,7 @@ func TestServeHTTP(t *testing.T) {
 						return
 					}
 
-					r.Content = newRes
-					r.Hash = fmt.Sprintf("%x", sha1.Sum(r.Content))
-					r.Header.Set("Content-Length", fmt.Sprintf("%d", len(r.Content)))
-					r.Header.Set("Etag", r.Hash)
+					r.SetContent(newRes)
 				},
 			},
 			result: result{
@@ -133,6 +143,7 @@ func TestServeHTTP(t *testing.T) {
 					"Content-Length": []string{"24"},
 					"Content-Type":   []string{"application/json"},
 					"Date":           []string{when},
+					"Last-Modified":  []string{modified},
 					"Etag":           []string{fmt.Sprintf("%x", sha1.Sum([]byte(`{"status":"transformed"}`)))},
 					"Cache-Control":  []string{fmt.Sprintf("max-age=%d", time.Second/time.Second)},
 					"Vary":           commonVaryHeaders,
@@ -182,3 +193,1447 @@ func TestServeHTTP(t *testing.T) {
 		})
 	}
 }
+
+func TestFetcherSkipsOverlappingTicks(t *testing.T) {
+	var inFlight, maxInFlight, numRequests int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+
+		atomic.AddInt32(&numRequests, 1)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(`{"status": "slow"}`))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	_, err := c.AddResource(&routing.Resource{
+		Alias:    "slow",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: 10 * time.Millisecond,
+		Timeout:  time.Second,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	time.Sleep(450 * time.Millisecond)
+
+	if max := atomic.LoadInt32(&maxInFlight); max != 1 {
+		t.Errorf("<fetcher> overlapping fetches. expected 1 obtained %d\n", max)
+	}
+
+	// 1 initial fetch plus at most one fetch per 100ms of upstream latency
+	if n := atomic.LoadInt32(&numRequests); n > 6 {
+		t.Errorf("<fetcher> fetches accumulated. expected at most 6 obtained %d\n", n)
+	}
+}
+
+func TestHandlerExposed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	for _, alias := range []string{"public", "private"} {
+		if _, err := c.AddResource(&routing.Resource{
+			Alias:    alias,
+			Method:   http.MethodGet,
+			URL:      srv.URL,
+			Interval: time.Second,
+		}, nil); err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+	}
+
+	h := c.Handler("public")
+
+	tests := []struct {
+		alias      string
+		statusCode int
+	}{
+		{alias: "public", statusCode: http.StatusOK},
+		{alias: "private", statusCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alias, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?alias="+tt.alias, nil)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if tt.statusCode != w.Code {
+				t.Errorf("<response> statusCode not equal. expected %v obtained %v\n", tt.statusCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestFetcherPausesAfterFailures(t *testing.T) {
+	var numRequests, numErrors, numPaused int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numRequests, 1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	c.OnResourceError = func(res *routing.Resource, err error) {
+		atomic.AddInt32(&numErrors, 1)
+	}
+	c.OnResourcePaused = func(res *routing.Resource) {
+		atomic.AddInt32(&numPaused, 1)
+	}
+
+	_, err := c.AddResource(&routing.Resource{
+		Alias:                  "gone",
+		Method:                 http.MethodGet,
+		URL:                    srv.URL,
+		Interval:               10 * time.Millisecond,
+		MaxConsecutiveFailures: 3,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	paused := atomic.LoadInt32(&numRequests)
+	time.Sleep(100 * time.Millisecond)
+
+	if n := atomic.LoadInt32(&numRequests); n != 3 || n != paused {
+		t.Errorf("<fetcher> upstream hits not equal. expected 3 obtained %d then %d\n", paused, n)
+	}
+
+	if n := atomic.LoadInt32(&numErrors); n != 3 {
+		t.Errorf("<fetcher> errors not equal. expected 3 obtained %d\n", n)
+	}
+
+	if n := atomic.LoadInt32(&numPaused); n != 1 {
+		t.Errorf("<fetcher> paused events not equal. expected 1 obtained %d\n", n)
+	}
+
+	if _, err := c.ResumeResource("gone"); err != nil {
+		t.Fatalf("resume resource error: %s", err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	if n := atomic.LoadInt32(&numRequests); n != 6 {
+		t.Errorf("<fetcher> upstream hits after resume not equal. expected 6 obtained %d\n", n)
+	}
+}
+
+func TestFetcherRemovesOnGone(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	_, err := c.AddResource(&routing.Resource{
+		Alias:        "gone",
+		Method:       http.MethodGet,
+		URL:          srv.URL,
+		Interval:     10 * time.Millisecond,
+		RemoveOnGone: true,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	if _, err := c.RemoveResource("gone"); err == nil {
+		t.Errorf("<cacher> expected resource to be removed on 410 Gone\n")
+	}
+}
+
+func TestServeHTTPDebugHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+
+	c := routing.NewResourceCacher(&routing.Options{DebugHeaders: true})
+	_, err := c.AddResource(&routing.Resource{
+		Alias:    "debug",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: 20 * time.Millisecond,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	serve := func() *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "/?alias=debug", nil)
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	if xc := serve().Header.Get("X-Cache"); xc != "HIT" {
+		t.Errorf("<response> X-Cache not equal. expected HIT obtained %s\n", xc)
+	}
+
+	// Upstream goes away, the previously fetched content is served stale
+	srv.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	r := serve()
+	if xc := r.Header.Get("X-Cache"); xc != "STALE" {
+		t.Errorf("<response> X-Cache not equal. expected STALE obtained %s\n", xc)
+	}
+
+	b, _ := ioutil.ReadAll(r.Body)
+	if string(b) != `{"status": "ok"}` {
+		t.Errorf("<response> stale content not equal. expected %s obtained %s\n", `{"status": "ok"}`, b)
+	}
+}
+
+func TestFetchRequestInterceptors(t *testing.T) {
+	secret := []byte("s3cr3t")
+
+	sign := func(method, path, timestamp string) string {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(method + "\n" + path + "\n" + timestamp))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expected := sign(r.Method, r.URL.Path, r.Header.Get("X-Timestamp"))
+		if !hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Signature"))) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Write([]byte(`{"status": "signed"}`))
+	}))
+	defer srv.Close()
+
+	signer := func(req *http.Request) error {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Timestamp", timestamp)
+		req.Header.Set("X-Signature", sign(req.Method, req.URL.Path, timestamp))
+		return nil
+	}
+
+	res := &routing.Resource{
+		Alias:               "signed",
+		Method:              http.MethodGet,
+		URL:                 srv.URL + "/signed",
+		Interval:            time.Second,
+		RequestInterceptors: []routing.RequestInterceptor{signer},
+	}
+
+	if err := res.Fetch(); err != nil {
+		t.Fatalf("fetch error: %s", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("<resource> statusCode not equal. expected %v obtained %v\n", http.StatusOK, res.StatusCode)
+	}
+
+	if string(res.Content) != `{"status": "signed"}` {
+		t.Errorf("<resource> content not equal. expected %s obtained %s\n", `{"status": "signed"}`, res.Content)
+	}
+
+	errAbort := errors.New("abort")
+	res.RequestInterceptors = append(res.RequestInterceptors, func(req *http.Request) error {
+		return errAbort
+	})
+
+	if err := res.Fetch(); err != errAbort {
+		t.Errorf("<resource> fetch error not equal. expected %v obtained %v\n", errAbort, err)
+	}
+}
+
+func TestFetchInterceptorErrorSkipsFallbacks(t *testing.T) {
+	var hits int32
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte(`{"status": "fallback"}`))
+	}))
+	defer fallback.Close()
+
+	errAbort := errors.New("abort")
+	res := &routing.Resource{
+		Alias:        "intercepted",
+		Method:       http.MethodGet,
+		URL:          fallback.URL + "/primary",
+		FallbackURLs: []string{fallback.URL},
+		RequestInterceptors: []routing.RequestInterceptor{func(req *http.Request) error {
+			if req.URL.Path == "/primary" {
+				return errAbort
+			}
+			return nil
+		}},
+	}
+
+	if err := res.Fetch(); err != errAbort {
+		t.Errorf("<resource> fetch error not equal. expected %v obtained %v\n", errAbort, err)
+	}
+
+	if n := atomic.LoadInt32(&hits); n != 0 {
+		t.Errorf("<upstream> requests not equal. expected 0 obtained %d\n", n)
+	}
+}
+
+func TestFetchFallbackURLs(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer broken.Close()
+
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "fallback"}`))
+	}))
+	defer fallback.Close()
+
+	tests := []struct {
+		name       string
+		res        *routing.Resource
+		fetchedURL string
+		statusCode int
+	}{
+		{
+			name: "primary down",
+			res: &routing.Resource{
+				URL:          down.URL,
+				FallbackURLs: []string{fallback.URL},
+			},
+			fetchedURL: fallback.URL,
+			statusCode: http.StatusOK,
+		},
+		{
+			name: "bad status kept",
+			res: &routing.Resource{
+				URL:          broken.URL,
+				FallbackURLs: []string{fallback.URL},
+			},
+			fetchedURL: broken.URL,
+			statusCode: http.StatusBadGateway,
+		},
+		{
+			name: "bad status falls back",
+			res: &routing.Resource{
+				URL:                 broken.URL,
+				FallbackURLs:        []string{down.URL, fallback.URL},
+				FallbackOnBadStatus: true,
+			},
+			fetchedURL: fallback.URL,
+			statusCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.res.Method = http.MethodGet
+			tt.res.Interval = time.Second
+
+			if err := tt.res.Fetch(); err != nil {
+				t.Fatalf("fetch error: %s", err)
+			}
+
+			if tt.res.FetchedURL != tt.fetchedURL {
+				t.Errorf("<resource> fetched url not equal. expected %s obtained %s\n", tt.fetchedURL, tt.res.FetchedURL)
+			}
+
+			if tt.res.StatusCode != tt.statusCode {
+				t.Errorf("<resource> statusCode not equal. expected %v obtained %v\n", tt.statusCode, tt.res.StatusCode)
+			}
+		})
+	}
+
+	res := &routing.Resource{
+		Method:       http.MethodGet,
+		URL:          down.URL,
+		FallbackURLs: []string{down.URL},
+		Interval:     time.Second,
+	}
+	if err := res.Fetch(); err == nil {
+		t.Errorf("<resource> expected error when all upstreams are down\n")
+	}
+}
+
+func TestReplaceResources(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer srv.Close()
+
+	numHits := func(path string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return hits[path]
+	}
+
+	newResource := func(alias string, interval time.Duration) *routing.Resource {
+		return &routing.Resource{
+			Alias:    alias,
+			Method:   http.MethodGet,
+			URL:      srv.URL + "/" + alias,
+			Interval: interval,
+		}
+	}
+
+	var removed []string
+
+	c := routing.NewResourceCacher(nil)
+	c.OnResourceRemoved = func(res *routing.Resource) {
+		removed = append(removed, res.Alias)
+	}
+
+	if err := c.AddResources([]*routing.Resource{newResource("kept", time.Hour), newResource("dropped", 10*time.Millisecond)}, nil); err != nil {
+		t.Fatalf("add resources error: %s", err)
+	}
+
+	if err := c.ReplaceResources([]*routing.Resource{newResource("kept", time.Hour), newResource("added", time.Hour)}, nil); err != nil {
+		t.Fatalf("replace resources error: %s", err)
+	}
+
+	dropped := numHits("/dropped")
+	time.Sleep(100 * time.Millisecond)
+
+	if n := numHits("/kept"); n != 1 {
+		t.Errorf("<cacher> kept resource fetches not equal. expected 1 obtained %d\n", n)
+	}
+
+	if n := numHits("/added"); n != 1 {
+		t.Errorf("<cacher> added resource fetches not equal. expected 1 obtained %d\n", n)
+	}
+
+	if n := numHits("/dropped"); n != dropped {
+		t.Errorf("<cacher> dropped resource still fetching. expected %d obtained %d\n", dropped, n)
+	}
+
+	if !reflect.DeepEqual(removed, []string{"dropped"}) {
+		t.Errorf("<cacher> removed not equal. expected %v obtained %v\n", []string{"dropped"}, removed)
+	}
+
+	for alias, statusCode := range map[string]int{"kept": http.StatusOK, "added": http.StatusOK, "dropped": http.StatusNotFound} {
+		req := httptest.NewRequest(http.MethodGet, "/?alias="+alias, nil)
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, req)
+
+		if w.Code != statusCode {
+			t.Errorf("<response> %s statusCode not equal. expected %v obtained %v\n", alias, statusCode, w.Code)
+		}
+
+		if statusCode == http.StatusOK && w.Body.String() != "/"+alias {
+			t.Errorf("<response> %s content not equal. expected %s obtained %s\n", alias, "/"+alias, w.Body.String())
+		}
+	}
+
+	if err := c.ReplaceResources([]*routing.Resource{newResource("dup", time.Hour), newResource("dup", time.Hour)}, nil); err == nil {
+		t.Errorf("<cacher> expected error for duplicate aliases\n")
+	}
+}
+
+func TestReplaceResourcesConcurrentAdd(t *testing.T) {
+	newResource := func(alias string, interval time.Duration) *routing.Resource {
+		return &routing.Resource{
+			Alias:    alias,
+			Interval: interval,
+			Fetcher: routing.FetcherFunc(func(ctx context.Context) ([]byte, http.Header, int, error) {
+				time.Sleep(time.Millisecond)
+				return []byte(alias), nil, http.StatusOK, nil
+			}),
+		}
+	}
+
+	c := routing.NewResourceCacher(nil)
+	defer c.Stop()
+
+	for i := 0; i < 50; i++ {
+		i := i
+		alias := fmt.Sprintf("added-%d", i)
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			// A new definition every time, for the resource to be replaced
+			c.ReplaceResources([]*routing.Resource{newResource("kept", time.Hour+time.Duration(i))}, nil)
+		}()
+
+		var res *routing.Resource
+		go func() {
+			defer wg.Done()
+			res, _ = c.AddResource(newResource(alias, time.Hour), nil)
+		}()
+		wg.Wait()
+
+		// A resource added concurrently is either kept or removed, never left fetching unlisted
+		if _, listed := c.GetResource(alias); res != nil && res.Running() != listed {
+			t.Fatalf("<cacher> %s running %v while listed %v\n", alias, res.Running(), listed)
+		}
+	}
+}
+
+func TestServeHTTPAliasValidation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	for _, alias := range []string{"normal", "café au lait"} {
+		if _, err := c.AddResource(&routing.Resource{
+			Alias:    alias,
+			Method:   http.MethodGet,
+			URL:      srv.URL,
+			Interval: time.Second,
+		}, nil); err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+	}
+
+	for _, alias := range []string{"with/slash", ".."} {
+		if _, err := c.AddResource(&routing.Resource{
+			Alias:    alias,
+			Method:   http.MethodGet,
+			URL:      srv.URL,
+			Interval: time.Second,
+		}, nil); err == nil {
+			t.Errorf("<cacher> expected alias %q to be rejected\n", alias)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		target     string
+		statusCode int
+	}{
+		{name: "normal path", target: "/resources/normal", statusCode: http.StatusOK},
+		{name: "normal query", target: "/resources/?alias=normal", statusCode: http.StatusOK},
+		{name: "escaped path", target: "/resources/caf%C3%A9%20au%20lait", statusCode: http.StatusOK},
+		{name: "escaped query", target: "/resources/?alias=caf%C3%A9+au+lait", statusCode: http.StatusOK},
+		{name: "slash path", target: "/resources/with%2Fslash", statusCode: http.StatusBadRequest},
+		{name: "slash query", target: "/resources/?alias=with%2Fslash", statusCode: http.StatusBadRequest},
+		{name: "empty", target: "/resources/", statusCode: http.StatusBadRequest},
+		{name: "query over path", target: "/resources/unknown?alias=normal", statusCode: http.StatusOK},
+		{name: "unknown path", target: "/resources/unknown", statusCode: http.StatusNotFound},
+		{name: "nested path", target: "/cdn/v1/resources/normal", statusCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			w := httptest.NewRecorder()
+			c.ServeHTTP(w, req)
+
+			if w.Code != tt.statusCode {
+				t.Errorf("<response> statusCode not equal. expected %v obtained %v (%s)\n", tt.statusCode, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// legacyHeaders computes the response headers the way they were written per request before precomputation
+func legacyHeaders(res *routing.Resource, origin string) http.Header {
+	w := httptest.NewRecorder()
+	for _, v := range []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"} {
+		w.Header().Add("Vary", v)
+	}
+	if origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	res.WriteHeaders(w)
+	return w.Header()
+}
+
+func newHeadersFixture(tb testing.TB) (*routing.ResourceCacher, *routing.Resource, func()) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("X-Multi", "first")
+		w.Header().Add("X-Multi", "second")
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+
+	c := routing.NewResourceCacher(nil)
+	res, err := c.AddResource(&routing.Resource{
+		Alias:    "headers",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Hour,
+	}, nil)
+	if err != nil {
+		tb.Fatalf("add resource error: %s", err)
+	}
+
+	return c, res, srv.Close
+}
+
+func TestServeHTTPPrecomputedHeaders(t *testing.T) {
+	c, res, done := newHeadersFixture(t)
+	defer done()
+
+	for _, origin := range []string{"", "http://good.origin"} {
+		req := httptest.NewRequest(http.MethodGet, "/?alias=headers", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, req)
+
+		expected := legacyHeaders(res, origin)
+		if !reflect.DeepEqual(expected, w.Header()) {
+			t.Errorf("<response> header not equal. expected %v obtained %v\n", expected, w.Header())
+		}
+
+		// Mutating a served header must not leak into the next response
+		w.Header().Add("Vary", "Cookie")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/?alias=headers", nil)
+	req.Header.Set("If-None-Match", res.Hash)
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotModified || len(w.Header()) != 0 {
+		t.Errorf("<response> expected bare 304 obtained %v with %v\n", w.Code, w.Header())
+	}
+}
+
+func TestFetchContext(t *testing.T) {
+	var numRequests int32
+	aborted := make(chan struct{}, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&numRequests, 1) == 1 {
+			w.Write([]byte(`{"status": "ok"}`))
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			aborted <- struct{}{}
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	res := &routing.Resource{
+		Alias:    "slow",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: 10 * time.Millisecond,
+	}
+
+	res.StartFetcher()
+	time.Sleep(50 * time.Millisecond)
+
+	stopped := time.Now()
+	res.StopFetcher()
+
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Fatalf("<fetcher> in-flight fetch not aborted by StopFetcher\n")
+	}
+
+	if elapsed := time.Since(stopped); elapsed > 500*time.Millisecond {
+		t.Errorf("<fetcher> abort took too long: %v\n", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := res.FetchContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("<resource> fetch error not equal. expected %v obtained %v\n", context.DeadlineExceeded, err)
+	}
+
+	if string(res.Content) != `{"status": "ok"}` {
+		t.Errorf("<resource> content not kept. expected %s obtained %s\n", `{"status": "ok"}`, res.Content)
+	}
+}
+
+func TestFetcherRetriesWithBackoff(t *testing.T) {
+	var numRequests int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&numRequests, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	var fetchErrors []string
+	res := &routing.Resource{
+		Alias:        "flaky",
+		Method:       http.MethodGet,
+		URL:          srv.URL,
+		Interval:     time.Hour,
+		MaxRetries:   3,
+		RetryBackoff: 20 * time.Millisecond,
+		OnFetchError: func(res *routing.Resource, err error) {
+			fetchErrors = append(fetchErrors, err.Error())
+		},
+	}
+
+	started := time.Now()
+	res.StartFetcher()
+	defer res.StopFetcher()
+
+	if elapsed := time.Since(started); elapsed < 60*time.Millisecond {
+		t.Errorf("<fetcher> retries did not back off. expected at least %v obtained %v\n", 60*time.Millisecond, elapsed)
+	}
+
+	if n := atomic.LoadInt32(&numRequests); n != 3 {
+		t.Errorf("<fetcher> upstream hits not equal. expected 3 obtained %d\n", n)
+	}
+
+	expected := []string{"unexpected status 503", "unexpected status 503"}
+	if !reflect.DeepEqual(expected, fetchErrors) {
+		t.Errorf("<fetcher> fetch errors not equal. expected %v obtained %v\n", expected, fetchErrors)
+	}
+
+	if res.StatusCode != http.StatusOK || string(res.Content) != `{"status": "ok"}` {
+		t.Errorf("<resource> content not equal. expected %s obtained %d %s\n", `{"status": "ok"}`, res.StatusCode, res.Content)
+	}
+}
+
+func TestForceRefresh(t *testing.T) {
+	var numRequests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&numRequests, 1)
+		fmt.Fprintf(w, `{"version": %d}`, n)
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	res, err := c.AddResource(&routing.Resource{
+		Alias:    "refresh",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Hour,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+	defer c.Stop()
+
+	if err := c.ForceRefresh("refresh"); err != nil {
+		t.Fatalf("force refresh error: %s", err)
+	}
+
+	if content := string(res.Content); content != `{"version": 2}` {
+		t.Errorf("<refresh> content not equal. expected %v obtained %v\n", `{"version": 2}`, content)
+	}
+
+	if err := c.ForceRefreshAsync("refresh"); err != nil {
+		t.Fatalf("force refresh async error: %s", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	if n := atomic.LoadInt32(&numRequests); n != 3 {
+		t.Errorf("<refresh> upstream hits not equal. expected 3 obtained %d\n", n)
+	}
+
+	if err := c.ForceRefresh("unknown"); err == nil {
+		t.Errorf("<refresh> expected error for unknown alias\n")
+	}
+}
+
+func TestForceRefreshSerialized(t *testing.T) {
+	var running, overlaps int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	if _, err := c.AddResource(&routing.Resource{
+		Alias:    "refresh",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Hour,
+	}, nil); err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+	defer c.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		c.ForceRefreshAsync("refresh")
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.ForceRefresh("refresh")
+		}()
+	}
+	wg.Wait()
+	c.ForceRefresh("refresh")
+
+	if n := atomic.LoadInt32(&overlaps); n != 0 {
+		t.Errorf("<refresh> overlapping fetches not equal. expected 0 obtained %d\n", n)
+	}
+}
+
+func TestFetchLifecycleCallbacks(t *testing.T) {
+	var fail int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	var numSuccess, numErrors, numResourceSuccess int32
+	c := routing.NewResourceCacher(nil)
+	c.OnFetchSuccess = func(res *routing.Resource) {
+		atomic.AddInt32(&numSuccess, 1)
+	}
+	c.OnFetchError = func(res *routing.Resource, err error) {
+		atomic.AddInt32(&numErrors, 1)
+	}
+
+	_, err := c.AddResource(&routing.Resource{
+		Alias:    "lifecycle",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Hour,
+		OnFetchSuccess: func(res *routing.Resource) {
+			atomic.AddInt32(&numResourceSuccess, 1)
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+	defer c.Stop()
+
+	c.ForceRefresh("lifecycle")
+
+	atomic.StoreInt32(&fail, 1)
+	c.ForceRefresh("lifecycle")
+
+	if n := atomic.LoadInt32(&numSuccess); n != 2 {
+		t.Errorf("<callbacks> success not equal. expected 2 obtained %d\n", n)
+	}
+
+	if n := atomic.LoadInt32(&numResourceSuccess); n != 2 {
+		t.Errorf("<callbacks> resource success not equal. expected 2 obtained %d\n", n)
+	}
+
+	if n := atomic.LoadInt32(&numErrors); n != 1 {
+		t.Errorf("<callbacks> errors not equal. expected 1 obtained %d\n", n)
+	}
+}
+
+func TestUnhealthyAndRecovered(t *testing.T) {
+	var fail int32 = 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	var numUnhealthy, numRecovered, lastCount int32
+	c := routing.NewResourceCacher(nil)
+	c.OnResourceUnhealthy = func(res *routing.Resource, failures int, err error) {
+		atomic.AddInt32(&numUnhealthy, 1)
+		atomic.StoreInt32(&lastCount, int32(failures))
+	}
+	c.OnResourceRecovered = func(res *routing.Resource) {
+		atomic.AddInt32(&numRecovered, 1)
+	}
+
+	_, err := c.AddResource(&routing.Resource{
+		Alias:              "flaky",
+		Method:             http.MethodGet,
+		URL:                srv.URL,
+		Interval:           time.Hour,
+		UnhealthyThreshold: 3,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+	defer c.Stop()
+
+	for i := 0; i < 3; i++ {
+		c.ForceRefresh("flaky")
+	}
+
+	if n := atomic.LoadInt32(&numUnhealthy); n != 1 {
+		t.Errorf("<health> unhealthy events not equal. expected 1 obtained %d\n", n)
+	}
+
+	if n := atomic.LoadInt32(&lastCount); n != 3 {
+		t.Errorf("<health> failures not equal. expected 3 obtained %d\n", n)
+	}
+
+	atomic.StoreInt32(&fail, 0)
+	c.ForceRefresh("flaky")
+	c.ForceRefresh("flaky")
+
+	if n := atomic.LoadInt32(&numRecovered); n != 1 {
+		t.Errorf("<health> recovered events not equal. expected 1 obtained %d\n", n)
+	}
+}
+
+func TestMaxConcurrentFetches(t *testing.T) {
+	var current, peak int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&current, 1)
+		defer atomic.AddInt32(&current, -1)
+
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(&routing.Options{MaxConcurrentFetches: 2})
+	defer c.Stop()
+
+	for i := 0; i < 6; i++ {
+		_, err := c.AddResource(&routing.Resource{
+			Alias:    fmt.Sprintf("res%d", i),
+			Method:   http.MethodGet,
+			URL:      srv.URL,
+			Interval: time.Hour,
+		}, nil)
+		if err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c.ForceRefresh(fmt.Sprintf("res%d", i))
+		}(i)
+	}
+	wg.Wait()
+
+	if p := atomic.LoadInt32(&peak); p != 2 {
+		t.Errorf("<fetcher> concurrent fetches not equal. expected 2 obtained %d\n", p)
+	}
+}
+
+func TestGetAndListResources(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	defer c.Stop()
+
+	for _, alias := range []string{"second", "first"} {
+		_, err := c.AddResource(&routing.Resource{
+			Alias:    alias,
+			Method:   http.MethodGet,
+			URL:      srv.URL,
+			Interval: time.Hour,
+		}, nil)
+		if err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+	}
+
+	if res, ok := c.GetResource("first"); !ok || res.Alias != "first" {
+		t.Errorf("<cacher> expected resource first\n")
+	}
+
+	if _, ok := c.GetResource("unknown"); ok {
+		t.Errorf("<cacher> expected no resource unknown\n")
+	}
+
+	resources := c.ListResources()
+	if len(resources) != 2 || resources[0].Alias != "first" || resources[1].Alias != "second" {
+		t.Errorf("<cacher> resources not equal. expected [first second] obtained %v\n", resources)
+	}
+
+	// Serving while resources are added and removed must not race
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			alias := fmt.Sprintf("res%d", i)
+			c.AddResource(&routing.Resource{Alias: alias, Method: http.MethodGet, URL: srv.URL, Interval: time.Hour}, nil)
+			c.RemoveResource(alias)
+		}(i)
+		go func() {
+			defer wg.Done()
+			c.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/?alias=first", nil))
+		}()
+	}
+	wg.Wait()
+}
+
+func TestServeHTTPConsistentSnapshot(t *testing.T) {
+	var version int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"version": %d}`, atomic.AddInt32(&version, 1))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	_, err := c.AddResource(&routing.Resource{
+		Alias:    "snapshot",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Hour,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+	defer c.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			c.ForceRefresh("snapshot")
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?alias=snapshot", nil))
+
+		if etag, hash := w.Header().Get("Etag"), fmt.Sprintf("%x", sha1.Sum(w.Body.Bytes())); etag != hash {
+			t.Fatalf("<response> etag not equal. expected %v obtained %v\n", hash, etag)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	var fetching int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&fetching, 1) == 1 {
+			w.Write([]byte(`{"status": "ok"}`))
+			return
+		}
+
+		// Hang until the fetch is aborted
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	_, err := c.AddResource(&routing.Resource{
+		Alias:    "run",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: 10 * time.Millisecond,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	var started, stopped int32
+	c.OnStarted = func() { atomic.AddInt32(&started, 1) }
+	c.OnStopped = func() { atomic.AddInt32(&stopped, 1) }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := c.Run(ctx); err != nil {
+		t.Errorf("<run> unexpected error %v\n", err)
+	}
+
+	if atomic.LoadInt32(&started) != 1 || atomic.LoadInt32(&stopped) != 1 {
+		t.Errorf("<run> expected started and stopped events\n")
+	}
+
+	res, _ := c.GetResource("run")
+	if status := res.Status(); status.Running {
+		t.Errorf("<run> expected fetcher to be stopped\n")
+	}
+}
+
+func TestStartStopFetcherInAnyOrder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	res := &routing.Resource{
+		Alias:    "lifecycle",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Hour,
+	}
+
+	// Never started
+	res.StopFetcher()
+	if res.Running() {
+		t.Errorf("<fetcher> expected not running before start\n")
+	}
+
+	res.StartFetcher()
+	res.StartFetcher()
+	if !res.Running() {
+		t.Errorf("<fetcher> expected running after start\n")
+	}
+
+	res.StopFetcher()
+	res.StopFetcher()
+	if res.Running() {
+		t.Errorf("<fetcher> expected not running after stop\n")
+	}
+
+	res.StartFetcher()
+	if !res.Running() {
+		t.Errorf("<fetcher> expected running after restart\n")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res.StopFetcher()
+		}()
+	}
+	wg.Wait()
+
+	if res.Running() {
+		t.Errorf("<fetcher> expected not running after concurrent stops\n")
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher()
+	defer c.Stop()
+
+	tests := []struct {
+		name     string
+		res      *routing.Resource
+		expected error
+	}{
+		{"missing alias", &routing.Resource{Method: http.MethodGet, URL: srv.URL, Interval: time.Hour}, routing.ErrMissingAlias},
+		{"invalid alias", &routing.Resource{Alias: "a/b", Method: http.MethodGet, URL: srv.URL, Interval: time.Hour}, routing.ErrInvalidAlias},
+		{"missing method", &routing.Resource{Alias: "first", URL: srv.URL, Interval: time.Hour}, routing.ErrMissingMethod},
+		{"missing url", &routing.Resource{Alias: "first", Method: http.MethodGet, Interval: time.Hour}, routing.ErrMissingURL},
+		{"invalid interval", &routing.Resource{Alias: "first", Method: http.MethodGet, URL: srv.URL}, routing.ErrInvalidInterval},
+		{"valid", &routing.Resource{Alias: "first", Method: http.MethodGet, URL: srv.URL, Interval: time.Hour}, nil},
+		{"duplicate", &routing.Resource{Alias: "first", Method: http.MethodGet, URL: srv.URL, Interval: time.Hour}, routing.ErrDuplicateResource},
+	}
+
+	for _, test := range tests {
+		if _, err := c.AddResource(test.res, nil); !errors.Is(err, test.expected) {
+			t.Errorf("<cacher> %s error not equal. expected %v obtained %v\n", test.name, test.expected, err)
+		}
+	}
+
+	if _, err := c.RemoveResource("unknown"); !errors.Is(err, routing.ErrResourceNotFound) {
+		t.Errorf("<cacher> remove error not equal. expected %v obtained %v\n", routing.ErrResourceNotFound, err)
+	}
+}
+
+func TestRemoveResourceStopsFetcher(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	res, err := c.AddResource(&routing.Resource{
+		Alias:    "removed",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: 5 * time.Millisecond,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	if _, err := c.RemoveResource("removed"); err != nil {
+		t.Fatalf("remove resource error: %s", err)
+	}
+	if res.Running() {
+		t.Errorf("<resource> fetcher still running after removal\n")
+	}
+
+	// Let a fetch aborted by the removal settle
+	time.Sleep(10 * time.Millisecond)
+	removed := atomic.LoadInt32(&hits)
+	time.Sleep(30 * time.Millisecond)
+	if obtained := atomic.LoadInt32(&hits); obtained != removed {
+		t.Errorf("<upstream> hits not equal. expected %v obtained %v\n", removed, obtained)
+	}
+}
+
+func TestAliasFunc(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(routing.WithAliasFunc(func(r *http.Request) (string, error) {
+		host := strings.Split(r.Host, ".")[0]
+		if host == "" {
+			return "", routing.ErrMissingAlias
+		}
+		return host, nil
+	}))
+
+	if _, err := c.AddResource(&routing.Resource{
+		Alias:    "tenant",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Hour,
+	}, nil); err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	tests := []struct {
+		name       string
+		host       string
+		target     string
+		statusCode int
+	}{
+		{name: "host", host: "tenant.example.com", target: "/", statusCode: http.StatusOK},
+		{name: "query ignored", host: "other.example.com", target: "/?alias=tenant", statusCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Host = tt.host
+			w := httptest.NewRecorder()
+			c.ServeHTTP(w, req)
+
+			if w.Code != tt.statusCode {
+				t.Errorf("<response> statusCode not equal. expected %v obtained %v\n", tt.statusCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestServeHTTPErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	var handled []int
+	for _, c := range []*routing.ResourceCacher{
+		routing.NewResourceCacher(nil),
+		routing.NewResourceCacher(routing.WithErrorHandler(func(w http.ResponseWriter, status int) {
+			handled = append(handled, status)
+			w.WriteHeader(status)
+			w.Write([]byte("custom"))
+		})),
+	} {
+		if _, err := c.AddResource(&routing.Resource{
+			Alias:          "errors",
+			Method:         http.MethodGet,
+			URL:            srv.URL,
+			Interval:       time.Hour,
+			AllowedOrigins: []string{"https://allowed.example.com"},
+		}, nil); err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+
+		tests := []struct {
+			name       string
+			method     string
+			target     string
+			origin     string
+			statusCode int
+		}{
+			{name: "ok", method: http.MethodGet, target: "/resources/errors", origin: "https://allowed.example.com", statusCode: http.StatusOK},
+			{name: "head", method: http.MethodHead, target: "/resources/errors", origin: "https://allowed.example.com", statusCode: http.StatusOK},
+			{name: "method", method: http.MethodPost, target: "/resources/errors", statusCode: http.StatusMethodNotAllowed},
+			{name: "missing", method: http.MethodGet, target: "/resources/", statusCode: http.StatusBadRequest},
+			{name: "unknown", method: http.MethodGet, target: "/resources/unknown", statusCode: http.StatusNotFound},
+			{name: "origin", method: http.MethodGet, target: "/resources/errors", origin: "https://other.example.com", statusCode: http.StatusForbidden},
+		}
+
+		for _, tt := range tests {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			c.ServeHTTP(w, req)
+
+			if w.Code != tt.statusCode {
+				t.Errorf("<%s> statusCode not equal. expected %v obtained %v\n", tt.name, tt.statusCode, w.Code)
+			}
+
+			if tt.statusCode == http.StatusMethodNotAllowed && w.Header().Get("Allow") != "GET, HEAD, OPTIONS" {
+				t.Errorf("<%s> Allow not equal. expected %v obtained %v\n", tt.name, "GET, HEAD, OPTIONS", w.Header().Get("Allow"))
+			}
+		}
+	}
+
+	expected := []int{http.StatusMethodNotAllowed, http.StatusBadRequest, http.StatusNotFound, http.StatusForbidden}
+	if !reflect.DeepEqual(handled, expected) {
+		t.Errorf("<response> handled errors not equal. expected %v obtained %v\n", expected, handled)
+	}
+}
+
+func TestServeHTTPIfModifiedSince(t *testing.T) {
+	modified := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	res, err := c.AddResource(&routing.Resource{
+		Alias:    "modified",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Hour,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	if !res.LastModified.Equal(modified) {
+		t.Errorf("<resource> LastModified not equal. expected %v obtained %v\n", modified, res.LastModified)
+	}
+
+	tests := []struct {
+		name       string
+		since      time.Time
+		match      string
+		statusCode int
+	}{
+		{name: "same", since: modified, statusCode: http.StatusNotModified},
+		{name: "later", since: modified.Add(time.Minute), statusCode: http.StatusNotModified},
+		{name: "earlier", since: modified.Add(-time.Minute), statusCode: http.StatusOK},
+		{name: "etag precedence", since: modified, match: `"other"`, statusCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/resources/modified", nil)
+		req.Header.Set("If-Modified-Since", tt.since.Format(http.TimeFormat))
+		if tt.match != "" {
+			req.Header.Set("If-None-Match", tt.match)
+		}
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, req)
+
+		if w.Code != tt.statusCode {
+			t.Errorf("<%s> statusCode not equal. expected %v obtained %v\n", tt.name, tt.statusCode, w.Code)
+		}
+
+		if w.Code == http.StatusOK && w.Header().Get("Last-Modified") != modified.Format(http.TimeFormat) {
+			t.Errorf("<%s> Last-Modified not equal. expected %v obtained %v\n", tt.name, modified.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+		}
+	}
+}
+
+func TestChangeDetection(t *testing.T) {
+	var version int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strconv.Itoa(int(atomic.LoadInt32(&version)))))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		opts     []routing.Option
+		expected int32
+	}{
+		{"changes only", nil, 2},
+		{"notify unchanged", []routing.Option{routing.WithNotifyUnchanged()}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&version, 0)
+
+			var updates int32
+			c := routing.NewResourceCacher(tt.opts...)
+			c.OnResourceUpdated = func(res *routing.Resource) {
+				atomic.AddInt32(&updates, 1)
+			}
+
+			res, err := c.AddResource(&routing.Resource{
+				Alias:    "counter",
+				Method:   http.MethodGet,
+				URL:      srv.URL,
+				Interval: time.Hour,
+			}, nil)
+			if err != nil {
+				t.Fatalf("add resource error: %s", err)
+			}
+			res.StopFetcher()
+
+			// Unchanged refetch after the initial fetch, then new content
+			for _, v := range []int32{0, 1} {
+				atomic.StoreInt32(&version, v)
+				before := time.Now()
+				if err := res.Fetch(); err != nil {
+					t.Fatalf("fetch error: %s", err)
+				}
+
+				if changed := res.ChangedAt.After(before); changed != res.Changed() {
+					t.Errorf("<resource> ChangedAt not consistent. changed %v obtained %v\n", res.Changed(), res.ChangedAt)
+				}
+			}
+
+			if res.OldHash == "" || res.OldHash == res.Hash {
+				t.Errorf("<resource> OldHash not tracked. hash %v obtained %v\n", res.Hash, res.OldHash)
+			}
+
+			if u := atomic.LoadInt32(&updates); u != tt.expected {
+				t.Errorf("<cacher> updates not equal. expected %v obtained %v\n", tt.expected, u)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// defaultCompositeInterval rebuilds composite resources periodically in case a member update was missed
+const defaultCompositeInterval = time.Minute
+
+// CompositeFetcher builds a JSON document keyed by alias from the content of member resources
+// of the same cacher, JSON content being embedded as is and other content as a string
+type CompositeFetcher struct {
+	Members []string
+
+	cacher *ResourceCacher
+}
+
+// NewCompositeResource returns a resource merging the content of its members into one JSON
+// document, rebuilt whenever the content of a member changes
+func NewCompositeResource(alias string, members ...string) *Resource {
+	return &Resource{
+		Alias:    alias,
+		Interval: defaultCompositeInterval,
+		Fetcher:  &CompositeFetcher{Members: members},
+	}
+}
+
+// Fetch implements Fetcher
+func (f *CompositeFetcher) Fetch(ctx context.Context) ([]byte, http.Header, int, error) {
+	doc := make(map[string]json.RawMessage, len(f.Members))
+	for _, alias := range f.Members {
+		doc[alias] = json.RawMessage("null")
+
+		if f.cacher == nil {
+			continue
+		}
+
+		res, ok := f.cacher.GetResource(alias)
+		if !ok {
+			continue
+		}
+
+		s := res.Snapshot()
+		switch {
+		case s.Hash == "":
+		case json.Valid(s.Content):
+			doc[alias] = s.Content
+		default:
+			b, err := json.Marshal(string(s.Content))
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			doc[alias] = b
+		}
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return b, http.Header{"Content-Type": {"application/json"}}, http.StatusOK, nil
+}
+
+// has tells whether alias is a member
+func (f *CompositeFetcher) has(alias string) bool {
+	for _, member := range f.Members {
+		if member == alias {
+			return true
+		}
+	}
+
+	return false
+}
+
+// refreshComposites rebuilds the composite resources holding a resource whose content changed
+func (c *ResourceCacher) refreshComposites(res *Resource) {
+	if !res.Changed() {
+		return
+	}
+
+	for _, composite := range c.ListResources() {
+		if f, ok := composite.Fetcher.(*CompositeFetcher); ok && f.has(res.Alias) {
+			c.ForceRefreshAsync(composite.Alias)
+		}
+	}
+}
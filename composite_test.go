@@ -0,0 +1,69 @@
+package routing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestCompositeResource(t *testing.T) {
+	var version int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/weather":
+			if atomic.LoadInt32(&version) == 0 {
+				w.Write([]byte(`{"temp": 20}`))
+			} else {
+				w.Write([]byte(`{"temp": 25}`))
+			}
+		case "/motd":
+			w.Write([]byte(`hello "world"`))
+		}
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	for _, alias := range []string{"weather", "motd"} {
+		if _, err := c.AddResource(&routing.Resource{
+			Alias:    alias,
+			Method:   http.MethodGet,
+			URL:      srv.URL + "/" + alias,
+			Interval: time.Hour,
+		}, nil); err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+	}
+
+	composite, err := c.AddResource(routing.NewCompositeResource("home", "weather", "motd", "missing"), nil)
+	if err != nil {
+		t.Fatalf("add composite error: %s", err)
+	}
+
+	expected := `{"missing":null,"motd":"hello \"world\"","weather":{"temp":20}}`
+	if string(composite.Snapshot().Content) != expected {
+		t.Errorf("<composite> content not equal. expected %s obtained %s\n", expected, composite.Snapshot().Content)
+	}
+
+	if ct := composite.Snapshot().Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("<composite> Content-Type not equal. expected %v obtained %v\n", "application/json", ct)
+	}
+
+	atomic.StoreInt32(&version, 1)
+	if err := c.ForceRefresh("weather"); err != nil {
+		t.Fatalf("refresh error: %s", err)
+	}
+
+	expected = `{"missing":null,"motd":"hello \"world\"","weather":{"temp":25}}`
+	deadline := time.Now().Add(time.Second)
+	for string(composite.Snapshot().Content) != expected && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if string(composite.Snapshot().Content) != expected {
+		t.Errorf("<composite> content not rebuilt. expected %s obtained %s\n", expected, composite.Snapshot().Content)
+	}
+}
@@ -0,0 +1,396 @@
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConfigDecoder converts a configuration file content to JSON
+type ConfigDecoder func(b []byte) ([]byte, error)
+
+var (
+	configFormats   = map[string]ConfigDecoder{".json": nil}
+	configFormatsMu sync.RWMutex
+)
+
+// RegisterConfigFormat enables loading configuration files with the given extension, e.g.
+// RegisterConfigFormat(".yaml", yaml.YAMLToJSON) with sigs.k8s.io/yaml
+func RegisterConfigFormat(ext string, decoder ConfigDecoder) {
+	configFormatsMu.Lock()
+	configFormats[strings.ToLower(ext)] = decoder
+	configFormatsMu.Unlock()
+}
+
+// duration marshals a time.Duration as a string like "10s"
+type duration time.Duration
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	switch value := v.(type) {
+	case float64:
+		*d = duration(value)
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*d = duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", b)
+	}
+
+	return nil
+}
+
+// resourceConfig represents the JSON definition of a resource
+type resourceConfig struct {
+	Alias                  string        `json:"alias"`
+	Method                 string        `json:"method"`
+	URL                    string        `json:"url"`
+	FallbackURLs           []string      `json:"fallback_urls,omitempty"`
+	Headers                http.Header   `json:"headers,omitempty"`
+	Auth                   *UpstreamAuth `json:"auth,omitempty"`
+	Body                   string        `json:"body,omitempty"`
+	ContentType            string        `json:"content_type,omitempty"`
+	Interval               duration      `json:"interval,omitempty"`
+	Schedule               string        `json:"schedule,omitempty"`
+	Adaptive               bool          `json:"adaptive,omitempty"`
+	MinInterval            duration      `json:"min_interval,omitempty"`
+	MaxInterval            duration      `json:"max_interval,omitempty"`
+	Timeout                duration      `json:"timeout,omitempty"`
+	AllowedOrigins         []string      `json:"allowed_origins,omitempty"`
+	MaxConsecutiveFailures int           `json:"max_consecutive_failures,omitempty"`
+	RemoveOnGone           bool          `json:"remove_on_gone,omitempty"`
+	CachePolicy            *CachePolicy  `json:"cache_policy,omitempty"`
+	CORS                   *CORSConfig   `json:"cors,omitempty"`
+	APIKeys                []string      `json:"api_keys,omitempty"`
+	Roles                  []string      `json:"roles,omitempty"`
+	JSONSchema             *JSONSchema   `json:"json_schema,omitempty"`
+	MaxVariants            int           `json:"max_variants,omitempty"`
+	VariantTTL             duration      `json:"variant_ttl,omitempty"`
+	Languages              []string      `json:"languages,omitempty"`
+	MaxVersions            int           `json:"max_versions,omitempty"`
+	EventName              string        `json:"event_name,omitempty"`
+
+	Representations []*Representation `json:"representations,omitempty"`
+	Transformers    []json.RawMessage `json:"transformers,omitempty"`
+}
+
+// corsConfig represents the JSON definition of a CORS configuration
+type corsConfig struct {
+	AllowedMethods   []string `json:"allowed_methods,omitempty"`
+	AllowedHeaders   []string `json:"allowed_headers,omitempty"`
+	ExposeHeaders    []string `json:"expose_headers,omitempty"`
+	AllowCredentials bool     `json:"allow_credentials,omitempty"`
+	MaxAge           duration `json:"max_age,omitempty"`
+}
+
+// MarshalJSON encodes the CORS configuration, durations being strings like "10s"
+func (cfg CORSConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(corsConfig{
+		AllowedMethods:   cfg.AllowedMethods,
+		AllowedHeaders:   cfg.AllowedHeaders,
+		ExposeHeaders:    cfg.ExposeHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           duration(cfg.MaxAge),
+	})
+}
+
+// UnmarshalJSON decodes a CORS configuration, durations being strings like "10s"
+func (cfg *CORSConfig) UnmarshalJSON(b []byte) error {
+	var v corsConfig
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*cfg = CORSConfig{
+		AllowedMethods:   v.AllowedMethods,
+		AllowedHeaders:   v.AllowedHeaders,
+		ExposeHeaders:    v.ExposeHeaders,
+		AllowCredentials: v.AllowCredentials,
+		MaxAge:           time.Duration(v.MaxAge),
+	}
+
+	return nil
+}
+
+// cachePolicyConfig represents the JSON definition of a cache policy
+type cachePolicyConfig struct {
+	MaxAge               duration `json:"max_age,omitempty"`
+	SMaxAge              duration `json:"s_maxage,omitempty"`
+	StaleWhileRevalidate duration `json:"stale_while_revalidate,omitempty"`
+	StaleIfError         duration `json:"stale_if_error,omitempty"`
+	NoStore              bool     `json:"no_store,omitempty"`
+	NoCache              bool     `json:"no_cache,omitempty"`
+	Private              bool     `json:"private,omitempty"`
+	Public               bool     `json:"public,omitempty"`
+	Immutable            bool     `json:"immutable,omitempty"`
+}
+
+// MarshalJSON encodes the cache policy, durations being strings like "10s"
+func (p CachePolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(cachePolicyConfig{
+		MaxAge:               duration(p.MaxAge),
+		SMaxAge:              duration(p.SMaxAge),
+		StaleWhileRevalidate: duration(p.StaleWhileRevalidate),
+		StaleIfError:         duration(p.StaleIfError),
+		NoStore:              p.NoStore,
+		NoCache:              p.NoCache,
+		Private:              p.Private,
+		Public:               p.Public,
+		Immutable:            p.Immutable,
+	})
+}
+
+// UnmarshalJSON decodes a cache policy, durations being strings like "10s"
+func (p *CachePolicy) UnmarshalJSON(b []byte) error {
+	var cfg cachePolicyConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return err
+	}
+
+	*p = CachePolicy{
+		MaxAge:               time.Duration(cfg.MaxAge),
+		SMaxAge:              time.Duration(cfg.SMaxAge),
+		StaleWhileRevalidate: time.Duration(cfg.StaleWhileRevalidate),
+		StaleIfError:         time.Duration(cfg.StaleIfError),
+		NoStore:              cfg.NoStore,
+		NoCache:              cfg.NoCache,
+		Private:              cfg.Private,
+		Public:               cfg.Public,
+		Immutable:            cfg.Immutable,
+	}
+
+	return nil
+}
+
+// secretHeaders are request headers left out of encoded resource definitions
+var secretHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key"}
+
+// MarshalJSON encodes the resource definition (not its cached content), transformers
+// being included when built from a definition. Secrets are write-only: upstream passwords
+// and tokens, API keys and credential headers are left out, see ExportResourcesJSON.
+func (r *Resource) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.config().redacted())
+}
+
+// redacted returns the definition without its secrets
+func (cfg resourceConfig) redacted() resourceConfig {
+	if auth := cfg.Auth; auth != nil {
+		cfg.Auth = nil
+		if auth.Username != "" {
+			cfg.Auth = &UpstreamAuth{Username: auth.Username}
+		}
+	}
+
+	cfg.APIKeys = nil
+
+	if cfg.Headers != nil {
+		cfg.Headers = cfg.Headers.Clone()
+		for _, key := range secretHeaders {
+			cfg.Headers.Del(key)
+		}
+		if len(cfg.Headers) == 0 {
+			cfg.Headers = nil
+		}
+	}
+
+	return cfg
+}
+
+// config returns the full definition of the resource, secrets included
+func (r *Resource) config() resourceConfig {
+	return resourceConfig{
+		Alias:                  r.Alias,
+		Method:                 r.Method,
+		URL:                    r.URL,
+		FallbackURLs:           r.FallbackURLs,
+		Headers:                r.RequestHeaders,
+		Auth:                   r.Auth,
+		Body:                   string(r.Body),
+		ContentType:            r.ContentType,
+		Interval:               duration(r.Interval),
+		Schedule:               r.Schedule,
+		Adaptive:               r.Adaptive,
+		MinInterval:            duration(r.MinInterval),
+		MaxInterval:            duration(r.MaxInterval),
+		Timeout:                duration(r.Timeout),
+		AllowedOrigins:         r.AllowedOrigins,
+		MaxConsecutiveFailures: r.MaxConsecutiveFailures,
+		RemoveOnGone:           r.RemoveOnGone,
+		CachePolicy:            r.CachePolicy,
+		CORS:                   r.CORS,
+		APIKeys:                r.APIKeys,
+		Roles:                  r.Roles,
+		JSONSchema:             r.JSONSchema,
+		MaxVariants:            r.MaxVariants,
+		VariantTTL:             duration(r.VariantTTL),
+		Languages:              r.Languages,
+		MaxVersions:            r.MaxVersions,
+		EventName:              r.EventName,
+		Representations:        r.Representations,
+		Transformers:           transformerConfigs(r.Transformers),
+	}
+}
+
+// sameDefinition reports whether two resources are defined identically
+func sameDefinition(a, b *Resource) bool {
+	ja, err := json.Marshal(a.config())
+	if err != nil {
+		return false
+	}
+
+	jb, err := json.Marshal(b.config())
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(ja, jb)
+}
+
+// UnmarshalJSON decodes a resource definition, durations being strings like "10s"
+func (r *Resource) UnmarshalJSON(b []byte) error {
+	var cfg resourceConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return err
+	}
+
+	r.Alias = cfg.Alias
+	r.Method = cfg.Method
+	r.URL = cfg.URL
+	r.FallbackURLs = cfg.FallbackURLs
+	r.RequestHeaders = cfg.Headers
+	r.Auth = cfg.Auth
+	r.ContentType = cfg.ContentType
+	if cfg.Body != "" {
+		r.Body = []byte(cfg.Body)
+	}
+	r.Interval = time.Duration(cfg.Interval)
+	r.Schedule = cfg.Schedule
+	r.Adaptive = cfg.Adaptive
+	r.MinInterval = time.Duration(cfg.MinInterval)
+	r.MaxInterval = time.Duration(cfg.MaxInterval)
+	r.Timeout = time.Duration(cfg.Timeout)
+	r.AllowedOrigins = cfg.AllowedOrigins
+	r.MaxConsecutiveFailures = cfg.MaxConsecutiveFailures
+	r.RemoveOnGone = cfg.RemoveOnGone
+	r.CachePolicy = cfg.CachePolicy
+	r.CORS = cfg.CORS
+	r.APIKeys = cfg.APIKeys
+	r.Roles = cfg.Roles
+	r.JSONSchema = cfg.JSONSchema
+	r.MaxVariants = cfg.MaxVariants
+	r.VariantTTL = time.Duration(cfg.VariantTTL)
+	r.Languages = cfg.Languages
+	r.MaxVersions = cfg.MaxVersions
+	r.EventName = cfg.EventName
+
+	r.Representations = cfg.Representations
+
+	transformers, err := newTransformers(cfg.Transformers)
+	if err != nil {
+		return err
+	}
+	r.Transformers = transformers
+
+	return nil
+}
+
+// LoadResourcesJSON parses a JSON array of resource definitions
+func LoadResourcesJSON(r io.Reader) ([]*Resource, error) {
+	var resources []*Resource
+	if err := json.NewDecoder(r).Decode(&resources); err != nil {
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+// LoadResources reads resource definitions from a configuration file, JSON by default
+// or any format enabled with RegisterConfigFormat according to the file extension
+func LoadResources(path string) ([]*Resource, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	configFormatsMu.RLock()
+	decoder, ok := configFormats[ext]
+	configFormatsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported config format %q", ext)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if decoder != nil {
+		if b, err = decoder(b); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	resources, err := LoadResourcesJSON(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return resources, nil
+}
+
+// NewResourceCacherFromConfig creates a resource cacher serving the resources defined in a configuration file
+func NewResourceCacherFromConfig(path string, opts ...Option) (*ResourceCacher, error) {
+	resources, err := LoadResources(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := NewResourceCacher(opts...)
+	if err := c.AddResources(resources, nil); err != nil {
+		c.Stop()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// ExportResourcesJSON writes the definitions of all resources as a JSON array loadable with
+// LoadResourcesJSON. Unlike MarshalJSON it includes the secrets, its output being as sensitive
+// as the configuration file.
+func (c *ResourceCacher) ExportResourcesJSON(w io.Writer) error {
+	resources := c.ListResources()
+
+	configs := make([]resourceConfig, len(resources))
+	for i, res := range resources {
+		configs[i] = res.config()
+	}
+
+	return json.NewEncoder(w).Encode(configs)
+}
+
+// AddResources adds several resources to the resource cacher, stopping at the first error
+func (c *ResourceCacher) AddResources(resources []*Resource, onUpdate ResourceEvent) error {
+	for _, res := range resources {
+		if _, err := c.AddResource(res, onUpdate); err != nil {
+			return fmt.Errorf("resource %s: %w", res.Alias, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,345 @@
+package routing_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestResourcesJSONRoundTrip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	config := `[
+		{"alias": "first", "method": "GET", "url": "` + srv.URL + `", "interval": "10s", "allowed_origins": ["http://good.origin"]},
+		{"alias": "second", "method": "GET", "url": "` + srv.URL + `", "interval": "1m30s", "timeout": "5s"}
+	]`
+
+	resources, err := routing.LoadResourcesJSON(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("load error: %s", err)
+	}
+
+	if len(resources) != 2 {
+		t.Fatalf("<config> resources not equal. expected 2 obtained %d\n", len(resources))
+	}
+
+	if resources[0].Interval != 10*time.Second {
+		t.Errorf("<config> interval not equal. expected %v obtained %v\n", 10*time.Second, resources[0].Interval)
+	}
+
+	if resources[1].Timeout != 5*time.Second {
+		t.Errorf("<config> timeout not equal. expected %v obtained %v\n", 5*time.Second, resources[1].Timeout)
+	}
+
+	c := routing.NewResourceCacher(nil)
+	if err := c.AddResources(resources, nil); err != nil {
+		t.Fatalf("add resources error: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := c.ExportResourcesJSON(&buf); err != nil {
+		t.Fatalf("export error: %s", err)
+	}
+
+	expected := `[{"alias":"first","method":"GET","url":"` + srv.URL + `","interval":"10s","allowed_origins":["http://good.origin"]},` +
+		`{"alias":"second","method":"GET","url":"` + srv.URL + `","interval":"1m30s","timeout":"5s"}]` + "\n"
+
+	if buf.String() != expected {
+		t.Errorf("<config> export not equal. expected %s obtained %s\n", expected, buf.String())
+	}
+}
+
+func TestLoadResourcesJSONInvalidInterval(t *testing.T) {
+	_, err := routing.LoadResourcesJSON(strings.NewReader(`[{"alias": "bad", "interval": "often"}]`))
+	if err == nil {
+		t.Errorf("<config> expected error for invalid interval\n")
+	}
+}
+
+func TestLoadResources(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "routing-config")
+	if err != nil {
+		t.Fatalf("temp dir error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	jsonPath := filepath.Join(dir, "resources.json")
+	config := `[{"alias": "first", "method": "GET", "url": "` + srv.URL + `", "interval": "10s"}]`
+	if err := ioutil.WriteFile(jsonPath, []byte(config), 0644); err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+
+	c, err := routing.NewResourceCacherFromConfig(jsonPath)
+	if err != nil {
+		t.Fatalf("new from config error: %s", err)
+	}
+	defer c.Stop()
+
+	if res, ok := c.GetResource("first"); !ok || res.Interval != 10*time.Second {
+		t.Errorf("<config> expected resource first with a 10s interval\n")
+	}
+
+	// A fake format with "key: value" lines standing for YAML
+	confPath := filepath.Join(dir, "resources.conf")
+	if err := ioutil.WriteFile(confPath, []byte("alias: second\nmethod: GET\nurl: "+srv.URL+"\ninterval: 1m\n"), 0644); err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+
+	if _, err := routing.LoadResources(confPath); err == nil {
+		t.Errorf("<config> expected error for unsupported format\n")
+	}
+
+	routing.RegisterConfigFormat(".conf", func(b []byte) ([]byte, error) {
+		def := make(map[string]string)
+		for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+			kv := strings.SplitN(line, ": ", 2)
+			def[kv[0]] = kv[1]
+		}
+		return json.Marshal([]map[string]string{def})
+	})
+
+	resources, err := routing.LoadResources(confPath)
+	if err != nil {
+		t.Fatalf("load error: %s", err)
+	}
+
+	if len(resources) != 1 || resources[0].Alias != "second" || resources[0].Interval != time.Minute {
+		t.Errorf("<config> unexpected resources %v\n", resources)
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"path": "` + r.URL.Path + `"}`))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "routing-config")
+	if err != nil {
+		t.Fatalf("temp dir error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "resources.json")
+	write := func(config string, modTime time.Time) {
+		if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+			t.Fatalf("write error: %s", err)
+		}
+		os.Chtimes(path, modTime, modTime)
+	}
+
+	write(`[
+		{"alias": "kept", "method": "GET", "url": "`+srv.URL+`/kept", "interval": "1h"},
+		{"alias": "removed", "method": "GET", "url": "`+srv.URL+`/removed", "interval": "1h"}
+	]`, time.Now().Add(-time.Hour))
+
+	c, err := routing.NewResourceCacherFromConfig(path)
+	if err != nil {
+		t.Fatalf("new from config error: %s", err)
+	}
+	defer c.Stop()
+
+	kept, _ := c.GetResource("kept")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.WatchConfig(ctx, path, 10*time.Millisecond, nil)
+	}()
+
+	// Let the watcher record the current modification time
+	time.Sleep(30 * time.Millisecond)
+
+	write(`[
+		{"alias": "kept", "method": "GET", "url": "`+srv.URL+`/kept", "interval": "1h"},
+		{"alias": "added", "method": "GET", "url": "`+srv.URL+`/added", "interval": "1h"}
+	]`, time.Now())
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	<-done
+
+	var aliases []string
+	for _, res := range c.ListResources() {
+		aliases = append(aliases, res.Alias)
+	}
+
+	if strings.Join(aliases, ",") != "added,kept" {
+		t.Errorf("<config> aliases not equal. expected %v obtained %v\n", "added,kept", aliases)
+	}
+
+	if res, _ := c.GetResource("kept"); res != kept {
+		t.Errorf("<config> expected unchanged resource to be kept\n")
+	}
+}
+
+func TestReloadConfigChangedDefinition(t *testing.T) {
+	versions := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		versions <- r.Header.Get("X-Version")
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "routing-config")
+	if err != nil {
+		t.Fatalf("temp dir error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "resources.json")
+	write := func(version, schedule string) {
+		config := `[{"alias": "weather", "method": "GET", "url": "` + srv.URL + `", "interval": "1h", "headers": {"X-Version": ["` + version + `"]}, "schedule": "` + schedule + `"}]`
+		if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+			t.Fatalf("write error: %s", err)
+		}
+	}
+
+	write("1", "")
+	c, err := routing.NewResourceCacherFromConfig(path)
+	if err != nil {
+		t.Fatalf("new from config error: %s", err)
+	}
+	defer c.Stop()
+	<-versions
+
+	tests := []struct {
+		name     string
+		version  string
+		schedule string
+		replaced bool
+	}{
+		{"unchanged", "1", "", false},
+		{"header", "2", "", true},
+		{"schedule", "2", "0 0 * * *", true},
+	}
+
+	for _, tt := range tests {
+		current, _ := c.GetResource("weather")
+
+		write(tt.version, tt.schedule)
+		if err := c.ReloadConfig(path, nil); err != nil {
+			t.Fatalf("%s reload error: %s", tt.name, err)
+		}
+
+		if res, _ := c.GetResource("weather"); (res != current) != tt.replaced {
+			t.Errorf("<config> %s replaced not equal. expected %v obtained %v\n", tt.name, tt.replaced, res != current)
+		}
+
+		if !tt.replaced {
+			continue
+		}
+
+		if version := <-versions; version != tt.version {
+			t.Errorf("<upstream> %s X-Version not equal. expected %v obtained %v\n", tt.name, tt.version, version)
+		}
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	var authorization, apiKey string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		apiKey = r.Header.Get("X-Api-Key")
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	os.Setenv("ROUTING_TEST_HOST", strings.TrimPrefix(srv.URL, "http://"))
+	os.Setenv("ROUTING_TEST_KEY", "s3cret")
+	os.Setenv("ROUTING_TEST_TOKEN", "t0ken")
+	defer os.Unsetenv("ROUTING_TEST_HOST")
+	defer os.Unsetenv("ROUTING_TEST_KEY")
+	defer os.Unsetenv("ROUTING_TEST_TOKEN")
+
+	c := routing.NewResourceCacher(routing.WithEnvExpansion())
+	defer c.Stop()
+
+	res, err := c.AddResource(&routing.Resource{
+		Alias:          "env",
+		Method:         http.MethodGet,
+		URL:            "http://${ROUTING_TEST_HOST}/data",
+		Interval:       time.Hour,
+		RequestHeaders: http.Header{"X-Api-Key": {"${ROUTING_TEST_KEY}"}},
+		Auth:           &routing.UpstreamAuth{BearerToken: "${ROUTING_TEST_TOKEN}"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	if res.URL != srv.URL+"/data" {
+		t.Errorf("<env> url not equal. expected %v obtained %v\n", srv.URL+"/data", res.URL)
+	}
+
+	if apiKey != "s3cret" || authorization != "Bearer t0ken" {
+		t.Errorf("<env> upstream headers not expanded, obtained %q and %q\n", apiKey, authorization)
+	}
+
+	_, err = c.AddResource(&routing.Resource{
+		Alias:    "undefined",
+		Method:   http.MethodGet,
+		URL:      "http://${ROUTING_TEST_UNDEFINED}/data",
+		Interval: time.Hour,
+	}, nil)
+	if err == nil {
+		t.Errorf("<env> expected error for undefined variable\n")
+	}
+}
+
+func TestResourceJSONSecrets(t *testing.T) {
+	res := &routing.Resource{
+		Alias:          "private",
+		Method:         http.MethodGet,
+		URL:            "http://upstream",
+		Interval:       time.Minute,
+		Auth:           &routing.UpstreamAuth{Username: "user", Password: "pass", BearerToken: "token"},
+		APIKeys:        []string{"client-key"},
+		RequestHeaders: http.Header{"Authorization": {"Basic secret"}, "Accept": {"application/json"}},
+	}
+
+	b, err := json.Marshal(res)
+	if err != nil {
+		t.Fatalf("marshal error: %s", err)
+	}
+
+	expected := `{"alias":"private","method":"GET","url":"http://upstream","headers":{"Accept":["application/json"]},"auth":{"username":"user"},"interval":"1m0s"}`
+	if string(b) != expected {
+		t.Errorf("<config> marshal not equal. expected %s obtained %s\n", expected, b)
+	}
+
+	c := routing.NewResourceCacher(nil)
+	if _, err := c.AddResource(res, nil); err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+	defer c.Stop()
+
+	var buf bytes.Buffer
+	if err := c.ExportResourcesJSON(&buf); err != nil {
+		t.Fatalf("export error: %s", err)
+	}
+
+	for _, secret := range []string{"pass", "token", "client-key", "Basic secret"} {
+		if !strings.Contains(buf.String(), secret) {
+			t.Errorf("<config> export missing %q: %s\n", secret, buf.String())
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package routing
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+func init() {
+	RegisterTransformer("xml_to_json", func(config json.RawMessage) (Transformer, error) {
+		return XMLToJSON(), nil
+	})
+
+	RegisterTransformer("csv_to_json", func(config json.RawMessage) (Transformer, error) {
+		var def struct {
+			Comma    string `json:"comma"`
+			NoHeader bool   `json:"no_header"`
+		}
+		if err := json.Unmarshal(config, &def); err != nil {
+			return Transformer{}, err
+		}
+
+		comma := ','
+		if def.Comma != "" {
+			r, size := utf8.DecodeRuneInString(def.Comma)
+			if size != len(def.Comma) {
+				return Transformer{}, errors.New("comma must be a single character")
+			}
+			comma = r
+		}
+
+		return CSVToJSON(comma, !def.NoHeader), nil
+	})
+}
+
+// jsonContentType replaces the content type of converted content
+func jsonContentType(header http.Header) http.Header {
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	header.Set("Content-Type", "application/json")
+	return header
+}
+
+// XMLToJSON converts XML content to JSON: an element becomes an object holding its attributes
+// as "@name" keys and its children by name, repeated children making arrays. Elements with
+// text only become strings, text mixed with attributes or children going to "#text".
+func XMLToJSON() Transformer {
+	return Transformer{
+		Name: "xml_to_json",
+		Transform: func(content []byte, header http.Header) ([]byte, http.Header, error) {
+			dec := xml.NewDecoder(bytes.NewReader(content))
+
+			for {
+				tok, err := dec.Token()
+				if err == io.EOF {
+					return nil, nil, errors.New("no XML root element")
+				}
+				if err != nil {
+					return nil, nil, err
+				}
+
+				if start, ok := tok.(xml.StartElement); ok {
+					root, err := decodeXMLElement(dec, start)
+					if err != nil {
+						return nil, nil, err
+					}
+
+					b, err := json.Marshal(map[string]interface{}{start.Name.Local: root})
+					return b, jsonContentType(header), err
+				}
+			}
+		},
+	}
+}
+
+// decodeXMLElement decodes the element opened by start up to its end
+func decodeXMLElement(dec *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	obj := make(map[string]interface{})
+	for _, attr := range start.Attr {
+		obj["@"+attr.Name.Local] = attr.Value
+	}
+
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := decodeXMLElement(dec, t)
+			if err != nil {
+				return nil, err
+			}
+
+			name := t.Name.Local
+			switch existing := obj[name].(type) {
+			case nil:
+				obj[name] = child
+			case []interface{}:
+				obj[name] = append(existing, child)
+			default:
+				obj[name] = []interface{}{existing, child}
+			}
+
+		case xml.CharData:
+			text.Write(t)
+
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			if len(obj) == 0 {
+				return s, nil
+			}
+
+			if s != "" {
+				obj["#text"] = s
+			}
+
+			return obj, nil
+		}
+	}
+}
+
+// CSVToJSON converts CSV content to JSON: an array of objects keyed by the header row,
+// or an array of arrays without header
+func CSVToJSON(comma rune, header bool) Transformer {
+	return Transformer{
+		Name: "csv_to_json",
+		Transform: func(content []byte, h http.Header) ([]byte, http.Header, error) {
+			r := csv.NewReader(bytes.NewReader(content))
+			r.Comma = comma
+			r.FieldsPerRecord = -1
+
+			records, err := r.ReadAll()
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if !header {
+				if records == nil {
+					records = [][]string{}
+				}
+
+				b, err := json.Marshal(records)
+				return b, jsonContentType(h), err
+			}
+
+			rows := make([]map[string]string, 0, len(records))
+			if len(records) > 1 {
+				columns := records[0]
+				for _, record := range records[1:] {
+					row := make(map[string]string, len(columns))
+					for i, column := range columns {
+						if i < len(record) {
+							row[column] = record[i]
+						}
+					}
+					rows = append(rows, row)
+				}
+			}
+
+			b, err := json.Marshal(rows)
+			return b, jsonContentType(h), err
+		},
+	}
+}
@@ -0,0 +1,86 @@
+package routing_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestXMLToJSON(t *testing.T) {
+	content := `<?xml version="1.0"?>
+<catalog updated="2020-01-01">
+	<book id="1"><title>First</title><tag>a</tag><tag>b</tag></book>
+	<book id="2">Second</book>
+	<empty/>
+</catalog>`
+
+	b, header, err := routing.XMLToJSON().Transform([]byte(content), http.Header{"Content-Type": {"application/xml"}})
+	if err != nil {
+		t.Fatalf("transform error: %s", err)
+	}
+
+	expected := `{"catalog":{"@updated":"2020-01-01","book":[{"@id":"1","tag":["a","b"],"title":"First"},{"#text":"Second","@id":"2"}],"empty":""}}`
+	if string(b) != expected {
+		t.Errorf("<xml> content not equal. expected %s obtained %s\n", expected, b)
+	}
+
+	if header.Get("Content-Type") != "application/json" {
+		t.Errorf("<xml> Content-Type not equal. expected %v obtained %v\n", "application/json", header.Get("Content-Type"))
+	}
+
+	if _, _, err := routing.XMLToJSON().Transform([]byte(`<unclosed>`), nil); err == nil {
+		t.Errorf("<xml> expected invalid XML to be rejected\n")
+	}
+}
+
+func TestCSVToJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		content  string
+		expected string
+	}{
+		{
+			name:     "header",
+			config:   `{"type": "csv_to_json"}`,
+			content:  "name,price\nfirst,1.5\nsecond,2\n",
+			expected: `[{"name":"first","price":"1.5"},{"name":"second","price":"2"}]`,
+		},
+		{
+			name:     "semicolon without header",
+			config:   `{"type": "csv_to_json", "comma": ";", "no_header": true}`,
+			content:  "first;1.5\nsecond;2\n",
+			expected: `[["first","1.5"],["second","2"]]`,
+		},
+		{
+			name:     "empty",
+			config:   `{"type": "csv_to_json"}`,
+			content:  "",
+			expected: `[]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := routing.NewTransformer(json.RawMessage(tt.config))
+			if err != nil {
+				t.Fatalf("new transformer error: %s", err)
+			}
+
+			b, header, err := tr.Transform([]byte(tt.content), http.Header{"Content-Type": {"text/csv"}})
+			if err != nil {
+				t.Fatalf("transform error: %s", err)
+			}
+
+			if string(b) != tt.expected {
+				t.Errorf("<csv> content not equal. expected %s obtained %s\n", tt.expected, b)
+			}
+
+			if header.Get("Content-Type") != "application/json" {
+				t.Errorf("<csv> Content-Type not equal. expected %v obtained %v\n", "application/json", header.Get("Content-Type"))
+			}
+		})
+	}
+}
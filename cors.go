@@ -0,0 +1,132 @@
+package routing
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CORSConfig defines the cross-origin policy of resources, the origins themselves being
+// allowed by Resource.AllowedOrigins
+type CORSConfig struct {
+	// AllowedMethods answered to preflight requests (defaults to GET, HEAD, OPTIONS)
+	AllowedMethods []string
+	// AllowedHeaders answered to preflight requests (defaults to the requested ones)
+	AllowedHeaders []string
+	// ExposeHeaders lists the response headers readable by scripts
+	ExposeHeaders []string
+	// AllowCredentials lets requests include cookies or HTTP authentication
+	AllowCredentials bool
+	// MaxAge of the preflight response in browser caches (0 = omitted)
+	MaxAge time.Duration
+}
+
+// corsFor returns the CORS configuration of a resource, defaulting to Options.CORS
+func (c *ResourceCacher) corsFor(res *Resource) *CORSConfig {
+	if res != nil && res.CORS != nil {
+		return res.CORS
+	}
+
+	return c.opts.CORS
+}
+
+// isPreflight tells whether a request is a CORS preflight request
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// writePreflight answers a CORS preflight request
+func writePreflight(w http.ResponseWriter, r *http.Request, cfg *CORSConfig) {
+	writeCommonHeaders(w, r)
+	writeCORSHeaders(w, cfg)
+
+	h := w.Header()
+	h.Set("Access-Control-Allow-Methods", allowedMethods)
+	if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		h.Set("Access-Control-Allow-Headers", requested)
+	}
+
+	if cfg != nil {
+		if len(cfg.AllowedMethods) > 0 {
+			h.Set("Access-Control-Allow-Methods", strings.Join(cfg.AllowedMethods, ", "))
+		}
+
+		if len(cfg.AllowedHeaders) > 0 {
+			h.Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+		}
+
+		if cfg.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", seconds(cfg.MaxAge))
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeCORSHeaders adds the headers shared by preflight and actual cross-origin responses
+func writeCORSHeaders(w http.ResponseWriter, cfg *CORSConfig) {
+	if cfg == nil {
+		return
+	}
+
+	h := w.Header()
+	if h.Get("Access-Control-Allow-Origin") == "" {
+		return
+	}
+
+	if cfg.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if len(cfg.ExposeHeaders) > 0 {
+		h.Set("Access-Control-Expose-Headers", strings.Join(cfg.ExposeHeaders, ", "))
+	}
+}
+
+// OriginRejection defines the answer to requests from origins not allowed
+type OriginRejection struct {
+	// StatusCode of the answer (defaults to 403)
+	StatusCode int
+	// Header of the answer
+	Header http.Header
+	// Body of the answer
+	Body []byte
+}
+
+// defaultOriginRejection answers 403 with a JSON error
+var defaultOriginRejection = &OriginRejection{
+	StatusCode: http.StatusForbidden,
+	Header:     http.Header{"Content-Type": {"application/json"}},
+	Body:       []byte(`{"error":"origin not allowed"}`),
+}
+
+// rejectOrigin answers a request from an origin not allowed by the resource
+func (c *ResourceCacher) rejectOrigin(w http.ResponseWriter, r *http.Request, res *Resource, origin string) {
+	c.opts.Logger.Debug("origin not allowed", F("alias", res.Alias), F("origin", origin))
+
+	if c.OnOriginRejected != nil {
+		c.OnOriginRejected(res, origin, r)
+	}
+
+	rejection := c.opts.OriginRejection
+	if rejection == nil {
+		rejection = defaultOriginRejection
+	}
+
+	status := rejection.StatusCode
+	if status == 0 {
+		status = http.StatusForbidden
+	}
+
+	if c.opts.ErrorHandler != nil {
+		c.opts.ErrorHandler(w, status)
+		return
+	}
+
+	for k, v := range rejection.Header {
+		w.Header()[k] = append([]string(nil), v...)
+	}
+
+	w.WriteHeader(status)
+	w.Write(rejection.Body)
+}
@@ -0,0 +1,190 @@
+package routing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestCORS(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(routing.WithCORS(&routing.CORSConfig{MaxAge: time.Minute}))
+	for _, res := range []*routing.Resource{
+		{Alias: "default", Method: http.MethodGet, URL: srv.URL, Interval: time.Hour},
+		{
+			Alias:          "custom",
+			Method:         http.MethodGet,
+			URL:            srv.URL,
+			Interval:       time.Hour,
+			AllowedOrigins: []string{"https://app.example.com"},
+			CORS: &routing.CORSConfig{
+				AllowedMethods:   []string{http.MethodGet},
+				AllowedHeaders:   []string{"X-Token"},
+				ExposeHeaders:    []string{"Etag", "Last-Modified"},
+				AllowCredentials: true,
+				MaxAge:           time.Hour,
+			},
+		},
+	} {
+		if _, err := c.AddResource(res, nil); err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		alias      string
+		origin     string
+		preflight  bool
+		statusCode int
+		header     map[string]string
+	}{
+		{
+			name: "default preflight", method: http.MethodOptions, alias: "default", origin: "https://any.example.com", preflight: true,
+			statusCode: http.StatusNoContent,
+			header: map[string]string{
+				"Access-Control-Allow-Origin":  "https://any.example.com",
+				"Access-Control-Allow-Methods": "GET, HEAD, OPTIONS",
+				"Access-Control-Allow-Headers": "X-Requested-With",
+				"Access-Control-Max-Age":       "60",
+			},
+		},
+		{
+			name: "custom preflight", method: http.MethodOptions, alias: "custom", origin: "https://app.example.com", preflight: true,
+			statusCode: http.StatusNoContent,
+			header: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Methods":     "GET",
+				"Access-Control-Allow-Headers":     "X-Token",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Max-Age":           "3600",
+			},
+		},
+		{
+			name: "custom get", method: http.MethodGet, alias: "custom", origin: "https://app.example.com",
+			statusCode: http.StatusOK,
+			header: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "Etag, Last-Modified",
+			},
+		},
+		{
+			name: "same origin get", method: http.MethodGet, alias: "default",
+			statusCode: http.StatusOK,
+			header:     map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/resources/"+tt.alias, nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+				req.Header.Set("Access-Control-Request-Headers", "X-Requested-With")
+			}
+			w := httptest.NewRecorder()
+			c.ServeHTTP(w, req)
+
+			if w.Code != tt.statusCode {
+				t.Errorf("<response> statusCode not equal. expected %v obtained %v\n", tt.statusCode, w.Code)
+			}
+
+			if tt.preflight && w.Body.Len() != 0 {
+				t.Errorf("<response> expected empty preflight body, obtained %s\n", w.Body.String())
+			}
+
+			for k, v := range tt.header {
+				if obtained := w.Header().Get(k); obtained != v {
+					t.Errorf("<response> %s not equal. expected %v obtained %v\n", k, v, obtained)
+				}
+			}
+		})
+	}
+}
+
+func TestOriginRejection(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		rejection   *routing.OriginRejection
+		statusCode  int
+		contentType string
+		body        string
+	}{
+		{
+			name:        "default",
+			statusCode:  http.StatusForbidden,
+			contentType: "application/json",
+			body:        `{"error":"origin not allowed"}`,
+		},
+		{
+			name: "custom",
+			rejection: &routing.OriginRejection{
+				StatusCode: http.StatusUnauthorized,
+				Header:     http.Header{"Content-Type": {"text/plain"}},
+				Body:       []byte("Invalid Origin"),
+			},
+			statusCode:  http.StatusUnauthorized,
+			contentType: "text/plain",
+			body:        "Invalid Origin",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rejected []string
+
+			c := routing.NewResourceCacher(routing.WithOriginRejection(tt.rejection))
+			c.OnOriginRejected = func(res *routing.Resource, origin string, r *http.Request) {
+				rejected = append(rejected, res.Alias+" "+origin)
+			}
+
+			if _, err := c.AddResource(&routing.Resource{
+				Alias:          "rejecting",
+				Method:         http.MethodGet,
+				URL:            srv.URL,
+				Interval:       time.Hour,
+				AllowedOrigins: []string{"https://app.example.com"},
+			}, nil); err != nil {
+				t.Fatalf("add resource error: %s", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/resources/rejecting", nil)
+			req.Header.Set("Origin", "https://evil.example.com")
+			w := httptest.NewRecorder()
+			c.ServeHTTP(w, req)
+
+			if w.Code != tt.statusCode {
+				t.Errorf("<response> statusCode not equal. expected %v obtained %v\n", tt.statusCode, w.Code)
+			}
+
+			if w.Header().Get("Content-Type") != tt.contentType {
+				t.Errorf("<response> Content-Type not equal. expected %v obtained %v\n", tt.contentType, w.Header().Get("Content-Type"))
+			}
+
+			if w.Body.String() != tt.body {
+				t.Errorf("<response> body not equal. expected %v obtained %v\n", tt.body, w.Body.String())
+			}
+
+			if len(rejected) != 1 || rejected[0] != "rejecting https://evil.example.com" {
+				t.Errorf("<response> rejected origins not equal. expected [rejecting https://evil.example.com] obtained %v\n", rejected)
+			}
+		})
+	}
+}
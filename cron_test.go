@@ -0,0 +1,44 @@
+package routing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		expr     string
+		from     string
+		expected string
+	}{
+		{"*/5 * * * *", "2020-01-06 10:02", "2020-01-06 10:05"},
+		{"*/5 8-18 * * MON-FRI", "2020-01-06 18:56", "2020-01-07 08:00"},
+		{"*/5 8-18 * * MON-FRI", "2020-01-10 19:00", "2020-01-13 08:00"},
+		{"0 0 1 JAN *", "2020-06-15 12:00", "2021-01-01 00:00"},
+		{"30 12 29 2 *", "2021-03-01 00:00", "2024-02-29 12:30"},
+		{"0 9 1,15 * sun", "2020-01-02 00:00", "2020-01-05 09:00"},
+		{"0 0 * * 7", "2020-01-06 00:00", "2020-01-12 00:00"},
+	}
+
+	for _, test := range tests {
+		s, err := parseCron(test.expr)
+		if err != nil {
+			t.Errorf("<cron> %s unexpected error %v\n", test.expr, err)
+			continue
+		}
+
+		from, _ := time.Parse("2006-01-02 15:04", test.from)
+		next := s.next(from).Format("2006-01-02 15:04")
+		if next != test.expected {
+			t.Errorf("<cron> %s next not equal. expected %v obtained %v\n", test.expr, test.expected, next)
+		}
+	}
+}
+
+func TestCronInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * * FOO *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("<cron> %q expected error\n", expr)
+		}
+	}
+}
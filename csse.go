@@ -3,22 +3,42 @@ package routing
 import (
 	"encoding/json"
 	"net/http"
-
-	"github.com/JulesMike/go-sse"
+	"strings"
 )
 
-const csseCommonChannel = "common"
+const (
+	csseCommonChannel = "common"
+	// csseSnapshotEvent carries the contents replayed to connecting clients
+	csseSnapshotEvent = "snapshot"
+	// csseInitDoneEvent follows the replayed contents
+	csseInitDoneEvent = "init-done"
+)
 
 type sseMessage struct {
 	Alias   string `json:"alias"`
 	Payload string `json:"payload"`
+
+	// Encoding is set unless the payload is the content as is, see PayloadEncoding
+	Encoding    string `json:"encoding,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Hash        string `json:"hash,omitempty"`
+	Size        int    `json:"size,omitempty"`
+	URL         string `json:"url,omitempty"`
+}
+
+type csseInitDone struct {
+	Aliases []string `json:"aliases"`
 }
 
-// CSSEResourceCacher is an SSE variant of Resource Cacher
+// CSSEResourceCacher is an SSE variant of Resource Cacher. Connecting clients get the
+// current contents in "snapshot" events followed by an "init-done" event listing their
+// aliases, then the updates in events named after each resource
 type CSSEResourceCacher struct {
 	*ResourceCacher
 
-	server *sse.Server
+	server  *sseServer
+	sseOpts *SSEOptions
+	delta   *sseDelta
 }
 
 // NewCSSEResourceCacher returns a new SSE resource cachner
@@ -27,7 +47,7 @@ func NewCSSEResourceCacher(opts *SSEOptions) *CSSEResourceCacher {
 		opts = &SSEOptions{}
 	}
 
-	c := &CSSEResourceCacher{ResourceCacher: NewResourceCacher(opts.Options)}
+	c := &CSSEResourceCacher{ResourceCacher: NewResourceCacher(opts.Options), sseOpts: opts, delta: newSSEDelta(opts)}
 
 	// Increase default retry interval to 5s
 	if opts.RetryInterval == 0 {
@@ -35,48 +55,46 @@ func NewCSSEResourceCacher(opts *SSEOptions) *CSSEResourceCacher {
 	}
 
 	// Create new SSE Server
-	c.server = sse.NewServer(&sse.Options{
-		RetryInterval: opts.RetryInterval,
-		Headers: map[string]string{
-			"Access-Control-Allow-Methods": "GET, OPTIONS",
-			"Access-Control-Allow-Headers": "Keep-Alive,X-Requested-With,Cache-Control,Content-Type,Last-Event-ID",
-		},
-		OnClientConnect: func(client *sse.Client) {
-			// Replay last messages
-			for _, res := range c.resources {
-				b, err := json.Marshal(sseMessage{
-					Alias:   res.Alias,
-					Payload: string(res.Content),
-				})
-				if err != nil {
-					return
-				}
-
-				client.SendMessage(sse.NewMessage(res.Alias+"-"+res.Hash, string(b), "message"))
+	c.server = newSSEServer(opts, c.opts.Logger, func(client *sseClient) []*sseEvent {
+		// Replay last messages
+		var events []*sseEvent
+		done := csseInitDone{Aliases: []string{}}
+		for _, res := range c.ListResources() {
+			if !client.subscribed(res.Alias) {
+				continue
 			}
-		},
-		ChannelNameFunc: func(r *http.Request) string {
-			return csseCommonChannel
-		},
-		Logger: c.ResourceCacher.opts.Logger,
-	})
 
-	c.OnResourceUpdated = func(res *Resource) {
-		if c.server == nil || res.OldHash == res.Hash {
-			return
+			snapshot := res.Snapshot()
+			data, err := c.encodeMessage(res, snapshot.Content)
+			if err != nil {
+				continue
+			}
+
+			e := newSSEEvent(snapshot.Alias+"-"+snapshot.Hash, data, csseSnapshotEvent)
+			e.topic = snapshot.Alias
+			e.res, e.snapshot = res, snapshot
+			events = append(events, e)
+			done.Aliases = append(done.Aliases, snapshot.Alias)
 		}
 
-		b, err := json.Marshal(sseMessage{
-			Alias:   res.Alias,
-			Payload: string(res.Content),
-		})
+		b, err := json.Marshal(done)
 		if err != nil {
-			return
+			return events
 		}
 
-		c.server.SendMessage(csseCommonChannel, sse.NewMessage(res.Alias+"-"+res.Hash, string(b), "message"))
+		return append(events, newSSEEvent("", string(b), csseInitDoneEvent))
+	})
+	c.server.filter = newSSEFilter(opts.MessageFilter, c.encodeMessage)
+
+	// Every client listens to all resources
+	c.clientCount = func(alias string) int {
+		return c.server.ClientCount(csseCommonChannel)
 	}
 
+	c.sseStats = c.server.Stats
+
+	c.AddPublisher(c)
+
 	c.OnStarted = func() {
 		if c.server == nil {
 			return
@@ -90,13 +108,42 @@ func NewCSSEResourceCacher(opts *SSEOptions) *CSSEResourceCacher {
 			return
 		}
 
-		c.server.CloseChannel(csseCommonChannel)
 		c.server.Shutdown()
 	}
 
 	return c
 }
 
+// Publish implements Publisher, changed contents being sent to the common channel
+func (c *CSSEResourceCacher) Publish(event Event) error {
+	if c.server == nil {
+		return nil
+	}
+
+	if event.Type != EventUpdated {
+		c.delta.forget(event.Snapshot.Alias)
+		return nil
+	}
+
+	if !event.Changed {
+		return nil
+	}
+
+	s := event.Snapshot
+	payload, name := c.delta.encode(event.Resource, s)
+	data, err := c.encodeMessage(event.Resource, payload)
+	if err != nil {
+		return err
+	}
+
+	e := newSSEEvent(s.Alias+"-"+s.Hash, data, name)
+	e.topic = s.Alias
+	e.res, e.snapshot = event.Resource, s
+	c.server.SendMessage(csseCommonChannel, e)
+
+	return nil
+}
+
 func (c *CSSEResourceCacher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if c.server == nil {
 		w.WriteHeader(http.StatusServiceUnavailable)
@@ -104,17 +151,89 @@ func (c *CSSEResourceCacher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	for _, resource := range c.resources {
+	aliases := splitAliases(r.URL.Query().Get("aliases"))
+
+	resources := c.ListResources()
+	if len(aliases) > 0 {
+		resources = nil
+		for _, alias := range aliases {
+			resource, ok := c.GetResource(alias)
+			if !ok {
+				c.opts.Logger.Debug("unknown alias", F("alias", alias))
+				c.writeError(w, http.StatusNotFound, "Invalid alias")
+				return
+			}
+			resources = append(resources, resource)
+		}
+	}
+
+	for _, resource := range resources {
 		origin := r.Header.Get("Origin")
 		if !resource.IsOriginAllowed(origin) {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte("Invalid Origin for " + resource.Alias))
+			c.rejectOrigin(w, r, resource, origin)
+			return
+		}
+
+		if isPreflight(r) {
+			continue
+		}
+
+		if err := c.authenticate(r, resource); err != nil {
+			c.rejectUnauthenticated(w, resource, err)
+			return
+		}
+
+		if !c.authorize(w, r, resource) {
 			return
 		}
+
+		if c.sseOpts.Authorize != nil {
+			if err := c.sseOpts.Authorize(r, resource.Alias); err != nil {
+				c.rejectUnauthenticated(w, resource, err)
+				return
+			}
+		}
+	}
+
+	if isPreflight(r) {
+		writePreflight(w, r, c.corsFor(nil))
+		return
 	}
 
 	writeCommonHeaders(w, r)
+	writeCORSHeaders(w, c.corsFor(nil))
+
+	// Blocks until the client is gone
+	c.server.ServeHTTP(w, r, csseCommonChannel, aliases...)
+}
+
+// Clients returns the connected EventSource clients, oldest first
+func (c *CSSEResourceCacher) Clients() []SSEClient {
+	if c.server == nil {
+		return nil
+	}
+
+	return c.server.Clients()
+}
+
+// encodeMessage wraps a resource content in the envelope telling clients its alias
+func (c *CSSEResourceCacher) encodeMessage(res *Resource, payload []byte) (string, error) {
+	b, err := json.Marshal(ssePayload(c.sseOpts, res, payload))
+	if err != nil {
+		return "", err
+	}
 
-	c.server.ServeHTTP(w, r)
+	return string(b), nil
+}
+
+// splitAliases parses a comma separated list of aliases
+func splitAliases(s string) []string {
+	var aliases []string
+	for _, alias := range strings.Split(s, ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
 
+	return aliases
 }
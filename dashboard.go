@@ -0,0 +1,151 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// DashboardHandler returns a handler serving a single page dashboard listing all resources,
+// their freshness, size, errors and connected SSE clients, with buttons to refresh or remove
+// them. adminPath is where AdminHandler is mounted (e.g. "/admin"), the admin token being
+// asked for in the page when required.
+func (c *ResourceCacher) DashboardHandler(adminPath string) http.Handler {
+	config, _ := json.Marshal(strings.TrimSuffix(adminPath, "/"))
+	page := strings.Replace(dashboardHTML, "{{ADMIN_PATH}}", string(config), 1)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("Method not allowed"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Write([]byte(page))
+	})
+}
+
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Resource cacher</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { padding: .4em .6em; border-bottom: 1px solid #ddd; text-align: left; font-size: .9em; }
+th { background: #f4f4f4; }
+.bad { color: #b00; }
+.good { color: #070; }
+.error { max-width: 20em; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+#message { color: #b00; }
+</style>
+</head>
+<body>
+<h1>Resource cacher</h1>
+<p>
+<label>Admin token <input id="token" type="password"></label>
+<span id="message"></span>
+</p>
+<table>
+<thead>
+<tr>
+<th>Alias</th><th>Health</th><th>Status</th><th>Size</th><th>Failures</th>
+<th>Last fetch</th><th>Next fetch</th><th>Clients</th><th>Last error</th><th></th>
+</tr>
+</thead>
+<tbody id="resources"></tbody>
+</table>
+<script>
+(function () {
+  var adminPath = {{ADMIN_PATH}};
+  var token = document.getElementById('token');
+  token.value = localStorage.getItem('routing-admin-token') || '';
+  token.addEventListener('change', function () {
+    localStorage.setItem('routing-admin-token', token.value);
+    load();
+  });
+
+  function request(method, path) {
+    return fetch(adminPath + path, {
+      method: method,
+      headers: { 'Authorization': 'Bearer ' + token.value }
+    }).then(function (resp) {
+      if (!resp.ok && resp.status !== 204) {
+        return resp.json().then(function (body) { throw new Error(body.error || resp.statusText); });
+      }
+      return resp.status === 204 ? null : resp.json();
+    });
+  }
+
+  function cell(row, text, className) {
+    var td = document.createElement('td');
+    td.textContent = text;
+    if (className) {
+      td.className = className;
+    }
+    row.appendChild(td);
+    return td;
+  }
+
+  function time(value) {
+    return !value || value.indexOf('0001-') === 0 ? '-' : new Date(value).toLocaleTimeString();
+  }
+
+  function button(td, label, action) {
+    var b = document.createElement('button');
+    b.textContent = label;
+    b.addEventListener('click', action);
+    td.appendChild(b);
+  }
+
+  function render(resources) {
+    var body = document.getElementById('resources');
+    body.innerHTML = '';
+    resources.forEach(function (item) {
+      var status = item.status;
+      var row = document.createElement('tr');
+      cell(row, status.alias);
+      cell(row, status.healthy ? (status.stale ? 'stale' : 'fresh') : 'unhealthy', status.healthy ? 'good' : 'bad');
+      cell(row, status.status_code || '-');
+      cell(row, status.content_size + ' B');
+      cell(row, status.failures);
+      cell(row, time(status.last_fetch));
+      cell(row, status.running ? time(status.next_fetch) : 'paused');
+      cell(row, status.clients || 0);
+      cell(row, status.last_error || '', 'error bad').title = status.last_error || '';
+      var actions = cell(row, '');
+      button(actions, 'Refresh', function () {
+        request('POST', '/resources/' + encodeURIComponent(status.alias) + '/refresh').then(load, show);
+      });
+      button(actions, 'Remove', function () {
+        if (confirm('Remove ' + status.alias + '?')) {
+          request('DELETE', '/resources/' + encodeURIComponent(status.alias)).then(load, show);
+        }
+      });
+      body.appendChild(row);
+    });
+  }
+
+  function show(err) {
+    document.getElementById('message').textContent = err ? err.message : '';
+  }
+
+  function load() {
+    request('GET', '/resources').then(function (resources) {
+      show(null);
+      render(resources);
+    }, show);
+  }
+
+  load();
+  setInterval(load, 5000);
+})();
+</script>
+</body>
+</html>
+`
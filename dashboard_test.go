@@ -0,0 +1,37 @@
+package routing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestDashboardHandler(t *testing.T) {
+	c := routing.NewResourceCacher()
+	h := c.DashboardHandler("/admin/")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("<response> status code not equal. expected %v obtained %v\n", http.StatusOK, w.Code)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("<response> content type not equal. expected %v obtained %v\n", "text/html; charset=utf-8", ct)
+	}
+
+	if body := w.Body.String(); !strings.Contains(body, `var adminPath = "/admin";`) {
+		t.Errorf("<response> expected admin path in page\n")
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/dashboard", nil))
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("<response> status code not equal. expected %v obtained %v\n", http.StatusMethodNotAllowed, w.Code)
+	}
+}
@@ -0,0 +1,71 @@
+package routing
+
+import "sync"
+
+const (
+	defaultSnapshotInterval = 10
+	// patchEventSuffix is appended to the event name of JSON Patch events
+	patchEventSuffix = ".patch"
+)
+
+// sseDelta tracks the content last broadcast for each resource, to send its updates as JSON Patches
+type sseDelta struct {
+	every int
+	last  map[string]deltaState
+	mu    sync.Mutex
+}
+
+type deltaState struct {
+	content []byte
+	patches int
+}
+
+// newSSEDelta returns the delta tracker of the SSE options, nil when disabled
+func newSSEDelta(opts *SSEOptions) *sseDelta {
+	// Patches are shared by all clients whereas filtered contents differ
+	if !opts.Delta || opts.MessageFilter != nil {
+		return nil
+	}
+
+	every := opts.SnapshotInterval
+	if every <= 0 {
+		every = defaultSnapshotInterval
+	}
+
+	return &sseDelta{every: every, last: make(map[string]deltaState)}
+}
+
+// encode returns the payload broadcasting a snapshot along with the event name to use,
+// a patch from the previous content when smaller
+func (d *sseDelta) encode(res *Resource, s *Snapshot) ([]byte, string) {
+	if d == nil {
+		return s.Content, res.eventName()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	prev := d.last[s.Alias]
+	if prev.content != nil && prev.patches+1 < d.every {
+		patch, err := JSONPatch(prev.content, s.Content)
+		if err == nil && len(patch) < len(s.Content) {
+			d.last[s.Alias] = deltaState{content: s.Content, patches: prev.patches + 1}
+			return patch, res.eventName() + patchEventSuffix
+		}
+	}
+
+	d.last[s.Alias] = deltaState{content: s.Content}
+
+	return s.Content, res.eventName()
+}
+
+// forget drops the content of a resource, its next update being sent in full
+func (d *sseDelta) forget(alias string) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	delete(d.last, alias)
+	d.mu.Unlock()
+}
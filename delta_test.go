@@ -0,0 +1,35 @@
+package routing
+
+import "testing"
+
+func TestSSEDelta(t *testing.T) {
+	res := &Resource{Alias: "clock"}
+	d := newSSEDelta(&SSEOptions{Delta: true, SnapshotInterval: 3})
+
+	big := `"padding": "the patch being smaller than the content"`
+	tests := []struct {
+		content  string
+		payload  string
+		expected string
+	}{
+		{`{"time": 1, ` + big + `}`, `{"time": 1, ` + big + `}`, "clock"},
+		{`{"time": 2, ` + big + `}`, `[{"op":"replace","path":"/time","value":2}]`, "clock.patch"},
+		{`{"time": 3, ` + big + `}`, `[{"op":"replace","path":"/time","value":3}]`, "clock.patch"},
+		{`{"time": 4, ` + big + `}`, `{"time": 4, ` + big + `}`, "clock"},
+		{`not json`, `not json`, "clock"},
+	}
+
+	for i, tt := range tests {
+		payload, name := d.encode(res, &Snapshot{Alias: "clock", Content: []byte(tt.content)})
+		if name != tt.expected {
+			t.Errorf("<event %d> name not equal. expected %v obtained %v\n", i, tt.expected, name)
+		}
+		if string(payload) != tt.payload {
+			t.Errorf("<event %d> payload not equal. expected %v obtained %v\n", i, tt.payload, string(payload))
+		}
+	}
+
+	if d := newSSEDelta(&SSEOptions{Delta: true, MessageFilter: func(SSEClient, *Resource) ([]byte, bool) { return nil, true }}); d != nil {
+		t.Errorf("<delta> enabled along with a message filter\n")
+	}
+}
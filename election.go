@@ -0,0 +1,157 @@
+package routing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync/atomic"
+	"time"
+)
+
+const defaultLockTTL = 15 * time.Second
+
+// Elector elects the instance of a cluster running the fetchers, the others serving the
+// content shared through the cache store (e.g. RedisStore)
+type Elector interface {
+	// Campaign runs until ctx is done, calling elected(true) when the instance becomes the
+	// leader and elected(false) when it loses leadership
+	Campaign(ctx context.Context, elected func(leader bool)) error
+}
+
+// LockClient is a distributed lock used by LockElector, typically a thin adapter around
+// a Redis SET NX PX with a Lua script for renewals, or an etcd lease
+type LockClient interface {
+	// Lock acquires key for owner during ttl, or extends it when owner already holds it,
+	// reporting whether owner holds the lock
+	Lock(ctx context.Context, key, owner string, ttl time.Duration) (bool, error)
+	// Unlock releases key when held by owner
+	Unlock(ctx context.Context, key, owner string) error
+}
+
+// LockElector elects the instance holding a distributed lock, renewed every third of its TTL
+type LockElector struct {
+	Client LockClient
+	// Key of the lock (defaults to "routing:leader")
+	Key string
+	// TTL of the lock, the delay before another instance takes over a dead leader (defaults to 15s)
+	TTL time.Duration
+}
+
+// Campaign implements Elector, releasing the lock when ctx is done
+func (e *LockElector) Campaign(ctx context.Context, elected func(leader bool)) error {
+	key := e.Key
+	if key == "" {
+		key = "routing:leader"
+	}
+
+	ttl := e.TTL
+	if ttl <= 0 {
+		ttl = defaultLockTTL
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	owner := hex.EncodeToString(id)
+
+	leader := false
+	var renewed time.Time
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		held, err := e.Client.Lock(ctx, key, owner, ttl)
+		switch {
+		case err != nil:
+			// Transient errors keep the leadership until the lock may have expired,
+			// another instance taking over then
+			held = leader && time.Since(renewed) < ttl
+		case held:
+			renewed = start
+		}
+
+		if held != leader {
+			leader = held
+			elected(leader)
+		}
+
+		select {
+		case <-ctx.Done():
+			if leader {
+				elected(false)
+
+				unlockCtx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+				defer cancel()
+
+				return e.Client.Unlock(unlockCtx, key, owner)
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// IsLeader reports whether the instance runs the fetchers, always true without Elector
+func (c *ResourceCacher) IsLeader() bool {
+	return c.opts.Elector == nil || atomic.LoadInt32(&c.leader) == 1
+}
+
+// startFetcher starts the fetcher of a resource when the instance is the leader
+func (c *ResourceCacher) startFetcher(res *Resource) {
+	if c.IsLeader() {
+		res.StartFetcher()
+	}
+}
+
+// campaign runs the election in the background, starting or stopping the fetchers accordingly
+func (c *ResourceCacher) campaign() {
+	c.campaignMu.Lock()
+	defer c.campaignMu.Unlock()
+
+	if c.stopCampaign != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	c.stopCampaign = func() {
+		cancel()
+		<-done
+	}
+
+	go func() {
+		defer close(done)
+
+		err := c.opts.Elector.Campaign(ctx, func(leader bool) {
+			if !leader {
+				atomic.StoreInt32(&c.leader, 0)
+				c.opts.Logger.Info("leadership lost")
+				for _, res := range c.ListResources() {
+					res.StopFetcher()
+				}
+				return
+			}
+
+			atomic.StoreInt32(&c.leader, 1)
+			c.opts.Logger.Info("elected leader")
+			for _, res := range c.ListResources() {
+				res.StartFetcher()
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			c.opts.Logger.Error("leader election failed", F("error", err))
+		}
+	}()
+}
+
+// resign stops campaigning, releasing leadership
+func (c *ResourceCacher) resign() {
+	c.campaignMu.Lock()
+	stop := c.stopCampaign
+	c.stopCampaign = nil
+	c.campaignMu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+}
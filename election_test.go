@@ -0,0 +1,202 @@
+package routing_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+type fakeLockClient struct {
+	mu      sync.Mutex
+	owner   string
+	expires time.Time
+}
+
+func (l *fakeLockClient) Lock(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.owner != "" && l.owner != owner && time.Now().Before(l.expires) {
+		return false, nil
+	}
+
+	l.owner, l.expires = owner, time.Now().Add(ttl)
+	return true, nil
+}
+
+func (l *fakeLockClient) Unlock(ctx context.Context, key, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.owner == owner {
+		l.owner = ""
+	}
+	return nil
+}
+
+func TestLeaderElection(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte("content"))
+	}))
+	defer srv.Close()
+
+	lock := &fakeLockClient{}
+	store := routing.NewMemoryStore()
+
+	newCacher := func() *routing.SSEResourceCacher {
+		c := routing.NewSSEResourceCacher(&routing.SSEOptions{Options: &routing.Options{
+			Elector: &routing.LockElector{Client: lock, TTL: 60 * time.Millisecond},
+			Store:   store,
+		}})
+		if _, err := c.AddResource(&routing.Resource{
+			Alias:    "news",
+			Method:   http.MethodGet,
+			URL:      srv.URL,
+			Interval: time.Hour,
+		}, nil); err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+		return c
+	}
+
+	waitLeader := func(c *routing.SSEResourceCacher) {
+		deadline := time.Now().Add(time.Second)
+		for !c.IsLeader() && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if !c.IsLeader() {
+			t.Fatalf("<election> instance not elected\n")
+		}
+	}
+
+	c1, c2 := newCacher(), newCacher()
+	if h := atomic.LoadInt32(&hits); h != 0 {
+		t.Errorf("<upstream> hits before election not equal. expected %v obtained %v\n", 0, h)
+	}
+
+	// A client of the follower listening before the leader fetches anything
+	follower := httptest.NewServer(c2)
+	defer follower.Close()
+
+	resp, err := http.Get(follower.URL + "/?alias=news")
+	if err != nil {
+		t.Fatalf("subscribe error: %s", err)
+	}
+	defer resp.Body.Close()
+
+	received := make(chan struct{})
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if scanner.Text() == "data: content" {
+				close(received)
+				return
+			}
+		}
+	}()
+
+	c1.Start()
+	waitLeader(c1)
+
+	c2.Start()
+	time.Sleep(50 * time.Millisecond)
+
+	if c2.IsLeader() {
+		t.Errorf("<election> both instances elected\n")
+	}
+
+	if h := atomic.LoadInt32(&hits); h != 1 {
+		t.Errorf("<upstream> hits not equal. expected %v obtained %v\n", 1, h)
+	}
+
+	// The follower streams the content fetched by the leader
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		t.Errorf("<follower> timed out waiting for the content event\n")
+	}
+
+	// and reports it as healthy
+	rec := httptest.NewRecorder()
+	c2.StatusHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/resources/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("<follower> status code not equal. expected %v obtained %v\n", http.StatusOK, rec.Code)
+	}
+
+	var statuses []routing.ResourceStatus
+	if err := json.NewDecoder(rec.Body).Decode(&statuses); err != nil {
+		t.Fatalf("decode status error: %s", err)
+	}
+	if len(statuses) != 1 || !statuses[0].Healthy || statuses[0].Running || statuses[0].Hash == "" || statuses[0].LastFetch.IsZero() {
+		t.Errorf("<follower> unexpected status %+v\n", statuses)
+	}
+
+	c1.Stop()
+	if c1.IsLeader() {
+		t.Errorf("<election> stopped instance still leader\n")
+	}
+
+	waitLeader(c2)
+	c2.Stop()
+
+	if h := atomic.LoadInt32(&hits); h != 2 {
+		t.Errorf("<upstream> hits not equal. expected %v obtained %v\n", 2, h)
+	}
+}
+
+// failingLockClient grants the lock once then fails to reach the lock server
+type failingLockClient struct {
+	calls int32
+}
+
+func (l *failingLockClient) Lock(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	if atomic.AddInt32(&l.calls, 1) == 1 {
+		return true, nil
+	}
+	return false, errors.New("connection refused")
+}
+
+func (l *failingLockClient) Unlock(ctx context.Context, key, owner string) error {
+	return nil
+}
+
+func TestLockElectorTransientError(t *testing.T) {
+	e := &routing.LockElector{Client: &failingLockClient{}, TTL: 150 * time.Millisecond}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var elected, lost int64
+	go e.Campaign(ctx, func(leader bool) {
+		if leader {
+			atomic.StoreInt64(&elected, time.Now().UnixNano())
+		} else {
+			atomic.StoreInt64(&lost, time.Now().UnixNano())
+		}
+	})
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt64(&lost) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if atomic.LoadInt64(&elected) == 0 || atomic.LoadInt64(&lost) == 0 {
+		t.Fatalf("<election> leadership not gained then lost\n")
+	}
+
+	// Leadership survives the failed renewals until the lock may have expired
+	if held := time.Duration(atomic.LoadInt64(&lost) - atomic.LoadInt64(&elected)); held < 150*time.Millisecond {
+		t.Errorf("<election> leadership lost after %v, before the lock TTL\n", held)
+	}
+}
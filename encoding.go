@@ -0,0 +1,155 @@
+package routing
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Encoding represents a Content-Encoding which cached content can be precompressed with.
+// GzipEncoding and DeflateEncoding are built in. Other codings such as Brotli are not part of
+// the standard library and are supplied by the application, e.g. with andybalholm/brotli:
+//
+//	routing.Encoding{Name: "br", Encode: func(content []byte) ([]byte, error) {
+//		var buf bytes.Buffer
+//		bw := brotli.NewWriter(&buf)
+//		if _, err := bw.Write(content); err != nil {
+//			return nil, err
+//		}
+//		err := bw.Close()
+//		return buf.Bytes(), err
+//	}}
+type Encoding struct {
+	// Name of the content coding as found in Accept-Encoding (e.g. "gzip")
+	Name string
+	// Encode compresses the content
+	Encode func(content []byte) ([]byte, error)
+}
+
+// GzipEncoding compresses content using gzip
+var GzipEncoding = Encoding{
+	Name: "gzip",
+	Encode: func(content []byte) ([]byte, error) {
+		var buf bytes.Buffer
+		return compress(&buf, gzip.NewWriter(&buf), content)
+	},
+}
+
+// DeflateEncoding compresses content using zlib wrapped deflate, as expected by the "deflate" coding
+var DeflateEncoding = Encoding{
+	Name: "deflate",
+	Encode: func(content []byte) ([]byte, error) {
+		var buf bytes.Buffer
+		return compress(&buf, zlib.NewWriter(&buf), content)
+	},
+}
+
+func compress(buf *bytes.Buffer, zw io.WriteCloser, content []byte) ([]byte, error) {
+	if _, err := zw.Write(content); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// encode precomputes the encoded variants of the snapshot content. Content below
+// MinEncodingSize, already encoded or which does not shrink (e.g. images) is left as is.
+func (c *ResourceCacher) encode(s *Snapshot) {
+	s.encoded = nil
+
+	if len(c.opts.Encodings) == 0 || len(s.Content) < c.opts.MinEncodingSize {
+		return
+	}
+
+	if s.Header != nil && s.Header.Get("Content-Encoding") != "" {
+		return
+	}
+
+	encoded := make(map[string][]byte, len(c.opts.Encodings))
+	for _, enc := range c.opts.Encodings {
+		b, err := enc.Encode(s.Content)
+		if err != nil {
+			c.opts.Logger.Warn("encoding failed", F("alias", s.Alias), F("encoding", enc.Name), F("error", err))
+			continue
+		}
+
+		if len(b) >= len(s.Content) {
+			continue
+		}
+
+		encoded[enc.Name] = b
+	}
+
+	s.encoded = encoded
+}
+
+// encodings returns the encodings among all which the snapshot content was precomputed in
+func (s *Snapshot) encodings(all []Encoding) []Encoding {
+	var available []Encoding
+	for _, enc := range all {
+		if _, ok := s.encoded[enc.Name]; ok {
+			available = append(available, enc)
+		}
+	}
+
+	return available
+}
+
+// negotiateEncoding picks the best available encoding given an Accept-Encoding header,
+// ties being broken by the order of the available encodings. Empty means identity.
+func negotiateEncoding(accept string, available []Encoding) string {
+	if accept == "" || len(available) == 0 {
+		return ""
+	}
+
+	qvalues := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		name, q := parseQValue(part)
+		if name != "" {
+			qvalues[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range available {
+		q, ok := qvalues[enc.Name]
+		if !ok {
+			q, ok = qvalues["*"]
+		}
+
+		if ok && q > bestQ {
+			best, bestQ = enc.Name, q
+		}
+	}
+
+	return best
+}
+
+// parseQValue parses an element like "gzip;q=0.8" into its lowercased name and quality
+func parseQValue(part string) (string, float64) {
+	params := strings.Split(part, ";")
+	name := strings.ToLower(strings.TrimSpace(params[0]))
+	q := 1.0
+
+	for _, param := range params[1:] {
+		param = strings.TrimSpace(param)
+		if !strings.HasPrefix(param, "q=") {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+		if err != nil {
+			return "", 0
+		}
+		q = v
+	}
+
+	return name, q
+}
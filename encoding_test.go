@@ -0,0 +1,211 @@
+package routing_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestServeHTTPEncodings(t *testing.T) {
+	content := bytes.Repeat([]byte(`{"status": "ok"}`), 64)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer srv.Close()
+
+	// Stands in for a brotli encoder, which is not part of the standard library
+	brotli := routing.Encoding{
+		Name: "br",
+		Encode: func(content []byte) ([]byte, error) {
+			b, err := routing.GzipEncoding.Encode(content)
+			return append([]byte("br:"), b...), err
+		},
+	}
+
+	c := routing.NewResourceCacher(&routing.Options{
+		Encodings: []routing.Encoding{brotli, routing.GzipEncoding},
+	})
+	_, err := c.AddResource(&routing.Resource{
+		Alias:    "encoded",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Second,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	decode := map[string]func(b []byte) ([]byte, error){
+		"": func(b []byte) ([]byte, error) {
+			return b, nil
+		},
+		"gzip": func(b []byte) ([]byte, error) {
+			zr, err := gzip.NewReader(bytes.NewReader(b))
+			if err != nil {
+				return nil, err
+			}
+			return ioutil.ReadAll(zr)
+		},
+	}
+	decode["br"] = func(b []byte) ([]byte, error) {
+		if !bytes.HasPrefix(b, []byte("br:")) {
+			return nil, errors.New("not brotli")
+		}
+		return decode["gzip"](bytes.TrimPrefix(b, []byte("br:")))
+	}
+
+	tests := []struct {
+		acceptEncoding string
+		encoding       string
+	}{
+		{acceptEncoding: "br, gzip", encoding: "br"},
+		{acceptEncoding: "gzip", encoding: "gzip"},
+		{acceptEncoding: "br;q=0.5, gzip;q=0.8", encoding: "gzip"},
+		{acceptEncoding: "*;q=0.1, br;q=0", encoding: "gzip"},
+		{acceptEncoding: "deflate", encoding: ""},
+		{acceptEncoding: "", encoding: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.acceptEncoding, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?alias=encoded", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			w := httptest.NewRecorder()
+			c.ServeHTTP(w, req)
+			r := w.Result()
+
+			if enc := r.Header.Get("Content-Encoding"); enc != tt.encoding {
+				t.Errorf("<response> Content-Encoding not equal. expected %q obtained %q\n", tt.encoding, enc)
+			}
+
+			b, _ := ioutil.ReadAll(r.Body)
+			if cl := r.Header.Get("Content-Length"); cl != strconv.Itoa(len(b)) {
+				t.Errorf("<response> Content-Length not equal. expected %d obtained %s\n", len(b), cl)
+			}
+
+			decoded, err := decode[r.Header.Get("Content-Encoding")](b)
+			if err != nil {
+				t.Fatalf("decode error: %s", err)
+			}
+
+			if !bytes.Equal(content, decoded) {
+				t.Errorf("<response> decoded content not equal. expected %s obtained %s\n", content, decoded)
+			}
+		})
+	}
+}
+
+func TestEncodingSkipped(t *testing.T) {
+	compressible := bytes.Repeat([]byte("abcdefgh"), 128)
+	incompressible := make([]byte, 1024)
+	rand.New(rand.NewSource(1)).Read(incompressible)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small":
+			w.Write([]byte(`{"status": "ok"}`))
+		case "/random":
+			w.Write(incompressible)
+		default:
+			w.Write(compressible)
+		}
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(&routing.Options{
+		Encodings:       []routing.Encoding{routing.DeflateEncoding},
+		MinEncodingSize: 64,
+	})
+
+	tests := []struct {
+		alias    string
+		encoding string
+	}{
+		{alias: "small", encoding: ""},
+		{alias: "random", encoding: ""},
+		{alias: "text", encoding: "deflate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alias, func(t *testing.T) {
+			if _, err := c.AddResource(&routing.Resource{
+				Alias:    tt.alias,
+				Method:   http.MethodGet,
+				URL:      srv.URL + "/" + tt.alias,
+				Interval: time.Hour,
+			}, nil); err != nil {
+				t.Fatalf("add resource error: %s", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/?alias="+tt.alias, nil)
+			req.Header.Set("Accept-Encoding", "deflate")
+			w := httptest.NewRecorder()
+			c.ServeHTTP(w, req)
+
+			if enc := w.Header().Get("Content-Encoding"); enc != tt.encoding {
+				t.Errorf("<response> Content-Encoding not equal. expected %q obtained %q\n", tt.encoding, enc)
+			}
+
+			if tt.encoding != "deflate" {
+				return
+			}
+
+			zr, err := zlib.NewReader(w.Body)
+			if err != nil {
+				t.Fatalf("deflate error: %s", err)
+			}
+
+			if b, _ := ioutil.ReadAll(zr); !bytes.Equal(compressible, b) {
+				t.Errorf("<response> decoded content not equal\n")
+			}
+		})
+	}
+}
+
+func TestEncodingFallback(t *testing.T) {
+	content := bytes.Repeat([]byte(`{"status": "ok"}`), 64)
+
+	failing := routing.Encoding{
+		Name: "br",
+		Encode: func(content []byte) ([]byte, error) {
+			return nil, errors.New("encoder unavailable")
+		},
+	}
+
+	c := routing.NewResourceCacher(&routing.Options{
+		Encodings: []routing.Encoding{failing, routing.GzipEncoding},
+	})
+	_, err := c.AddResource(&routing.Resource{
+		Alias:    "encoded",
+		Interval: time.Hour,
+		Fetcher: routing.FetcherFunc(func(ctx context.Context) ([]byte, http.Header, int, error) {
+			return content, nil, http.StatusOK, nil
+		}),
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+	defer c.Stop()
+
+	// The preferred encoding is missing, the next acceptable one is served
+	req := httptest.NewRequest(http.MethodGet, "/?alias=encoded", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip;q=0.5")
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, req)
+
+	if enc := w.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Errorf("<response> Content-Encoding not equal. expected %q obtained %q\n", "gzip", enc)
+	}
+}
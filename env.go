@@ -0,0 +1,74 @@
+package routing
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+)
+
+var envVarPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces the ${VAR} references of s with environment variables
+func expandEnv(s string) (string, error) {
+	var err error
+
+	expanded := envVarPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		name := envVarPattern.FindStringSubmatch(ref)[1]
+
+		value, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("undefined environment variable %s", name)
+		}
+
+		return value
+	})
+
+	return expanded, err
+}
+
+// expandResourceEnv interpolates environment variables in the URLs, request headers and
+// auth of a resource
+func expandResourceEnv(res *Resource) error {
+	var err error
+
+	expand := func(s *string) {
+		if err == nil {
+			*s, err = expandEnv(*s)
+		}
+	}
+
+	expand(&res.URL)
+
+	if len(res.FallbackURLs) > 0 {
+		fallbacks := make([]string, len(res.FallbackURLs))
+		copy(fallbacks, res.FallbackURLs)
+		for i := range fallbacks {
+			expand(&fallbacks[i])
+		}
+		res.FallbackURLs = fallbacks
+	}
+
+	if res.RequestHeaders != nil {
+		headers := make(http.Header, len(res.RequestHeaders))
+		for k, v := range res.RequestHeaders {
+			values := make([]string, len(v))
+			copy(values, v)
+			for i := range values {
+				expand(&values[i])
+			}
+			headers[k] = values
+		}
+		res.RequestHeaders = headers
+	}
+
+	if res.Auth != nil {
+		auth := *res.Auth
+		expand(&auth.Username)
+		expand(&auth.Password)
+		expand(&auth.BearerToken)
+		res.Auth = &auth
+	}
+
+	return err
+}
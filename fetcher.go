@@ -0,0 +1,240 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// ErrContentTooLarge is returned when upstream content exceeds the maximum content size
+var ErrContentTooLarge = errors.New("content too large")
+
+// UpstreamAuth represents the credentials sent with upstream requests
+type UpstreamAuth struct {
+	// Username and Password for basic auth
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// BearerToken is sent as an Authorization bearer token
+	BearerToken string `json:"bearer_token,omitempty"`
+	// TokenProvider is invoked before each fetch for a bearer token, overriding BearerToken
+	TokenProvider func(ctx context.Context) (string, error) `json:"-"`
+}
+
+// apply sets the Authorization header of an upstream request
+func (a *UpstreamAuth) apply(req *http.Request) error {
+	token := a.BearerToken
+	if a.TokenProvider != nil {
+		var err error
+		if token, err = a.TokenProvider(req.Context()); err != nil {
+			return err
+		}
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if a.Username != "" || a.Password != "" {
+		req.SetBasicAuth(a.Username, a.Password)
+	}
+
+	return nil
+}
+
+// Fetcher obtains the content of a resource, e.g. from gRPC calls, files or databases
+type Fetcher interface {
+	Fetch(ctx context.Context) (content []byte, header http.Header, statusCode int, err error)
+}
+
+// FetcherFunc is an adapter to use an ordinary function as a Fetcher
+type FetcherFunc func(ctx context.Context) ([]byte, http.Header, int, error)
+
+// Fetch calls f(ctx)
+func (f FetcherFunc) Fetch(ctx context.Context) ([]byte, http.Header, int, error) {
+	return f(ctx)
+}
+
+// fetchHTTP is the default fetcher requesting the resource URL, then its fallbacks
+func (r *Resource) fetchHTTP(ctx context.Context) ([]byte, http.Header, int, error) {
+	cli := r.HTTPClient
+	if cli == nil {
+		cli = &http.Client{
+			Timeout:   r.timeout(),
+			Transport: r.transport(),
+		}
+	}
+
+	var (
+		resp *http.Response
+		err  error
+	)
+
+	// Try the primary URL then the fallbacks in order, failing to build a request aborting the fetch
+	urls := append([]string{r.URL}, r.FallbackURLs...)
+	for i, target := range urls {
+		var req *http.Request
+		if req, err = r.newRequest(ctx, target); err != nil {
+			return nil, nil, 0, err
+		}
+
+		resp, err = cli.Do(req)
+		if err != nil {
+			continue
+		}
+
+		if r.FallbackOnBadStatus && !isSuccessStatus(resp.StatusCode) && i < len(urls)-1 {
+			resp.Body.Close()
+			err = fmt.Errorf("unexpected status %d from %s", resp.StatusCode, target)
+			continue
+		}
+
+		r.FetchedURL = target
+		break
+	}
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	b, err := r.readBody(resp)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return b, resp.Header.Clone(), resp.StatusCode, nil
+}
+
+// transport returns the round tripper for upstream requests, honoring TLSConfig
+func (r *Resource) transport() http.RoundTripper {
+	if r.Transport != nil {
+		return r.Transport
+	}
+
+	if r.TLSConfig == nil {
+		return http.DefaultTransport
+	}
+
+	if r.tlsTransport == nil || r.tlsTransport.TLSClientConfig != r.TLSConfig {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = r.TLSConfig
+		r.tlsTransport = t
+	}
+
+	return r.tlsTransport
+}
+
+// NewTLSConfig builds a TLS configuration trusting the CA bundle in caFile (if any), presenting
+// the client certificate in certFile/keyFile (if any). insecure skips verification, for dev only.
+func NewTLSConfig(caFile, certFile, keyFile string, insecure bool) (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: insecure}
+
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", caFile)
+		}
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// readBody reads the response body, aborting as soon as it exceeds MaxContentSize
+func (r *Resource) readBody(resp *http.Response) ([]byte, error) {
+	if r.MaxContentSize <= 0 {
+		return ioutil.ReadAll(resp.Body)
+	}
+
+	if resp.ContentLength > r.MaxContentSize {
+		return nil, ErrContentTooLarge
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, r.MaxContentSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(b)) > r.MaxContentSize {
+		return nil, ErrContentTooLarge
+	}
+
+	return b, nil
+}
+
+// newRequest builds the upstream request to target, running the request interceptors
+func (r *Resource) newRequest(ctx context.Context, target string) (*http.Request, error) {
+	body := r.Body
+	if r.BodyFunc != nil {
+		var err error
+		if body, err = r.BodyFunc(); err != nil {
+			return nil, err
+		}
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range r.RequestHeaders {
+		req.Header[k] = append([]string(nil), v...)
+	}
+
+	if r.ContentType != "" {
+		req.Header.Set("Content-Type", r.ContentType)
+	}
+
+	if r.Auth != nil {
+		if err := r.Auth.apply(req); err != nil {
+			return nil, err
+		}
+	}
+
+	// Conditional request against the upstream the current content came from
+	if r.Hash != "" && target == r.FetchedURL {
+		if r.upstreamETag != "" {
+			req.Header.Set("If-None-Match", r.upstreamETag)
+		}
+		if r.upstreamLastModified != "" {
+			req.Header.Set("If-Modified-Since", r.upstreamLastModified)
+		}
+	}
+
+	if r.tracer != nil {
+		r.tracer.Inject(ctx, req.Header)
+	}
+
+	for _, intercept := range r.RequestInterceptors {
+		if err := intercept(req); err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+func isSuccessStatus(status int) bool {
+	return status >= http.StatusOK && status < http.StatusMultipleChoices
+}
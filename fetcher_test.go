@@ -0,0 +1,490 @@
+package routing_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestCustomFetcher(t *testing.T) {
+	calls := 0
+	fetcher := routing.FetcherFunc(func(ctx context.Context) ([]byte, http.Header, int, error) {
+		calls++
+		if calls > 1 {
+			return nil, nil, 0, errors.New("unavailable")
+		}
+
+		return []byte("from a file"), http.Header{"Content-Type": []string{"text/plain"}}, 0, nil
+	})
+
+	c := routing.NewResourceCacher(nil)
+	res, err := c.AddResource(&routing.Resource{
+		Alias:    "custom",
+		Interval: time.Hour,
+		Fetcher:  fetcher,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/?alias=custom", nil)
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("<response> statusCode not equal. expected %v obtained %v\n", http.StatusOK, w.Code)
+	}
+
+	if w.Body.String() != "from a file" {
+		t.Errorf("<response> content not equal. expected %s obtained %s\n", "from a file", w.Body.String())
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/plain" {
+		t.Errorf("<response> Content-Type not equal. expected %s obtained %s\n", "text/plain", ct)
+	}
+
+	if err := res.Fetch(); err == nil || err.Error() != "unavailable" {
+		t.Errorf("<resource> fetch error not equal. expected unavailable obtained %v\n", err)
+	}
+
+	if string(res.Content) != "from a file" {
+		t.Errorf("<resource> content not kept. expected %s obtained %s\n", "from a file", res.Content)
+	}
+}
+
+func TestFetchRequestBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	query := `{"query": "{ clock { now } }"}`
+
+	tests := []struct {
+		name string
+		res  *routing.Resource
+	}{
+		{
+			name: "body",
+			res:  &routing.Resource{Body: []byte(query)},
+		},
+		{
+			name: "body func",
+			res: &routing.Resource{
+				Body: []byte("ignored"),
+				BodyFunc: func() ([]byte, error) {
+					return []byte(query), nil
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.res.Method = http.MethodPost
+			tt.res.URL = srv.URL
+			tt.res.ContentType = "application/json"
+			tt.res.Interval = time.Second
+
+			// Fetch twice to make sure the body is sent on every request
+			for i := 0; i < 2; i++ {
+				if err := tt.res.Fetch(); err != nil {
+					t.Fatalf("fetch error: %s", err)
+				}
+
+				if tt.res.StatusCode != http.StatusOK || string(tt.res.Content) != query {
+					t.Errorf("<resource> content not equal. expected %s obtained %d %s\n", query, tt.res.StatusCode, tt.res.Content)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchRequestHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "key" || r.Header.Get("Accept") != "application/xml" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Write([]byte(r.Header.Get("X-Correlation-Id")))
+	}))
+	defer srv.Close()
+
+	res := &routing.Resource{
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Second,
+		RequestHeaders: http.Header{
+			"X-Api-Key":        []string{"key"},
+			"Accept":           []string{"application/xml"},
+			"X-Correlation-Id": []string{"cacher"},
+		},
+	}
+
+	if err := res.Fetch(); err != nil {
+		t.Fatalf("fetch error: %s", err)
+	}
+
+	if res.StatusCode != http.StatusOK || string(res.Content) != "cacher" {
+		t.Errorf("<resource> content not equal. expected cacher obtained %d %s\n", res.StatusCode, res.Content)
+	}
+}
+
+func TestFetchConditionalRequests(t *testing.T) {
+	lastModified := time.Now().UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		name     string
+		validate func(r *http.Request) bool
+		header   http.Header
+	}{
+		{
+			name: "etag",
+			validate: func(r *http.Request) bool {
+				return r.Header.Get("If-None-Match") == `"v1"`
+			},
+			header: http.Header{"Etag": []string{`"v1"`}},
+		},
+		{
+			name: "last modified",
+			validate: func(r *http.Request) bool {
+				return r.Header.Get("If-Modified-Since") == lastModified
+			},
+			header: http.Header{"Last-Modified": []string{lastModified}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notModified := 0
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.header {
+					w.Header()[k] = v
+				}
+
+				if tt.validate(r) {
+					notModified++
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+
+				w.Write([]byte(`{"status": "ok"}`))
+			}))
+			defer srv.Close()
+
+			updates := 0
+			c := routing.NewResourceCacher(nil)
+			res, err := c.AddResource(&routing.Resource{
+				Alias:    "conditional",
+				Method:   http.MethodGet,
+				URL:      srv.URL,
+				Interval: time.Hour,
+			}, func(res *routing.Resource) {
+				updates++
+			})
+			if err != nil {
+				t.Fatalf("add resource error: %s", err)
+			}
+
+			hash := res.Hash
+			if err := res.Fetch(); err != nil {
+				t.Fatalf("fetch error: %s", err)
+			}
+
+			if notModified != 1 {
+				t.Errorf("<upstream> 304 answers not equal. expected 1 obtained %d\n", notModified)
+			}
+
+			if updates != 1 {
+				t.Errorf("<resource> update events not equal. expected 1 obtained %d\n", updates)
+			}
+
+			if res.Hash != hash || res.StatusCode != http.StatusOK || string(res.Content) != `{"status": "ok"}` {
+				t.Errorf("<resource> content not kept. obtained %d %s %s\n", res.StatusCode, res.Hash, res.Content)
+			}
+		})
+	}
+}
+
+func TestFetchMaxContentSize(t *testing.T) {
+	var size int32 = 8
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Streamed without Content-Length so the limit is enforced while reading
+		w.(http.Flusher).Flush()
+		w.Write(bytes.Repeat([]byte("x"), int(atomic.LoadInt32(&size))))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(&routing.Options{MaxContentSize: 16})
+	res, err := c.AddResource(&routing.Resource{
+		Alias:    "limited",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Hour,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	if len(res.Content) != 8 {
+		t.Fatalf("<resource> content size not equal. expected 8 obtained %d\n", len(res.Content))
+	}
+
+	atomic.StoreInt32(&size, 1<<20)
+	if err := res.Fetch(); err != routing.ErrContentTooLarge {
+		t.Errorf("<resource> fetch error not equal. expected %v obtained %v\n", routing.ErrContentTooLarge, err)
+	}
+
+	if len(res.Content) != 8 {
+		t.Errorf("<resource> previous content not kept. expected 8 bytes obtained %d\n", len(res.Content))
+	}
+}
+
+func TestFetchUpstreamAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); ok && user == "user" && pass == "pass" {
+			w.Write([]byte("basic"))
+			return
+		}
+
+		switch r.Header.Get("Authorization") {
+		case "Bearer static":
+			w.Write([]byte("bearer"))
+		case "Bearer fresh":
+			w.Write([]byte("provider"))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		auth    *routing.UpstreamAuth
+		content string
+	}{
+		{
+			name:    "basic",
+			auth:    &routing.UpstreamAuth{Username: "user", Password: "pass"},
+			content: "basic",
+		},
+		{
+			name:    "bearer",
+			auth:    &routing.UpstreamAuth{BearerToken: "static"},
+			content: "bearer",
+		},
+		{
+			name: "token provider",
+			auth: &routing.UpstreamAuth{
+				BearerToken: "static",
+				TokenProvider: func(ctx context.Context) (string, error) {
+					return "fresh", nil
+				},
+			},
+			content: "provider",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &routing.Resource{
+				Method:   http.MethodGet,
+				URL:      srv.URL,
+				Interval: time.Second,
+				Auth:     tt.auth,
+			}
+
+			if err := res.Fetch(); err != nil {
+				t.Fatalf("fetch error: %s", err)
+			}
+
+			if res.StatusCode != http.StatusOK || string(res.Content) != tt.content {
+				t.Errorf("<resource> content not equal. expected %s obtained %d %s\n", tt.content, res.StatusCode, res.Content)
+			}
+		})
+	}
+
+	res := &routing.Resource{
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Second,
+		Auth: &routing.UpstreamAuth{
+			TokenProvider: func(ctx context.Context) (string, error) {
+				return "", errors.New("token expired")
+			},
+		},
+	}
+
+	if err := res.Fetch(); err == nil || err.Error() != "token expired" {
+		t.Errorf("<resource> fetch error not equal. expected token expired obtained %v\n", err)
+	}
+}
+
+func TestFetchTLSConfig(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secure"))
+	}))
+	defer srv.Close()
+
+	f, err := ioutil.TempFile("", "ca*.pem")
+	if err != nil {
+		t.Fatalf("temp file error: %s", err)
+	}
+	defer os.Remove(f.Name())
+
+	pem.Encode(f, &pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	f.Close()
+
+	trusted, err := routing.NewTLSConfig(f.Name(), "", "", false)
+	if err != nil {
+		t.Fatalf("tls config error: %s", err)
+	}
+
+	tests := []struct {
+		name      string
+		tlsConfig *tls.Config
+		opts      *routing.Options
+		ok        bool
+	}{
+		{name: "unknown authority", ok: false},
+		{name: "private ca", tlsConfig: trusted, ok: true},
+		{name: "cacher default", opts: &routing.Options{TLSConfig: &tls.Config{InsecureSkipVerify: true}}, ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := routing.NewResourceCacher(tt.opts)
+			res, err := c.AddResource(&routing.Resource{
+				Alias:     "secure",
+				Method:    http.MethodGet,
+				URL:       srv.URL,
+				Interval:  time.Hour,
+				TLSConfig: tt.tlsConfig,
+			}, nil)
+			if err != nil {
+				t.Fatalf("add resource error: %s", err)
+			}
+
+			err = res.Fetch()
+			if tt.ok && (err != nil || string(res.Content) != "secure") {
+				t.Errorf("<resource> expected secure content obtained %s %v\n", res.Content, err)
+			}
+
+			if !tt.ok && err == nil {
+				t.Errorf("<resource> expected certificate error\n")
+			}
+		})
+	}
+}
+
+type countingTransport struct {
+	requests int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.requests, 1)
+	req.Header.Set("X-Instrumented", "yes")
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestFetchInjectedClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Instrumented")))
+	}))
+	defer srv.Close()
+
+	defaultTransport := &countingTransport{}
+	resourceTransport := &countingTransport{}
+	clientTransport := &countingTransport{}
+
+	c := routing.NewResourceCacher(&routing.Options{Transport: defaultTransport})
+
+	resources := []*routing.Resource{
+		{Alias: "default"},
+		{Alias: "transport", Transport: resourceTransport},
+		{Alias: "client", HTTPClient: &http.Client{Transport: clientTransport}},
+	}
+
+	for _, res := range resources {
+		res.Method = http.MethodGet
+		res.URL = srv.URL
+		res.Interval = time.Hour
+
+		if _, err := c.AddResource(res, nil); err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+
+		if string(res.Content) != "yes" {
+			t.Errorf("<resource> %s not fetched through the injected transport. obtained %s\n", res.Alias, res.Content)
+		}
+	}
+
+	for name, transport := range map[string]*countingTransport{"default": defaultTransport, "resource": resourceTransport, "client": clientTransport} {
+		if n := atomic.LoadInt32(&transport.requests); n != 1 {
+			t.Errorf("<transport> %s requests not equal. expected 1 obtained %d\n", name, n)
+		}
+	}
+}
+
+func TestFetcherMaxContentSize(t *testing.T) {
+	size := 8
+	res := &routing.Resource{
+		Alias:          "limited",
+		Interval:       time.Hour,
+		MaxContentSize: 16,
+		Fetcher: routing.FetcherFunc(func(ctx context.Context) ([]byte, http.Header, int, error) {
+			return bytes.Repeat([]byte("x"), size), nil, http.StatusOK, nil
+		}),
+	}
+
+	if err := res.Fetch(); err != nil {
+		t.Fatalf("fetch error: %s", err)
+	}
+
+	size = 1 << 20
+	if err := res.Fetch(); err != routing.ErrContentTooLarge {
+		t.Errorf("<resource> fetch error not equal. expected %v obtained %v\n", routing.ErrContentTooLarge, err)
+	}
+
+	if len(res.Content) != 8 {
+		t.Errorf("<resource> previous content not kept. expected 8 bytes obtained %d\n", len(res.Content))
+	}
+}
+
+func TestFetchNotModifiedWithoutContent(t *testing.T) {
+	res := &routing.Resource{
+		Alias:    "unmodified",
+		Interval: time.Hour,
+		Fetcher: routing.FetcherFunc(func(ctx context.Context) ([]byte, http.Header, int, error) {
+			return nil, nil, http.StatusNotModified, nil
+		}),
+	}
+
+	if err := res.Fetch(); err == nil {
+		t.Errorf("<resource> expected an error for 304 without cached content\n")
+	}
+
+	if res.Hash != "" || res.StatusCode != 0 {
+		t.Errorf("<resource> empty 304 cached. hash %q status code %d\n", res.Hash, res.StatusCode)
+	}
+}
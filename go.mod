@@ -2,7 +2,4 @@ module go.lsl.digital/lardwaz/routing
 
 go 1.13
 
-require (
-	github.com/JulesMike/go-sse v1.1.2
-	github.com/sirupsen/logrus v1.4.2
-)
+require github.com/sirupsen/logrus v1.4.2
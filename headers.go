@@ -0,0 +1,79 @@
+package routing
+
+import (
+	"net/http"
+	"strconv"
+)
+
+var commonVaryHeaders = []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}
+
+// prepareHeaders precomputes the response headers of every content variant
+// so serving a resource boils down to a bulk copy
+func (c *ResourceCacher) prepareHeaders(s *Snapshot) {
+	headers := map[string]http.Header{"": c.buildHeaders(s, "")}
+	for encoding := range s.encoded {
+		headers[encoding] = c.buildHeaders(s, encoding)
+	}
+
+	s.responseHeaders = headers
+}
+
+// buildHeaders computes the static response headers of a snapshot for an encoding
+func (c *ResourceCacher) buildHeaders(s *Snapshot, encoding string) http.Header {
+	h := make(http.Header, len(s.Header)+len(commonVaryHeaders))
+	for _, v := range commonVaryHeaders {
+		h.Add("Vary", v)
+	}
+
+	for k, v := range s.Header {
+		for _, v2 := range v {
+			h.Set(k, v2)
+		}
+	}
+
+	if len(c.opts.Encodings) != 0 {
+		h.Add("Vary", "Accept-Encoding")
+	}
+
+	if content, ok := s.encoded[encoding]; ok {
+		h.Set("Content-Encoding", encoding)
+		h.Set("Content-Length", strconv.Itoa(len(content)))
+		h.Set("Etag", s.Hash+"-"+encoding)
+	}
+
+	// Exact capacity so that appending to a served header never touches the shared block
+	for k, v := range h {
+		h[k] = v[:len(v):len(v)]
+	}
+
+	return h
+}
+
+// writeResponseHeaders copies the precomputed headers of a snapshot and adds the per request ones
+func (c *ResourceCacher) writeResponseHeaders(w http.ResponseWriter, r *http.Request, res *Resource, s *Snapshot, encoding string) {
+	block, ok := s.responseHeaders[encoding]
+	if !ok {
+		block = c.buildHeaders(s, encoding)
+	}
+
+	h := w.Header()
+	for k, v := range block {
+		if existing, ok := h[k]; ok && k == "Vary" {
+			h[k] = append(existing, v...)
+			continue
+		}
+		h[k] = v
+	}
+
+	if _, ok := block["Access-Control-Allow-Origin"]; !ok {
+		if origin := r.Header.Get("Origin"); origin != "" {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+	}
+
+	writeCORSHeaders(w, c.corsFor(res))
+
+	if c.opts.DebugHeaders {
+		h.Set("X-Cache", res.cacheStatus(s))
+	}
+}
@@ -0,0 +1,56 @@
+package routing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newBenchmarkResource() (*ResourceCacher, *Resource, *Snapshot, *http.Request) {
+	c := NewResourceCacher(nil)
+	res := &Resource{
+		Alias:      "headers",
+		Interval:   time.Hour,
+		Content:    []byte(`{"status": "ok"}`),
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type":   []string{"application/json"},
+			"Content-Length": []string{"16"},
+			"Date":           []string{time.Now().Format(time.RFC1123)},
+			"X-Multi":        []string{"first", "second"},
+		},
+	}
+	res.Hash = "0123456789abcdef"
+	res.Header.Set("Etag", res.Hash)
+	res.Header.Set("Cache-Control", "max-age=3600")
+	res.prepareSnapshot = c.prepareSnapshot
+	res.publish()
+
+	req := httptest.NewRequest(http.MethodGet, "/?alias=headers", nil)
+	req.Header.Set("Origin", "http://good.origin")
+
+	return c, res, res.Snapshot(), req
+}
+
+func BenchmarkWriteResponseHeaders(b *testing.B) {
+	c, res, snapshot, req := newBenchmarkResource()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.writeResponseHeaders(httptest.NewRecorder(), req, res, snapshot, "")
+	}
+}
+
+func BenchmarkWriteHeadersPerRequest(b *testing.B) {
+	_, res, _, req := newBenchmarkResource()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		writeCommonHeaders(w, req)
+		res.WriteHeaders(w)
+	}
+}
@@ -0,0 +1,85 @@
+package routing
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// InvalidationSecretHeader carries the shared secret of invalidation requests
+const InvalidationSecretHeader = "X-Invalidation-Secret"
+
+// InvalidationHandler returns a handler forcing an immediate refetch of the resource
+// given by alias (e.g. POST /resources/invalidate?alias=x), so upstream systems can push
+// invalidations instead of waiting for the next fetch. Requests must carry the shared
+// secret in the X-Invalidation-Secret header or as a bearer token; an empty secret
+// disables invalidations, all requests being refused. Adding wait=1 to the query answers
+// once the refetch completed.
+func (c *ResourceCacher) InvalidationHandler(secret string) http.Handler {
+	if secret == "" {
+		c.opts.Logger.Warn("invalidations disabled, no secret given")
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("Method not allowed"))
+			return
+		}
+
+		if secret == "" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("Invalidations disabled"))
+			return
+		}
+
+		if !checkSecret(r, secret) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("Invalid secret"))
+			return
+		}
+
+		alias, err := getAliasFromRequest(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf("%v", err)))
+			return
+		}
+
+		if r.URL.Query().Get("wait") == "" {
+			if err := c.ForceRefreshAsync(alias); err != nil {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte("Invalid alias"))
+				return
+			}
+
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		if _, ok := c.GetResource(alias); !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("Invalid alias"))
+			return
+		}
+
+		if err := c.ForceRefreshContext(r.Context(), alias); err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(fmt.Sprintf("%v", err)))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func checkSecret(r *http.Request, secret string) bool {
+	provided := r.Header.Get(InvalidationSecretHeader)
+	if provided == "" {
+		provided = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(secret)) == 1
+}
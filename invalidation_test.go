@@ -0,0 +1,94 @@
+package routing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestInvalidationHandler(t *testing.T) {
+	var numRequests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numRequests, 1)
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	_, err := c.AddResource(&routing.Resource{
+		Alias:    "invalidated",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Hour,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+	defer c.Stop()
+
+	h := c.InvalidationHandler("s3cret")
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		secret   string
+		expected int
+	}{
+		{"get", http.MethodGet, "/resources/invalidate?alias=invalidated", "s3cret", http.StatusMethodNotAllowed},
+		{"bad secret", http.MethodPost, "/resources/invalidate?alias=invalidated", "wrong", http.StatusForbidden},
+		{"unknown alias", http.MethodPost, "/resources/invalidate?alias=unknown", "s3cret", http.StatusNotFound},
+		{"async", http.MethodPost, "/resources/invalidate?alias=invalidated", "s3cret", http.StatusAccepted},
+		{"wait", http.MethodPost, "/resources/invalidate?alias=invalidated&wait=1", "s3cret", http.StatusNoContent},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.target, nil)
+		req.Header.Set(routing.InvalidationSecretHeader, test.secret)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Code != test.expected {
+			t.Errorf("<response> %s status code not equal. expected %v obtained %v\n", test.name, test.expected, w.Code)
+		}
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	if n := atomic.LoadInt32(&numRequests); n != 3 {
+		t.Errorf("<invalidation> upstream hits not equal. expected 3 obtained %d\n", n)
+	}
+}
+
+func TestInvalidationHandlerWithoutSecret(t *testing.T) {
+	c := routing.NewResourceCacher(nil)
+	_, err := c.AddResource(&routing.Resource{
+		Alias:    "invalidated",
+		Interval: time.Hour,
+		Fetcher: routing.FetcherFunc(func(ctx context.Context) ([]byte, http.Header, int, error) {
+			return []byte("content"), nil, http.StatusOK, nil
+		}),
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+	defer c.Stop()
+
+	h := c.InvalidationHandler("")
+
+	for _, secret := range []string{"", "anything"} {
+		req := httptest.NewRequest(http.MethodPost, "/resources/invalidate?alias=invalidated", nil)
+		req.Header.Set(routing.InvalidationSecretHeader, secret)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("<response> status code with secret %q not equal. expected %v obtained %v\n", secret, http.StatusForbidden, w.Code)
+		}
+	}
+}
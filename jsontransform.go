@@ -0,0 +1,297 @@
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterTransformer("jsonpath", func(config json.RawMessage) (Transformer, error) {
+		var def struct {
+			Path string `json:"path"`
+		}
+		if err := json.Unmarshal(config, &def); err != nil {
+			return Transformer{}, err
+		}
+
+		return JSONPath(def.Path)
+	})
+
+	RegisterTransformer("json_fields", func(config json.RawMessage) (Transformer, error) {
+		var def struct {
+			Include []string `json:"include"`
+			Exclude []string `json:"exclude"`
+		}
+		if err := json.Unmarshal(config, &def); err != nil {
+			return Transformer{}, err
+		}
+
+		return JSONFields(def.Include, def.Exclude), nil
+	})
+}
+
+// jsonPathSegment is a step of a JSONPath, a wildcard matching every element of an array or object
+type jsonPathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parseJSONPath parses a JSONPath subset made of dotted keys, quoted keys, indexes and
+// wildcards, e.g. $.data.items[*].name or $['odd.key'][0]
+func parseJSONPath(path string) ([]jsonPathSegment, error) {
+	p := strings.TrimPrefix(strings.TrimSpace(path), "$")
+
+	var segments []jsonPathSegment
+	for len(p) > 0 {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+
+			key := p[:end]
+			if key == "" {
+				return nil, fmt.Errorf("invalid path %q: empty key", path)
+			}
+
+			if key == "*" {
+				segments = append(segments, jsonPathSegment{wildcard: true})
+			} else {
+				segments = append(segments, jsonPathSegment{key: key})
+			}
+			p = p[end:]
+
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unclosed bracket", path)
+			}
+
+			inner := p[1:end]
+			switch {
+			case inner == "*":
+				segments = append(segments, jsonPathSegment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				segments = append(segments, jsonPathSegment{key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid path %q: invalid index %q", path, inner)
+				}
+				segments = append(segments, jsonPathSegment{index: index, isIndex: true})
+			}
+			p = p[end+1:]
+
+		default:
+			return nil, fmt.Errorf("invalid path %q: unexpected %q", path, p[0])
+		}
+	}
+
+	return segments, nil
+}
+
+// evalJSONPath evaluates parsed segments on a decoded JSON value, a path holding
+// wildcards returning the array of all matches
+func evalJSONPath(value interface{}, segments []jsonPathSegment) (interface{}, error) {
+	values := []interface{}{value}
+	multiple := false
+
+	for _, seg := range segments {
+		var next []interface{}
+		for _, v := range values {
+			switch {
+			case seg.wildcard:
+				multiple = true
+				switch node := v.(type) {
+				case []interface{}:
+					next = append(next, node...)
+				case map[string]interface{}:
+					for _, item := range node {
+						next = append(next, item)
+					}
+				}
+
+			case seg.isIndex:
+				node, ok := v.([]interface{})
+				index := seg.index
+				if ok && index < 0 {
+					index += len(node)
+				}
+				if !ok || index < 0 || index >= len(node) {
+					if multiple {
+						continue
+					}
+					return nil, fmt.Errorf("index %d not found", seg.index)
+				}
+				next = append(next, node[index])
+
+			default:
+				node, ok := v.(map[string]interface{})
+				item, found := node[seg.key]
+				if !ok || !found {
+					if multiple {
+						continue
+					}
+					return nil, fmt.Errorf("key %q not found", seg.key)
+				}
+				next = append(next, item)
+			}
+		}
+		values = next
+	}
+
+	if multiple {
+		if values == nil {
+			values = []interface{}{}
+		}
+		return values, nil
+	}
+
+	return values[0], nil
+}
+
+// decodeJSON decodes content keeping numbers as they are
+func decodeJSON(content []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// JSONPath caches only the part of JSON content selected by a JSONPath expression, e.g.
+// "$.data.items[*].name". Dotted keys, quoted keys, indexes (negative from the end) and
+// wildcards are supported.
+func JSONPath(path string) (Transformer, error) {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return Transformer{}, err
+	}
+
+	return Transformer{
+		Name: "jsonpath " + path,
+		Transform: func(content []byte, header http.Header) ([]byte, http.Header, error) {
+			v, err := decodeJSON(content)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			selected, err := evalJSONPath(v, segments)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			b, err := json.Marshal(selected)
+			return b, header, err
+		},
+	}, nil
+}
+
+// JSONFields keeps only the included fields of JSON objects, or drops the excluded ones,
+// applying to each element of top level arrays. Fields are dotted paths like "user.name".
+func JSONFields(include, exclude []string) Transformer {
+	return Transformer{
+		Name: "json_fields",
+		Transform: func(content []byte, header http.Header) ([]byte, http.Header, error) {
+			v, err := decodeJSON(content)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			filter := func(v interface{}) (interface{}, error) {
+				obj, ok := v.(map[string]interface{})
+				if !ok {
+					return nil, errors.New("not a JSON object")
+				}
+
+				if len(include) > 0 {
+					obj = includeFields(obj, include)
+				}
+
+				for _, field := range exclude {
+					excludeField(obj, strings.Split(field, "."))
+				}
+
+				return obj, nil
+			}
+
+			if items, ok := v.([]interface{}); ok {
+				for i, item := range items {
+					if items[i], err = filter(item); err != nil {
+						return nil, nil, err
+					}
+				}
+			} else if v, err = filter(v); err != nil {
+				return nil, nil, err
+			}
+
+			b, err := json.Marshal(v)
+			return b, header, err
+		},
+	}
+}
+
+// includeFields copies the fields of obj found at the given dotted paths
+func includeFields(obj map[string]interface{}, fields []string) map[string]interface{} {
+	out := make(map[string]interface{})
+	for _, field := range fields {
+		keys := strings.Split(field, ".")
+
+		src, dst := obj, out
+		for i, key := range keys {
+			value, ok := src[key]
+			if !ok {
+				break
+			}
+
+			if i == len(keys)-1 {
+				dst[key] = value
+				break
+			}
+
+			child, ok := value.(map[string]interface{})
+			if !ok {
+				break
+			}
+
+			next, ok := dst[key].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				dst[key] = next
+			}
+
+			src, dst = child, next
+		}
+	}
+
+	return out
+}
+
+// excludeField deletes the field of obj found at the given path
+func excludeField(obj map[string]interface{}, keys []string) {
+	for i, key := range keys {
+		if i == len(keys)-1 {
+			delete(obj, key)
+			return
+		}
+
+		child, ok := obj[key].(map[string]interface{})
+		if !ok {
+			return
+		}
+		obj = child
+	}
+}
@@ -0,0 +1,148 @@
+package routing_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+const jsonTransformFixture = `{
+	"data": {
+		"total": 12345678901234567890,
+		"items": [
+			{"name": "first", "price": 1.5, "user": {"id": 1, "email": "a@example.com"}},
+			{"name": "second", "price": 2, "user": {"id": 2, "email": "b@example.com"}}
+		],
+		"odd.key": true
+	}
+}`
+
+func TestJSONPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+		err      bool
+	}{
+		{path: "$.data.total", expected: `12345678901234567890`},
+		{path: "$.data.items[0].name", expected: `"first"`},
+		{path: "$.data.items[-1].user", expected: `{"email":"b@example.com","id":2}`},
+		{path: "$.data.items[*].name", expected: `["first","second"]`},
+		{path: "$.data['odd.key']", expected: `true`},
+		{path: "$.data.items[*].missing", expected: `[]`},
+		{path: "$.data.missing", err: true},
+		{path: "$.data.items[2]", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			tr, err := routing.JSONPath(tt.path)
+			if err != nil {
+				t.Fatalf("parse error: %s", err)
+			}
+
+			b, _, err := tr.Transform([]byte(jsonTransformFixture), http.Header{})
+			if tt.err {
+				if err == nil {
+					t.Errorf("<jsonpath> expected error, obtained %s\n", b)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("transform error: %s", err)
+			}
+
+			if string(b) != tt.expected {
+				t.Errorf("<jsonpath> content not equal. expected %s obtained %s\n", tt.expected, b)
+			}
+		})
+	}
+
+	for _, path := range []string{"$.data[", "$..data", "$.data[x]"} {
+		if _, err := routing.JSONPath(path); err == nil {
+			t.Errorf("<jsonpath> expected path %q to be rejected\n", path)
+		}
+	}
+}
+
+func TestJSONFields(t *testing.T) {
+	items := `[{"name": "first", "price": 1.5, "user": {"id": 1, "email": "a@example.com"}}, {"name": "second"}]`
+
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		expected string
+	}{
+		{name: "include", include: []string{"name", "user.id"}, expected: `[{"name":"first","user":{"id":1}},{"name":"second"}]`},
+		{name: "exclude", exclude: []string{"price", "user.email"}, expected: `[{"name":"first","user":{"id":1}},{"name":"second"}]`},
+		{name: "both", include: []string{"name", "user"}, exclude: []string{"user.email"}, expected: `[{"name":"first","user":{"id":1}},{"name":"second"}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _, err := routing.JSONFields(tt.include, tt.exclude).Transform([]byte(items), http.Header{})
+			if err != nil {
+				t.Fatalf("transform error: %s", err)
+			}
+
+			if string(b) != tt.expected {
+				t.Errorf("<fields> content not equal. expected %s obtained %s\n", tt.expected, b)
+			}
+		})
+	}
+}
+
+func TestTransformersConfig(t *testing.T) {
+	config := `{
+		"alias": "configured",
+		"method": "GET",
+		"url": "http://example.com",
+		"interval": "1m",
+		"transformers": [
+			{"type": "jsonpath", "path": "$.data.items"},
+			{"type": "json_fields", "include": ["name"]}
+		]
+	}`
+
+	var res routing.Resource
+	if err := json.Unmarshal([]byte(config), &res); err != nil {
+		t.Fatalf("unmarshal error: %s", err)
+	}
+
+	content := []byte(jsonTransformFixture)
+	header := http.Header{}
+	for _, tr := range res.Transformers {
+		var err error
+		if content, header, err = tr.Transform(content, header); err != nil {
+			t.Fatalf("transform error: %s", err)
+		}
+	}
+
+	expected := `[{"name":"first"},{"name":"second"}]`
+	if string(content) != expected {
+		t.Errorf("<config> content not equal. expected %s obtained %s\n", expected, content)
+	}
+
+	b, err := json.Marshal(&res)
+	if err != nil {
+		t.Fatalf("marshal error: %s", err)
+	}
+
+	var decoded struct {
+		Transformers []map[string]interface{} `json:"transformers"`
+	}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("unmarshal error: %s", err)
+	}
+
+	if len(decoded.Transformers) != 2 || decoded.Transformers[0]["type"] != "jsonpath" {
+		t.Errorf("<config> transformers not exported, obtained %s\n", b)
+	}
+
+	if err := json.Unmarshal([]byte(`{"alias": "unknown", "transformers": [{"type": "unknown"}]}`), &res); err == nil {
+		t.Errorf("<config> expected unknown transformer type to be rejected\n")
+	}
+}
@@ -0,0 +1,450 @@
+package routing
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultJWKSRefresh = time.Hour
+	// jwksMinRefetch rate limits the JWKS fetches triggered by unknown key ids or following failures
+	jwksMinRefetch = 10 * time.Second
+	// maxJWKSSize bounds the size of fetched JWKS documents
+	maxJWKSSize = 1 << 20
+)
+
+// JWTClaims holds the claims of a validated JSON Web Token
+type JWTClaims map[string]interface{}
+
+// String returns a string claim, empty when missing
+func (c JWTClaims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings returns a claim holding a list of strings, a string being split on spaces (e.g. "scope")
+func (c JWTClaims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
+
+// Subject returns the "sub" claim
+func (c JWTClaims) Subject() string {
+	return c.String("sub")
+}
+
+// JWTAuth is an Authenticator validating JSON Web Tokens sent as bearer tokens, signed with
+// HS256 using Secret or with RS256 / ES256 using the keys published at JWKSURL
+type JWTAuth struct {
+	// Issuer expected in the "iss" claim (empty = not checked)
+	Issuer string
+	// Audience expected in the "aud" claim (empty = not checked)
+	Audience string
+	// JWKSURL publishes the public keys of RS256 and ES256 tokens
+	JWKSURL string
+	// JWKSRefresh is how long fetched keys are used before fetching them again, the last keys
+	// being kept while the JWKS is unavailable (defaults to 1h)
+	JWKSRefresh time.Duration
+	// Secret of HS256 tokens (empty = HS256 rejected)
+	Secret []byte
+	// Query parameter carrying the token when the Authorization header is missing, as
+	// EventSource cannot send headers (empty = disabled)
+	Query string
+	// Leeway tolerates clock skew when checking "exp" and "nbf"
+	Leeway time.Duration
+	// Client fetches the JWKS (defaults to http.DefaultClient)
+	Client *http.Client
+
+	// Authorize decides whether the claims grant access to the resource, returning ErrForbidden otherwise
+	Authorize func(claims JWTClaims, res *Resource) error
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	failedAt  time.Time
+	fetchErr  error
+	fetching  *jwksFetch
+}
+
+// jwksFetch is a JWKS fetch in progress, shared by the requests waiting for it
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// Authenticate implements Authenticator
+func (a *JWTAuth) Authenticate(r *http.Request, res *Resource) error {
+	claims, err := a.Claims(r)
+	if err != nil {
+		return err
+	}
+
+	if a.Authorize != nil {
+		return a.Authorize(claims, res)
+	}
+
+	return nil
+}
+
+// Claims validates the token of a request and returns its claims
+func (a *JWTAuth) Claims(r *http.Request) (JWTClaims, error) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" && a.Query != "" {
+		token = r.URL.Query().Get(a.Query)
+	}
+
+	if token == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	claims, err := a.Verify(r.Context(), token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+
+	return claims, nil
+}
+
+// Verify checks the signature and registered claims of a token and returns its claims
+func (a *JWTAuth) Verify(ctx context.Context, token string) (JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding: %v", err)
+	}
+
+	if err := a.verifySignature(ctx, header.Alg, header.Kid, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims JWTClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	if err := a.validate(claims, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("invalid token encoding: %v", err)
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("invalid token: %v", err)
+	}
+
+	return nil
+}
+
+// verifySignature checks the signature of the signed part of a token
+func (a *JWTAuth) verifySignature(ctx context.Context, alg, kid, signed string, signature []byte) error {
+	sum := sha256.Sum256([]byte(signed))
+
+	switch alg {
+	case "HS256":
+		if len(a.Secret) == 0 {
+			return errors.New("HS256 tokens not accepted")
+		}
+
+		mac := hmac.New(sha256.New, a.Secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return errors.New("invalid signature")
+		}
+
+	case "RS256":
+		key, err := a.key(ctx, kid)
+		if err != nil {
+			return err
+		}
+
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key %q is not an RSA key", kid)
+		}
+
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], signature); err != nil {
+			return errors.New("invalid signature")
+		}
+
+	case "ES256":
+		key, err := a.key(ctx, kid)
+		if err != nil {
+			return err
+		}
+
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key %q is not an EC key", kid)
+		}
+
+		if len(signature) != 64 {
+			return errors.New("invalid signature")
+		}
+
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, sum[:], r, s) {
+			return errors.New("invalid signature")
+		}
+
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	return nil
+}
+
+// validate checks the expiry, not before, issuer and audience claims
+func (a *JWTAuth) validate(claims JWTClaims, now time.Time) error {
+	if exp, ok := claims["exp"].(float64); ok && now.Add(-a.Leeway).Unix() > int64(exp) {
+		return errors.New("token expired")
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(a.Leeway).Unix() < int64(nbf) {
+		return errors.New("token not valid yet")
+	}
+
+	if a.Issuer != "" && claims.String("iss") != a.Issuer {
+		return fmt.Errorf("invalid issuer %q", claims.String("iss"))
+	}
+
+	if a.Audience != "" {
+		for _, aud := range claims.Strings("aud") {
+			if aud == a.Audience {
+				return nil
+			}
+		}
+
+		return errors.New("invalid audience")
+	}
+
+	return nil
+}
+
+// key returns the public key of a key id, fetching the JWKS when expired or when the id is unknown.
+// Concurrent requests share a single fetch, each waiting for it until its context is done.
+func (a *JWTAuth) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	if a.JWKSURL == "" {
+		return nil, errors.New("no JWKS configured")
+	}
+
+	refresh := a.JWKSRefresh
+	if refresh <= 0 {
+		refresh = defaultJWKSRefresh
+	}
+
+	a.mu.Lock()
+	_, known := a.keys[kid]
+	since := time.Since(a.fetchedAt)
+	fetch := a.keys == nil || since > refresh || (!known && since > jwksMinRefetch)
+	if fetch && time.Since(a.failedAt) < jwksMinRefetch {
+		// Back off after a failed fetch, the last keys being used meanwhile
+		if a.keys == nil {
+			err := a.fetchErr
+			a.mu.Unlock()
+			return nil, err
+		}
+		fetch = false
+	}
+
+	if fetch {
+		f := a.fetching
+		if f == nil {
+			f = &jwksFetch{done: make(chan struct{})}
+			a.fetching = f
+			go a.refreshJWKS(f)
+		}
+		a.mu.Unlock()
+
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		a.mu.Lock()
+		if f.err != nil && a.keys == nil {
+			a.mu.Unlock()
+			return nil, f.err
+		}
+	}
+	defer a.mu.Unlock()
+
+	if key, ok := a.keys[kid]; ok {
+		return key, nil
+	}
+
+	// Tokens without key id are accepted when the JWKS holds a single key
+	if kid == "" && len(a.keys) == 1 {
+		for _, key := range a.keys {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// jwk is a JSON Web Key as published in a JWKS
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// refreshJWKS replaces the keys with those published at JWKSURL, regardless of the requests
+// waiting for them being cancelled. The last keys are kept when the fetch fails.
+func (a *JWTAuth) refreshJWKS(f *jwksFetch) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	keys, err := a.fetchJWKS(ctx)
+
+	a.mu.Lock()
+	if err == nil {
+		a.keys, a.fetchedAt = keys, time.Now()
+		a.failedAt, a.fetchErr = time.Time{}, nil
+	} else {
+		a.failedAt, a.fetchErr = time.Now(), err
+	}
+	f.err = err
+	a.fetching = nil
+	a.mu.Unlock()
+
+	close(f.done)
+}
+
+// fetchJWKS fetches the RSA and P-256 public keys published at JWKSURL
+func (a *JWTAuth) fetchJWKS(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch JWKS: unexpected status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJWKSSize)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %v", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	return keys, nil
+}
+
+// publicKey decodes an RSA or P-256 public key
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
@@ -0,0 +1,280 @@
+package routing_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+// signJWT builds a token signed by sign over its header and claims
+func signJWT(t *testing.T, header, claims map[string]interface{}, sign func(signed []byte) []byte) string {
+	segment := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("marshal error: %s", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	signed := segment(header) + "." + segment(claims)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(signed)))
+}
+
+func TestJWTAuth(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key error: %s", err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key error: %s", err)
+	}
+
+	b64 := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{
+				{"kty": "RSA", "kid": "rsa", "n": b64(rsaKey.N), "e": b64(big.NewInt(int64(rsaKey.E)))},
+				{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X), "y": b64(ecKey.Y)},
+			},
+		})
+	}))
+	defer jwks.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	auth := &routing.JWTAuth{
+		Issuer:   "https://issuer.example.com",
+		Audience: "routing",
+		JWKSURL:  jwks.URL,
+		Secret:   []byte("s3cret"),
+		Query:    "access_token",
+		Authorize: func(claims routing.JWTClaims, res *routing.Resource) error {
+			if res.Alias == "admin" && claims.String("role") != "admin" {
+				return routing.ErrForbidden
+			}
+			return nil
+		},
+	}
+
+	c := routing.NewResourceCacher(routing.WithAuthenticator(auth))
+	for _, alias := range []string{"public", "admin"} {
+		if _, err := c.AddResource(&routing.Resource{
+			Alias:    alias,
+			Method:   http.MethodGet,
+			URL:      srv.URL,
+			Interval: time.Hour,
+		}, nil); err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+	}
+
+	claims := func(extra map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss": "https://issuer.example.com",
+			"aud": []string{"other", "routing"},
+			"sub": "user",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+
+	hs256 := func(signed []byte) []byte {
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write(signed)
+		return mac.Sum(nil)
+	}
+	rs256 := func(signed []byte) []byte {
+		sum := sha256.Sum256(signed)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, sum[:])
+		if err != nil {
+			t.Fatalf("sign error: %s", err)
+		}
+		return sig
+	}
+	es256 := func(signed []byte) []byte {
+		sum := sha256.Sum256(signed)
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, sum[:])
+		if err != nil {
+			t.Fatalf("sign error: %s", err)
+		}
+		sig := make([]byte, 64)
+		rb, sb := r.Bytes(), s.Bytes()
+		copy(sig[32-len(rb):32], rb)
+		copy(sig[64-len(sb):], sb)
+		return sig
+	}
+
+	tests := []struct {
+		name       string
+		alias      string
+		token      string
+		query      bool
+		statusCode int
+	}{
+		{name: "missing", alias: "public", statusCode: http.StatusUnauthorized},
+		{name: "hs256", alias: "public", token: signJWT(t, map[string]interface{}{"alg": "HS256"}, claims(nil), hs256), statusCode: http.StatusOK},
+		{name: "rs256", alias: "public", token: signJWT(t, map[string]interface{}{"alg": "RS256", "kid": "rsa"}, claims(nil), rs256), statusCode: http.StatusOK},
+		{name: "es256 query", alias: "public", token: signJWT(t, map[string]interface{}{"alg": "ES256", "kid": "ec"}, claims(nil), es256), query: true, statusCode: http.StatusOK},
+		{name: "wrong key", alias: "public", token: signJWT(t, map[string]interface{}{"alg": "ES256", "kid": "rsa"}, claims(nil), es256), statusCode: http.StatusUnauthorized},
+		{name: "none", alias: "public", token: signJWT(t, map[string]interface{}{"alg": "none"}, claims(nil), func([]byte) []byte { return nil }), statusCode: http.StatusUnauthorized},
+		{name: "expired", alias: "public", token: signJWT(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()}), hs256), statusCode: http.StatusUnauthorized},
+		{name: "issuer", alias: "public", token: signJWT(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"iss": "other"}), hs256), statusCode: http.StatusUnauthorized},
+		{name: "audience", alias: "public", token: signJWT(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"aud": "other"}), hs256), statusCode: http.StatusUnauthorized},
+		{name: "forbidden", alias: "admin", token: signJWT(t, map[string]interface{}{"alg": "HS256"}, claims(nil), hs256), statusCode: http.StatusForbidden},
+		{name: "authorized", alias: "admin", token: signJWT(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"role": "admin"}), hs256), statusCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/resources/" + tt.alias
+			if tt.query {
+				target += "?access_token=" + tt.token
+			}
+
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			if tt.token != "" && !tt.query {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			c.ServeHTTP(w, req)
+
+			if w.Code != tt.statusCode {
+				t.Errorf("<response> statusCode not equal. expected %v obtained %v (%s)\n", tt.statusCode, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestJWTAuthJWKSFetchShared(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.Write([]byte(`{"keys": []}`))
+	}))
+	defer jwks.Close()
+	defer close(release)
+
+	auth := &routing.JWTAuth{JWKSURL: jwks.URL}
+	token := signJWT(t, map[string]interface{}{"alg": "RS256", "kid": "rsa"}, map[string]interface{}{"sub": "user"}, func([]byte) []byte { return []byte("sig") })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+			r.Header.Set("Authorization", "Bearer "+token)
+
+			start := time.Now()
+			if _, err := auth.Claims(r); err == nil {
+				t.Errorf("<jwt> expected error while the JWKS is unavailable\n")
+			}
+			if d := time.Since(start); d > time.Second {
+				t.Errorf("<jwt> request held by the JWKS fetch for %v\n", d)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Errorf("<jwks> fetches not equal. expected %v obtained %v\n", 1, n)
+	}
+}
+
+func TestJWTAuthJWKSFailure(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key error: %s", err)
+	}
+
+	var hits, down int32
+	b64 := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if atomic.LoadInt32(&down) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{
+				{"kty": "RSA", "kid": "rsa", "n": b64(rsaKey.N), "e": b64(big.NewInt(int64(rsaKey.E)))},
+			},
+		})
+	}))
+	defer jwks.Close()
+
+	token := signJWT(t, map[string]interface{}{"alg": "RS256", "kid": "rsa"}, map[string]interface{}{"sub": "user"}, func(signed []byte) []byte {
+		digest := sha256.Sum256(signed)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("sign error: %s", err)
+		}
+		return sig
+	})
+
+	auth := &routing.JWTAuth{JWKSURL: jwks.URL, JWKSRefresh: 10 * time.Millisecond}
+	verify := func() error {
+		_, err := auth.Verify(context.Background(), token)
+		return err
+	}
+
+	if err := verify(); err != nil {
+		t.Fatalf("verify error: %s", err)
+	}
+
+	// The refresh fails once the keys expired, the last ones being used meanwhile
+	atomic.StoreInt32(&down, 1)
+	time.Sleep(20 * time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		if err := verify(); err != nil {
+			t.Errorf("<jwt> verify error with stale keys: %s\n", err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("<jwks> fetches not equal. expected %v obtained %v\n", 2, n)
+	}
+
+	// Without any key, failures are not retried right away either
+	unavailable := &routing.JWTAuth{JWKSURL: jwks.URL}
+	for i := 0; i < 3; i++ {
+		if _, err := unavailable.Verify(context.Background(), token); err == nil {
+			t.Errorf("<jwt> expected error while the JWKS is unavailable\n")
+		}
+	}
+
+	if n := atomic.LoadInt32(&hits); n != 3 {
+		t.Errorf("<jwks> fetches not equal. expected %v obtained %v\n", 3, n)
+	}
+}
@@ -0,0 +1,136 @@
+package routing
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Logger represents a minimal leveled and structured logger
+type Logger interface {
+	Debug(msg string, fields ...Field)
+	Info(msg string, fields ...Field)
+	Warn(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+}
+
+// Field represents a key/value pair attached to a log entry
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F creates a log field
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, fields ...Field) {}
+func (nopLogger) Info(msg string, fields ...Field)  {}
+func (nopLogger) Warn(msg string, fields ...Field)  {}
+func (nopLogger) Error(msg string, fields ...Field) {}
+
+// NopLogger returns a logger discarding everything
+func NopLogger() Logger {
+	return nopLogger{}
+}
+
+type stdLogger struct {
+	logger *log.Logger
+}
+
+// NewStdLogger adapts a standard library logger, fields being appended as key=value
+func NewStdLogger(logger *log.Logger) Logger {
+	return &stdLogger{logger}
+}
+
+func (l *stdLogger) Debug(msg string, fields ...Field) { l.print("DEBUG", msg, fields) }
+func (l *stdLogger) Info(msg string, fields ...Field)  { l.print("INFO", msg, fields) }
+func (l *stdLogger) Warn(msg string, fields ...Field)  { l.print("WARN", msg, fields) }
+func (l *stdLogger) Error(msg string, fields ...Field) { l.print("ERROR", msg, fields) }
+
+func (l *stdLogger) print(level, msg string, fields []Field) {
+	var b strings.Builder
+	b.WriteString(level)
+	b.WriteString(" ")
+	b.WriteString(msg)
+
+	for _, f := range fields {
+		fmt.Fprintf(&b, " %s=%v", f.Key, f.Value)
+	}
+
+	l.logger.Print(b.String())
+}
+
+type logrusLogger struct {
+	entry *logrus.Entry
+}
+
+// NewLogrusLogger adapts a logrus entry, fields becoming logrus fields
+func NewLogrusLogger(entry *logrus.Entry) Logger {
+	return &logrusLogger{entry}
+}
+
+func (l *logrusLogger) Debug(msg string, fields ...Field) { l.with(fields).Debug(msg) }
+func (l *logrusLogger) Info(msg string, fields ...Field)  { l.with(fields).Info(msg) }
+func (l *logrusLogger) Warn(msg string, fields ...Field)  { l.with(fields).Warn(msg) }
+func (l *logrusLogger) Error(msg string, fields ...Field) { l.with(fields).Error(msg) }
+
+func (l *logrusLogger) with(fields []Field) *logrus.Entry {
+	if len(fields) == 0 {
+		return l.entry
+	}
+
+	data := make(logrus.Fields, len(fields))
+	for _, f := range fields {
+		data[f.Key] = f.Value
+	}
+
+	return l.entry.WithFields(data)
+}
+
+// ZapSugaredLogger is the subset of *zap.SugaredLogger used by the zap adapter
+type ZapSugaredLogger interface {
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+}
+
+type zapLogger struct {
+	logger ZapSugaredLogger
+}
+
+// NewZapLogger adapts a zap sugared logger, e.g. NewZapLogger(zapLogger.Sugar())
+func NewZapLogger(logger ZapSugaredLogger) Logger {
+	return &zapLogger{logger}
+}
+
+func (l *zapLogger) Debug(msg string, fields ...Field) {
+	l.logger.Debugw(msg, keysAndValues(fields)...)
+}
+
+func (l *zapLogger) Info(msg string, fields ...Field) {
+	l.logger.Infow(msg, keysAndValues(fields)...)
+}
+
+func (l *zapLogger) Warn(msg string, fields ...Field) {
+	l.logger.Warnw(msg, keysAndValues(fields)...)
+}
+
+func (l *zapLogger) Error(msg string, fields ...Field) {
+	l.logger.Errorw(msg, keysAndValues(fields)...)
+}
+
+func keysAndValues(fields []Field) []interface{} {
+	kv := make([]interface{}, 0, 2*len(fields))
+	for _, f := range fields {
+		kv = append(kv, f.Key, f.Value)
+	}
+
+	return kv
+}
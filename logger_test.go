@@ -0,0 +1,56 @@
+package routing_test
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"go.lsl.digital/lardwaz/routing"
+)
+
+type sugaredLogger struct {
+	calls []string
+}
+
+func (l *sugaredLogger) Debugw(msg string, kv ...interface{}) { l.record("debug", msg, kv) }
+func (l *sugaredLogger) Infow(msg string, kv ...interface{})  { l.record("info", msg, kv) }
+func (l *sugaredLogger) Warnw(msg string, kv ...interface{})  { l.record("warn", msg, kv) }
+func (l *sugaredLogger) Errorw(msg string, kv ...interface{}) { l.record("error", msg, kv) }
+
+func (l *sugaredLogger) record(level, msg string, kv []interface{}) {
+	var b strings.Builder
+	b.WriteString(level + " " + msg)
+	for _, v := range kv {
+		b.WriteString(" ")
+		b.WriteString(v.(string))
+	}
+	l.calls = append(l.calls, b.String())
+}
+
+func TestLoggerAdapters(t *testing.T) {
+	var buf bytes.Buffer
+	routing.NewStdLogger(log.New(&buf, "", 0)).Warn("fetch failed", routing.F("alias", "first"), routing.F("failures", 2))
+
+	if expected := "WARN fetch failed alias=first failures=2\n"; buf.String() != expected {
+		t.Errorf("<logger> std output not equal. expected %q obtained %q\n", expected, buf.String())
+	}
+
+	buf.Reset()
+	l := logrus.New()
+	l.SetOutput(&buf)
+	l.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+	routing.NewLogrusLogger(logrus.NewEntry(l)).Error("fetch failed", routing.F("alias", "first"))
+
+	if expected := "level=error msg=\"fetch failed\" alias=first\n"; buf.String() != expected {
+		t.Errorf("<logger> logrus output not equal. expected %q obtained %q\n", expected, buf.String())
+	}
+
+	sugared := &sugaredLogger{}
+	routing.NewZapLogger(sugared).Info("resource added", routing.F("alias", "first"))
+
+	if len(sugared.calls) != 1 || sugared.calls[0] != "info resource added alias first" {
+		t.Errorf("<logger> zap calls not equal. expected %v obtained %v\n", []string{"info resource added alias first"}, sugared.calls)
+	}
+}
@@ -0,0 +1,63 @@
+package routing
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// mqttReserved holds the characters of aliases escaped in topics: level separators,
+// wildcards and NUL
+const mqttReserved = "/+#\x00"
+
+// MQTTClient is the subset of an MQTT client used by MQTTPublisher, typically a thin adapter
+// around paho.mqtt.golang waiting for the publish token
+type MQTTClient interface {
+	Publish(topic string, qos byte, retained bool, payload []byte) error
+}
+
+// MQTTPublisher publishes the content of resources to MQTT topics when it changes. Messages
+// are retained by default so that new subscribers get the latest content right away.
+type MQTTPublisher struct {
+	Client MQTTClient
+	// Topic of the messages, "{alias}" being replaced by the alias (defaults to "resources/{alias}").
+	// Slashes, wildcards and NUL of aliases are percent-encoded, e.g. "a+b" becoming "a%2Bb".
+	Topic string
+	// QoS of the messages (0, 1 or 2)
+	QoS byte
+	// NoRetain disables retained messages
+	NoRetain bool
+	// Envelope sends JSON WebhookEvent messages instead of the raw content
+	Envelope bool
+}
+
+// Publish implements Publisher, the retained message being cleared when a resource is removed
+func (p *MQTTPublisher) Publish(event Event) error {
+	topic := p.Topic
+	if topic == "" {
+		topic = "resources/{alias}"
+	}
+	topic = strings.Replace(topic, "{alias}", escapeAlias(event.Snapshot.Alias, mqttReserved), -1)
+
+	switch event.Type {
+	case EventUpdated:
+		payload := event.Snapshot.Content
+		if p.Envelope {
+			b, err := json.Marshal(newWebhookEvent(event.Snapshot, event.OldHash, true))
+			if err != nil {
+				return err
+			}
+			payload = b
+		}
+
+		return p.Client.Publish(topic, p.QoS, !p.NoRetain, payload)
+	case EventRemoved:
+		if p.NoRetain {
+			return nil
+		}
+
+		// An empty retained message deletes the retained one
+		return p.Client.Publish(topic, p.QoS, true, []byte{})
+	}
+
+	return nil
+}
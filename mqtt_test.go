@@ -0,0 +1,98 @@
+package routing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+type mqttMessage struct {
+	Topic    string
+	QoS      byte
+	Retained bool
+	Payload  string
+}
+
+type fakeMQTTClient struct {
+	mu       sync.Mutex
+	messages []mqttMessage
+}
+
+func (c *fakeMQTTClient) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	c.mu.Lock()
+	c.messages = append(c.messages, mqttMessage{topic, qos, retained, string(payload)})
+	c.mu.Unlock()
+	return nil
+}
+
+func TestMQTTPublisher(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("21.5"))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		p        *routing.MQTTPublisher
+		alias    string
+		expected []mqttMessage
+	}{
+		{
+			"retained",
+			&routing.MQTTPublisher{QoS: 1},
+			"temp",
+			[]mqttMessage{{"resources/temp", 1, true, "21.5"}, {"resources/temp", 1, true, ""}},
+		},
+		{
+			"not retained",
+			&routing.MQTTPublisher{Topic: "home/{alias}/state", NoRetain: true},
+			"temp",
+			[]mqttMessage{{"home/temp/state", 0, false, "21.5"}},
+		},
+		{
+			"escaped",
+			&routing.MQTTPublisher{NoRetain: true},
+			"temp+#%",
+			[]mqttMessage{{"resources/temp%2B%23%25", 0, false, "21.5"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeMQTTClient{}
+			tt.p.Client = client
+
+			c := routing.NewResourceCacher(routing.WithPublisher(tt.p))
+			if _, err := c.AddResource(&routing.Resource{
+				Alias:    tt.alias,
+				Method:   http.MethodGet,
+				URL:      srv.URL,
+				Interval: time.Hour,
+			}, nil); err != nil {
+				t.Fatalf("add resource error: %s", err)
+			}
+
+			if _, err := c.RemoveResource(tt.alias); err != nil {
+				t.Fatalf("remove resource error: %s", err)
+			}
+
+			waitPublished(func() bool {
+				client.mu.Lock()
+				defer client.mu.Unlock()
+				return len(client.messages) == len(tt.expected)
+			})
+
+			client.mu.Lock()
+			defer client.mu.Unlock()
+
+			if !reflect.DeepEqual(client.messages, tt.expected) {
+				t.Errorf("<mqtt> messages not equal. expected %v obtained %v\n", tt.expected, client.messages)
+			}
+		})
+	}
+}
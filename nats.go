@@ -0,0 +1,65 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// natsReserved holds the characters of aliases escaped in subjects: token separators,
+// wildcards and whitespace
+const natsReserved = ".*> \t\r\n"
+
+// NATSConn is the subset of a NATS connection used by NATSPublisher, satisfied by *nats.Conn
+type NATSConn interface {
+	Publish(subject string, data []byte) error
+}
+
+// NATSPublisher publishes the updates of resources to NATS as JSON WebhookEvent messages,
+// so that backend services receive the same updates as SSE clients
+type NATSPublisher struct {
+	Conn NATSConn
+	// Subject of the messages, "{alias}" being replaced by the alias (defaults to "resources.{alias}").
+	// Dots, wildcards and whitespace of aliases are percent-encoded, e.g. "a.b" becoming "a%2Eb".
+	Subject string
+	// HashOnly leaves the content out of the messages, subscribers fetching it when needed
+	HashOnly bool
+}
+
+// Publish implements Publisher
+func (p *NATSPublisher) Publish(event Event) error {
+	if event.Type != EventUpdated {
+		return nil
+	}
+
+	b, err := json.Marshal(newWebhookEvent(event.Snapshot, event.OldHash, !p.HashOnly))
+	if err != nil {
+		return err
+	}
+
+	subject := p.Subject
+	if subject == "" {
+		subject = "resources.{alias}"
+	}
+
+	return p.Conn.Publish(strings.Replace(subject, "{alias}", escapeAlias(event.Snapshot.Alias, natsReserved), -1), b)
+}
+
+// escapeAlias percent-encodes the reserved characters of an alias, along with "%" so that
+// distinct aliases stay distinct
+func escapeAlias(alias, reserved string) string {
+	if !strings.ContainsAny(alias, reserved+"%") {
+		return alias
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(alias); i++ {
+		if c := alias[i]; c == '%' || strings.IndexByte(reserved, c) >= 0 {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,96 @@
+package routing_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+type natsMessage struct {
+	subject string
+	data    []byte
+}
+
+type fakeNATSConn struct {
+	mu       sync.Mutex
+	messages []natsMessage
+}
+
+func (c *fakeNATSConn) Publish(subject string, data []byte) error {
+	c.mu.Lock()
+	c.messages = append(c.messages, natsMessage{subject, data})
+	c.mu.Unlock()
+	return nil
+}
+
+func TestNATSPublisher(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content"))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		p        *routing.NATSPublisher
+		alias    string
+		subject  string
+		hashOnly bool
+	}{
+		{"default", &routing.NATSPublisher{}, "news", "resources.news", false},
+		{"hash only", &routing.NATSPublisher{Subject: "cache.{alias}.updated", HashOnly: true}, "news", "cache.news.updated", true},
+		{"escaped", &routing.NATSPublisher{}, "eu.news >*%", "resources.eu%2Enews%20%3E%2A%25", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &fakeNATSConn{}
+			tt.p.Conn = conn
+
+			c := routing.NewResourceCacher(routing.WithPublisher(tt.p))
+			res, err := c.AddResource(&routing.Resource{
+				Alias:    tt.alias,
+				Method:   http.MethodGet,
+				URL:      srv.URL,
+				Interval: time.Hour,
+			}, nil)
+			if err != nil {
+				t.Fatalf("add resource error: %s", err)
+			}
+
+			waitPublished(func() bool {
+				conn.mu.Lock()
+				defer conn.mu.Unlock()
+				return len(conn.messages) != 0
+			})
+
+			conn.mu.Lock()
+			defer conn.mu.Unlock()
+
+			if len(conn.messages) != 1 {
+				t.Fatalf("<nats> messages not equal. expected %v obtained %v\n", 1, len(conn.messages))
+			}
+
+			if conn.messages[0].subject != tt.subject {
+				t.Errorf("<nats> subject not equal. expected %v obtained %v\n", tt.subject, conn.messages[0].subject)
+			}
+
+			var event routing.WebhookEvent
+			if err := json.Unmarshal(conn.messages[0].data, &event); err != nil {
+				t.Fatalf("unmarshal error: %s", err)
+			}
+
+			if event.Hash != res.Hash || event.Size != len("content") {
+				t.Errorf("<nats> event not equal. obtained %+v\n", event)
+			}
+
+			if hasPayload := event.Payload != ""; hasPayload == tt.hashOnly {
+				t.Errorf("<nats> payload not expected. hash only %v obtained %q\n", tt.hashOnly, event.Payload)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package routing
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// Option configures a resource cacher
+type Option interface {
+	apply(opts *Options)
+}
+
+type optionFunc func(opts *Options)
+
+func (f optionFunc) apply(opts *Options) {
+	f(opts)
+}
+
+// apply makes Options usable as an Option, replacing all the options applied before it
+func (o *Options) apply(opts *Options) {
+	if o != nil {
+		*opts = *o
+	}
+}
+
+// WithLogger sets the logger
+func WithLogger(logger Logger) Option {
+	return optionFunc(func(opts *Options) {
+		opts.Logger = logger
+	})
+}
+
+// WithEncodings precompresses content with these encodings, in order of preference
+func WithEncodings(encodings ...Encoding) Option {
+	return optionFunc(func(opts *Options) {
+		opts.Encodings = encodings
+	})
+}
+
+// WithMinEncodingSize leaves content smaller than size bytes unencoded
+func WithMinEncodingSize(size int) Option {
+	return optionFunc(func(opts *Options) {
+		opts.MinEncodingSize = size
+	})
+}
+
+// WithStore sets where fetched content is stored
+func WithStore(store CacheStore) Option {
+	return optionFunc(func(opts *Options) {
+		opts.Store = store
+	})
+}
+
+// WithHTTPClient sets the default HTTP client of upstream requests
+func WithHTTPClient(client *http.Client) Option {
+	return optionFunc(func(opts *Options) {
+		opts.HTTPClient = client
+	})
+}
+
+// WithTransport sets the default transport of upstream requests
+func WithTransport(transport http.RoundTripper) Option {
+	return optionFunc(func(opts *Options) {
+		opts.Transport = transport
+	})
+}
+
+// WithTLSConfig sets the default TLS configuration of upstream requests
+func WithTLSConfig(config *tls.Config) Option {
+	return optionFunc(func(opts *Options) {
+		opts.TLSConfig = config
+	})
+}
+
+// WithMaxContentSize sets the default maximum size in bytes of fetched content
+func WithMaxContentSize(size int64) Option {
+	return optionFunc(func(opts *Options) {
+		opts.MaxContentSize = size
+	})
+}
+
+// WithMaxConcurrency bounds the number of upstream fetches running at once
+func WithMaxConcurrency(n int) Option {
+	return optionFunc(func(opts *Options) {
+		opts.MaxConcurrentFetches = n
+	})
+}
+
+// WithTracer instruments fetches and serving with tracing spans
+func WithTracer(tracer Tracer) Option {
+	return optionFunc(func(opts *Options) {
+		opts.Tracer = tracer
+	})
+}
+
+// WithEnvExpansion interpolates ${VAR} environment variables in resource URLs, request headers and auth
+func WithEnvExpansion() Option {
+	return optionFunc(func(opts *Options) {
+		opts.ExpandEnv = true
+	})
+}
+
+// WithAliasFunc maps requests to resource aliases with a custom function
+func WithAliasFunc(fn func(r *http.Request) (string, error)) Option {
+	return optionFunc(func(opts *Options) {
+		opts.AliasFunc = fn
+	})
+}
+
+// WithErrorHandler writes error responses with a custom handler
+func WithErrorHandler(handler ErrorHandler) Option {
+	return optionFunc(func(opts *Options) {
+		opts.ErrorHandler = handler
+	})
+}
+
+// WithAuthenticator controls the access to resources without their own Authenticator or APIKeys
+func WithAuthenticator(auth Authenticator) Option {
+	return optionFunc(func(opts *Options) {
+		opts.Authenticator = auth
+	})
+}
+
+// WithPrincipalFunc identifies the principal of requests to resources restricted to roles
+func WithPrincipalFunc(fn PrincipalFunc) Option {
+	return optionFunc(func(opts *Options) {
+		opts.PrincipalFunc = fn
+	})
+}
+
+// WithCORS sets the default cross-origin configuration of resources
+func WithCORS(cfg *CORSConfig) Option {
+	return optionFunc(func(opts *Options) {
+		opts.CORS = cfg
+	})
+}
+
+// WithOriginRejection customizes the answer to requests from origins not allowed
+func WithOriginRejection(rejection *OriginRejection) Option {
+	return optionFunc(func(opts *Options) {
+		opts.OriginRejection = rejection
+	})
+}
+
+// WithPurger invalidates downstream caches when the content of a resource changes
+func WithPurger(purger Purger) Option {
+	return optionFunc(func(opts *Options) {
+		opts.Purger = purger
+	})
+}
+
+// WithNotifyUnchanged fires OnResourceUpdated and publishes update events after every successful fetch, even when the content did not change
+func WithNotifyUnchanged() Option {
+	return optionFunc(func(opts *Options) {
+		opts.NotifyUnchanged = true
+	})
+}
+
+// WithWebhooks posts an event to webhook URLs when the content of a resource changes
+func WithWebhooks(n *WebhookNotifier) Option {
+	return optionFunc(func(opts *Options) {
+		opts.Webhooks = n
+	})
+}
+
+// WithPublisher adds a publisher receiving the added, updated and removed events of resources
+func WithPublisher(p Publisher) Option {
+	return optionFunc(func(opts *Options) {
+		opts.Publishers = append(opts.Publishers, p)
+	})
+}
+
+// WithElector runs the fetchers only on the instance of a cluster elected leader
+func WithElector(e Elector) Option {
+	return optionFunc(func(opts *Options) {
+		opts.Elector = e
+	})
+}
+
+// WithAdminToken mounts the admin API with RegisterResourceCacher, requests to it and to the
+// status endpoint having to carry token as a bearer token
+func WithAdminToken(token string) Option {
+	return optionFunc(func(opts *Options) {
+		opts.AdminToken = token
+	})
+}
+
+// WithPollTimeout sets the longest wait of long polling requests
+func WithPollTimeout(timeout time.Duration) Option {
+	return optionFunc(func(opts *Options) {
+		opts.PollTimeout = timeout
+	})
+}
+
+// WithDebugHeaders adds an X-Cache header to served responses
+func WithDebugHeaders() Option {
+	return optionFunc(func(opts *Options) {
+		opts.DebugHeaders = true
+	})
+}
@@ -0,0 +1,50 @@
+package routing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestFunctionalOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		opts     []routing.Option
+		expected string
+	}{
+		{"none", nil, ""},
+		{"nil options", []routing.Option{nil}, ""},
+		{"functional", []routing.Option{routing.WithDebugHeaders(), routing.WithStore(routing.NewMemoryStore())}, "HIT"},
+		{"struct", []routing.Option{&routing.Options{DebugHeaders: true}}, "HIT"},
+		{"struct then functional", []routing.Option{&routing.Options{}, routing.WithDebugHeaders()}, "HIT"},
+	}
+
+	for _, test := range tests {
+		c := routing.NewResourceCacher(test.opts...)
+		_, err := c.AddResource(&routing.Resource{
+			Alias:    "options",
+			Method:   http.MethodGet,
+			URL:      srv.URL,
+			Interval: time.Hour,
+		}, nil)
+		if err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?alias=options", nil))
+		c.Stop()
+
+		if xCache := w.Header().Get("X-Cache"); xCache != test.expected {
+			t.Errorf("<response> %s X-Cache not equal. expected %v obtained %v\n", test.name, test.expected, xCache)
+		}
+	}
+}
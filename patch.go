@@ -0,0 +1,194 @@
+package routing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownVersion is returned when a version is neither current nor kept in history
+var ErrUnknownVersion = errors.New("unknown version")
+
+// JSONPatchContentType is the media type of RFC 6902 JSON Patch documents
+const JSONPatchContentType = "application/json-patch+json"
+
+// PatchOperation is an RFC 6902 JSON Patch operation
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// JSONPatch returns the RFC 6902 JSON Patch turning the JSON document a into b
+func JSONPatch(a, b []byte) ([]byte, error) {
+	va, err := decodeJSON(a)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidContent, err)
+	}
+
+	vb, err := decodeJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidContent, err)
+	}
+
+	ops := []PatchOperation{}
+	if err := diffJSON(&ops, "", va, vb); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ops)
+}
+
+// Diff returns the JSON Patch between two versions of the resource content, to
+// defaulting to the current one
+func (r *Resource) Diff(from, to string) ([]byte, error) {
+	a, ok := r.Version(from)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownVersion, from)
+	}
+
+	b := r.Snapshot()
+	if to != "" {
+		if b, ok = r.Version(to); !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownVersion, to)
+		}
+	}
+
+	return JSONPatch(a.Content, b.Content)
+}
+
+// diffJSON appends the operations turning a into b at path
+func diffJSON(ops *[]PatchOperation, path string, a, b interface{}) error {
+	switch va := a.(type) {
+	case map[string]interface{}:
+		if vb, ok := b.(map[string]interface{}); ok {
+			return diffObjects(ops, path, va, vb)
+		}
+	case []interface{}:
+		if vb, ok := b.([]interface{}); ok {
+			return diffArrays(ops, path, va, vb)
+		}
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+
+	return appendOp(ops, "replace", path, b)
+}
+
+// diffObjects appends the operations turning the object a into b, keys in order
+func diffObjects(ops *[]PatchOperation, path string, a, b map[string]interface{}) error {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		va, inA := a[k]
+		vb, inB := b[k]
+		p := path + "/" + escapePointer(k)
+
+		var err error
+		switch {
+		case !inB:
+			err = appendOp(ops, "remove", p, nil)
+		case !inA:
+			err = appendOp(ops, "add", p, vb)
+		default:
+			err = diffJSON(ops, p, va, vb)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// diffArrays appends the operations turning the array a into b element by element,
+// extra elements being removed from the end
+func diffArrays(ops *[]PatchOperation, path string, a, b []interface{}) error {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if err := diffJSON(ops, path+"/"+strconv.Itoa(i), a[i], b[i]); err != nil {
+			return err
+		}
+	}
+
+	for i := len(a) - 1; i >= len(b); i-- {
+		if err := appendOp(ops, "remove", path+"/"+strconv.Itoa(i), nil); err != nil {
+			return err
+		}
+	}
+
+	for i := len(a); i < len(b); i++ {
+		if err := appendOp(ops, "add", path+"/-", b[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// appendOp appends an operation, its value being omitted for removals
+func appendOp(ops *[]PatchOperation, op, path string, value interface{}) error {
+	o := PatchOperation{Op: op, Path: path}
+	if op != "remove" {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		o.Value = b
+	}
+
+	*ops = append(*ops, o)
+
+	return nil
+}
+
+// escapePointer escapes a key as a JSON Pointer reference token
+func escapePointer(key string) string {
+	return strings.Replace(strings.Replace(key, "~", "~0", -1), "/", "~1", -1)
+}
+
+// serveDiff answers ?from=<hash>[&to=<hash>] with the JSON Patch between both versions
+func (c *ResourceCacher) serveDiff(w http.ResponseWriter, r *http.Request, res *Resource, from string) {
+	to := r.URL.Query().Get("to")
+	if to == "" {
+		to = res.Snapshot().Hash
+	}
+
+	patch, err := res.Diff(from, to)
+	switch {
+	case errors.Is(err, ErrUnknownVersion):
+		c.writeError(w, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		c.writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	etag := from + "-" + to
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	writeCommonHeaders(w, r)
+	writeCORSHeaders(w, c.corsFor(res))
+	w.Header().Set("Content-Type", JSONPatchContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(patch)))
+	w.Header().Set("Etag", etag)
+	w.Write(patch)
+}
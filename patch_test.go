@@ -0,0 +1,117 @@
+package routing_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		expected string
+	}{
+		{"equal", `{"a":1}`, `{"a":1}`, `[]`},
+		{"replace", `{"a":1}`, `{"a":2}`, `[{"op":"replace","path":"/a","value":2}]`},
+		{"add and remove", `{"a":1,"b":2}`, `{"b":2,"c":null}`, `[{"op":"remove","path":"/a"},{"op":"add","path":"/c","value":null}]`},
+		{"nested", `{"x":{"y":[1,2,3]}}`, `{"x":{"y":[1,5]}}`, `[{"op":"replace","path":"/x/y/1","value":5},{"op":"remove","path":"/x/y/2"}]`},
+		{"append", `[1]`, `[1,{"k":"v"}]`, `[{"op":"add","path":"/-","value":{"k":"v"}}]`},
+		{"escaped keys", `{"a/b":1,"c~d":1}`, `{"a/b":2,"c~d":2}`, `[{"op":"replace","path":"/a~1b","value":2},{"op":"replace","path":"/c~0d","value":2}]`},
+		{"type change", `{"a":[1]}`, `{"a":{"b":1}}`, `[{"op":"replace","path":"/a","value":{"b":1}}]`},
+		{"root", `1`, `"x"`, `[{"op":"replace","path":"","value":"x"}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := routing.JSONPatch([]byte(tt.a), []byte(tt.b))
+			if err != nil {
+				t.Fatalf("patch error: %s", err)
+			}
+
+			if string(patch) != tt.expected {
+				t.Errorf("<patch> not equal. expected %v obtained %v\n", tt.expected, string(patch))
+			}
+		})
+	}
+
+	if _, err := routing.JSONPatch([]byte(`{`), []byte(`{}`)); err == nil {
+		t.Errorf("<patch> expected error for invalid JSON\n")
+	}
+}
+
+func TestServeDiff(t *testing.T) {
+	var version int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&version) == 0 {
+			w.Write([]byte(`{"count":1}`))
+		} else {
+			w.Write([]byte(`{"count":2}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	res, err := c.AddResource(&routing.Resource{
+		Alias:       "doc",
+		Method:      http.MethodGet,
+		URL:         srv.URL,
+		Interval:    time.Hour,
+		MaxVersions: 5,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	if err := res.Fetch(); err != nil {
+		t.Fatalf("fetch error: %s", err)
+	}
+	first := res.Snapshot().Hash
+
+	atomic.StoreInt32(&version, 1)
+	if err := res.Fetch(); err != nil {
+		t.Fatalf("fetch error: %s", err)
+	}
+	current := res.Snapshot().Hash
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		body   string
+	}{
+		{"to current", "from=" + first, http.StatusOK, `[{"op":"replace","path":"/count","value":2}]`},
+		{"backwards", "from=" + current + "&to=" + first, http.StatusOK, `[{"op":"replace","path":"/count","value":1}]`},
+		{"unknown", "from=nope", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?alias=doc&"+tt.query, nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("<response> status not equal. expected %v obtained %v\n", tt.status, rec.Code)
+			}
+
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			if ct := rec.Header().Get("Content-Type"); ct != routing.JSONPatchContentType {
+				t.Errorf("<response> Content-Type not equal. expected %v obtained %v\n", routing.JSONPatchContentType, ct)
+			}
+
+			body, _ := ioutil.ReadAll(rec.Body)
+			if string(body) != tt.body {
+				t.Errorf("<response> body not equal. expected %v obtained %v\n", tt.body, string(body))
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package routing
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// PayloadEncoding controls how SSE events carry the contents of resources
+type PayloadEncoding string
+
+const (
+	// PayloadAuto sends text contents as is and binary ones in base64
+	PayloadAuto PayloadEncoding = ""
+	// PayloadText sends contents as is
+	PayloadText PayloadEncoding = "text"
+	// PayloadBase64 sends contents in base64 along with their content type
+	PayloadBase64 PayloadEncoding = "base64"
+	// PayloadMetadata sends the alias, hash, content type, size and URL of contents instead of them
+	PayloadMetadata PayloadEncoding = "metadata"
+)
+
+// ssePayload returns the message carrying a content of a resource according to the payload encoding
+func ssePayload(opts *SSEOptions, res *Resource, payload []byte) sseMessage {
+	msg := sseMessage{Alias: res.Alias}
+
+	encoding := opts.PayloadEncoding
+	if encoding == PayloadAuto {
+		encoding = PayloadText
+		if !utf8.Valid(payload) {
+			encoding = PayloadBase64
+		}
+	}
+
+	switch encoding {
+	case PayloadBase64:
+		msg.Encoding = string(PayloadBase64)
+		msg.ContentType = res.Snapshot().Header.Get("Content-Type")
+		msg.Payload = base64.StdEncoding.EncodeToString(payload)
+	case PayloadMetadata:
+		s := res.Snapshot()
+		msg.Encoding = string(PayloadMetadata)
+		msg.ContentType = s.Header.Get("Content-Type")
+		msg.Hash = s.Hash
+		msg.Size = len(payload)
+		if opts.ContentURL != "" {
+			msg.URL = strings.Replace(opts.ContentURL, "{alias}", url.PathEscape(res.Alias), -1)
+		}
+	default:
+		msg.Payload = string(payload)
+	}
+
+	return msg
+}
+
+// encodeSSEData returns the data of an SSE event carrying a content, as is when sent as text
+// and as a JSON message otherwise
+func encodeSSEData(opts *SSEOptions, res *Resource, payload []byte) (string, error) {
+	msg := ssePayload(opts, res, payload)
+	if msg.Encoding == "" {
+		return msg.Payload, nil
+	}
+
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
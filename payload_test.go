@@ -0,0 +1,41 @@
+package routing
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSSEPayloadEncoding(t *testing.T) {
+	png := []byte{0x89, 'P', 'N', 'G', 0xff, 0x00}
+	res := &Resource{
+		Alias:   "logo",
+		Content: png,
+		Header:  http.Header{"Content-Type": {"image/png"}},
+		Hash:    "abc",
+	}
+	res.publish()
+
+	tests := []struct {
+		name     string
+		opts     *SSEOptions
+		content  []byte
+		expected string
+	}{
+		{"auto text", &SSEOptions{}, []byte(`{"status": "ok"}`), `{"status": "ok"}`},
+		{"auto binary", &SSEOptions{}, png, `{"alias":"logo","payload":"iVBOR/8A","encoding":"base64","content_type":"image/png"}`},
+		{"base64", &SSEOptions{PayloadEncoding: PayloadBase64}, []byte("ok"), `{"alias":"logo","payload":"b2s=","encoding":"base64","content_type":"image/png"}`},
+		{"metadata", &SSEOptions{PayloadEncoding: PayloadMetadata, ContentURL: "/resources/{alias}"}, png, `{"alias":"logo","payload":"","encoding":"metadata","content_type":"image/png","hash":"abc","size":6,"url":"/resources/logo"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := encodeSSEData(tt.opts, res, tt.content)
+			if err != nil {
+				t.Fatalf("encode error: %s", err)
+			}
+			if data != tt.expected {
+				t.Errorf("<event> data not equal. expected %v obtained %v\n", tt.expected, data)
+			}
+		})
+	}
+}
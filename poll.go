@@ -0,0 +1,77 @@
+package routing
+
+import (
+	"net/http"
+	"time"
+)
+
+const defaultPollTimeout = 30 * time.Second
+
+// PollHandler returns a long polling handler, e.g. to mount on /resources/poll, for clients
+// unable to use SSE or WebSocket. A request like ?alias=x&since=<hash> is answered with the
+// content of the resource as soon as its hash differs from since, or 304 once PollTimeout elapsed.
+func (c *ResourceCacher) PollHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resource, ok := c.resolveRequest(w, r)
+		if !ok {
+			return
+		}
+
+		if isPreflight(r) {
+			writePreflight(w, r, c.corsFor(resource))
+			return
+		}
+
+		timeout := c.opts.PollTimeout
+		if timeout <= 0 {
+			timeout = defaultPollTimeout
+		}
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		since := r.URL.Query().Get("since")
+		for {
+			// Taken before reading the snapshot so that no update is missed
+			updated := c.updateSignal()
+
+			if snapshot := resource.Snapshot(); snapshot.Hash != "" && snapshot.Hash != since {
+				c.writeSnapshot(w, r, resource, snapshot)
+				return
+			}
+
+			select {
+			case <-updated:
+			case <-timer.C:
+				w.Header().Set("Etag", since)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+}
+
+// updateSignal returns a channel closed on the next update of any resource
+func (c *ResourceCacher) updateSignal() <-chan struct{} {
+	c.updatedMu.Lock()
+	defer c.updatedMu.Unlock()
+
+	if c.updated == nil {
+		c.updated = make(chan struct{})
+	}
+
+	return c.updated
+}
+
+// signalUpdate wakes up the long polling requests
+func (c *ResourceCacher) signalUpdate() {
+	c.updatedMu.Lock()
+	defer c.updatedMu.Unlock()
+
+	if c.updated != nil {
+		close(c.updated)
+		c.updated = nil
+	}
+}
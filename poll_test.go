@@ -0,0 +1,74 @@
+package routing_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestPollHandler(t *testing.T) {
+	var version int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&version) == 0 {
+			w.Write([]byte("v0"))
+		} else {
+			w.Write([]byte("v1"))
+		}
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(routing.WithPollTimeout(100 * time.Millisecond))
+	res, err := c.AddResource(&routing.Resource{
+		Alias:    "news",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Hour,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	mux := http.NewServeMux()
+	routing.RegisterResourceCacher(mux, "", c)
+
+	poll := func(since string) (*httptest.ResponseRecorder, time.Duration) {
+		start := time.Now()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/resources/poll?alias=news&since="+since, nil))
+		return rec, time.Since(start)
+	}
+
+	// Answered right away when the client is behind
+	rec, _ := poll("")
+	if body, _ := ioutil.ReadAll(rec.Body); rec.Code != http.StatusOK || string(body) != "v0" {
+		t.Errorf("<poll> response not equal. expected %v %v obtained %v %v\n", http.StatusOK, "v0", rec.Code, string(body))
+	}
+
+	// Times out when nothing changes
+	hash := res.Snapshot().Hash
+	rec, elapsed := poll(hash)
+	if rec.Code != http.StatusNotModified || elapsed < 100*time.Millisecond {
+		t.Errorf("<poll> timeout not equal. expected %v after 100ms obtained %v after %v\n", http.StatusNotModified, rec.Code, elapsed)
+	}
+
+	// Woken up by an update
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		atomic.StoreInt32(&version, 1)
+		c.ForceRefresh("news")
+	}()
+
+	rec, elapsed = poll(hash)
+	if body, _ := ioutil.ReadAll(rec.Body); rec.Code != http.StatusOK || string(body) != "v1" {
+		t.Errorf("<poll> response not equal. expected %v %v obtained %v %v\n", http.StatusOK, "v1", rec.Code, string(body))
+	}
+
+	if elapsed >= 100*time.Millisecond {
+		t.Errorf("<poll> update not delivered before the timeout, took %v\n", elapsed)
+	}
+}
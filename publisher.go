@@ -0,0 +1,144 @@
+package routing
+
+import "sync"
+
+// publisherQueueSize bounds the events pending for a publisher, newer ones being dropped
+const publisherQueueSize = 256
+
+// EventType tells what happened to a resource
+type EventType string
+
+// Types of the events delivered to publishers
+const (
+	EventAdded   EventType = "added"
+	EventUpdated EventType = "updated"
+	EventRemoved EventType = "removed"
+)
+
+// Event describes a change of a resource, Snapshot being its content when the event fired.
+// Events are delivered asynchronously: fields of Resource other than its definition may
+// have moved on, Changed and OldHash being their values when the event fired.
+type Event struct {
+	Type     EventType
+	Resource *Resource
+	Snapshot *Snapshot
+
+	Changed bool
+	OldHash string
+}
+
+// Publisher receives the events of the resources of a cacher, e.g. to fan them out to a message bus
+type Publisher interface {
+	Publish(event Event) error
+}
+
+// PublisherFunc is a function implementing Publisher
+type PublisherFunc func(event Event) error
+
+// Publish calls f(event)
+func (f PublisherFunc) Publish(event Event) error {
+	return f(event)
+}
+
+// publisherQueue delivers the events of a publisher in order from its own goroutine, so that
+// slow publishers hold up neither the fetches nor the other publishers
+type publisherQueue struct {
+	publisher Publisher
+	events    []Event
+	running   bool
+	mu        sync.Mutex
+}
+
+// AddPublisher registers a publisher, in addition to Options.Publishers
+func (c *ResourceCacher) AddPublisher(p Publisher) {
+	c.publishersMu.Lock()
+	c.publishers = append(c.publishers, &publisherQueue{publisher: p})
+	c.publishersMu.Unlock()
+}
+
+// publish queues an event for every publisher
+func (c *ResourceCacher) publish(t EventType, res *Resource) {
+	c.publishersMu.RLock()
+	publishers := c.publishers
+	c.publishersMu.RUnlock()
+
+	if len(publishers) == 0 {
+		return
+	}
+
+	event := Event{Type: t, Resource: res, Snapshot: res.Snapshot(), Changed: res.Changed(), OldHash: res.OldHash}
+	for _, q := range publishers {
+		c.enqueue(q, event)
+	}
+}
+
+// enqueue appends an event to the queue of a publisher, starting its delivery when idle
+func (c *ResourceCacher) enqueue(q *publisherQueue, event Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.events) >= publisherQueueSize {
+		c.opts.Logger.Warn("publisher queue full, event dropped", F("alias", event.Snapshot.Alias), F("event", string(event.Type)))
+		return
+	}
+
+	q.events = append(q.events, event)
+	if !q.running {
+		q.running = true
+		go c.deliver(q)
+	}
+}
+
+// deliver sends the queued events to a publisher until its queue is empty, logging failures
+func (c *ResourceCacher) deliver(q *publisherQueue) {
+	for {
+		q.mu.Lock()
+		if len(q.events) == 0 {
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+
+		event := q.events[0]
+		q.events[0] = Event{}
+		q.events = q.events[1:]
+		q.mu.Unlock()
+
+		if err := q.publisher.Publish(event); err != nil {
+			c.opts.Logger.Warn("publish failed", F("alias", event.Snapshot.Alias), F("event", string(event.Type)), F("error", err))
+		}
+	}
+}
+
+// resourceAdded fires OnResourceAdded and publishes the event
+func (c *ResourceCacher) resourceAdded(res *Resource) {
+	if c.OnResourceAdded != nil {
+		c.OnResourceAdded(res)
+	}
+
+	c.publish(EventAdded, res)
+}
+
+// resourceUpdated fires OnResourceUpdated and publishes the event when the content changed,
+// or on every fetch with NotifyUnchanged
+func (c *ResourceCacher) resourceUpdated(res *Resource) {
+	if !res.Changed() && !c.opts.NotifyUnchanged {
+		return
+	}
+
+	if c.OnResourceUpdated != nil {
+		c.OnResourceUpdated(res)
+	}
+
+	c.signalUpdate()
+	c.publish(EventUpdated, res)
+}
+
+// resourceRemoved fires OnResourceRemoved and publishes the event
+func (c *ResourceCacher) resourceRemoved(res *Resource) {
+	if c.OnResourceRemoved != nil {
+		c.OnResourceRemoved(res)
+	}
+
+	c.publish(EventRemoved, res)
+}
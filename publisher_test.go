@@ -0,0 +1,155 @@
+package routing_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+// waitPublished waits for the events delivered in the background until done or a second elapsed
+func waitPublished(done func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !done() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	var version int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&version) == 0 {
+			w.Write([]byte("first"))
+		} else {
+			w.Write([]byte("second"))
+		}
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var events []string
+	record := routing.PublisherFunc(func(event routing.Event) error {
+		mu.Lock()
+		events = append(events, string(event.Type)+" "+event.Snapshot.Alias+" "+string(event.Snapshot.Content))
+		mu.Unlock()
+		return nil
+	})
+
+	failing := routing.PublisherFunc(func(event routing.Event) error {
+		return errors.New("unavailable")
+	})
+
+	c := routing.NewResourceCacher(routing.WithPublisher(failing), routing.WithPublisher(record))
+
+	res, err := c.AddResource(&routing.Resource{
+		Alias:    "news",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Hour,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	// Unchanged content is not published
+	if err := res.Fetch(); err != nil {
+		t.Fatalf("fetch error: %s", err)
+	}
+
+	atomic.StoreInt32(&version, 1)
+	if err := res.Fetch(); err != nil {
+		t.Fatalf("fetch error: %s", err)
+	}
+
+	if _, err := c.RemoveResource("news"); err != nil {
+		t.Fatalf("remove resource error: %s", err)
+	}
+
+	expected := []string{"added news ", "updated news first", "updated news second", "removed news second"}
+
+	waitPublished(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events) == len(expected)
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("<publisher> events not equal. expected %v obtained %v\n", expected, events)
+	}
+}
+
+func TestSSEPublisherKeepsHooks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content"))
+	}))
+	defer srv.Close()
+
+	c := routing.NewSSEResourceCacher(nil)
+
+	var updates int32
+	c.OnResourceUpdated = func(res *routing.Resource) {
+		atomic.AddInt32(&updates, 1)
+	}
+
+	if _, err := c.AddResource(&routing.Resource{
+		Alias:    "news",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Hour,
+	}, nil); err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	if u := atomic.LoadInt32(&updates); u != 1 {
+		t.Errorf("<cacher> updates not equal. expected %v obtained %v\n", 1, u)
+	}
+}
+
+func TestSlowPublisher(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(time.Now().String()))
+	}))
+	defer srv.Close()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	blocked := routing.PublisherFunc(func(event routing.Event) error {
+		<-release
+		return nil
+	})
+
+	c := routing.NewResourceCacher(routing.WithPublisher(blocked))
+
+	done := make(chan error)
+	go func() {
+		res, err := c.AddResource(&routing.Resource{
+			Alias:    "clock",
+			Method:   http.MethodGet,
+			URL:      srv.URL,
+			Interval: time.Hour,
+		}, nil)
+		if err == nil {
+			err = res.Fetch()
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("fetch error: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("<publisher> fetch held up by a blocked publisher\n")
+	}
+}
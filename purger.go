@@ -0,0 +1,168 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Purger invalidates the copies of a resource held by downstream caches such as CDNs
+type Purger interface {
+	Purge(ctx context.Context, s *Snapshot) error
+}
+
+// PurgerFunc is a function implementing Purger
+type PurgerFunc func(ctx context.Context, s *Snapshot) error
+
+// Purge calls f(ctx, s)
+func (f PurgerFunc) Purge(ctx context.Context, s *Snapshot) error {
+	return f(ctx, s)
+}
+
+// purge invalidates downstream caches in the background once the content of a resource changed
+func (c *ResourceCacher) purge(res *Resource) {
+	if c.opts.Purger == nil || res.OldHash == "" || !res.Changed() {
+		return
+	}
+
+	s := res.Snapshot()
+
+	res.inflight.Add(1)
+	go func() {
+		defer res.inflight.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+		defer cancel()
+
+		if err := c.opts.Purger.Purge(ctx, s); err != nil {
+			c.opts.Logger.Warn("purge failed", F("alias", s.Alias), F("error", err))
+		}
+	}()
+}
+
+// purgeURL expands the {alias} placeholder of a public resource URL
+func purgeURL(template string, s *Snapshot) string {
+	return strings.Replace(template, "{alias}", s.Alias, -1)
+}
+
+// sendPurge sends a purge request, failing on non 2xx answers
+func sendPurge(ctx context.Context, client *http.Client, method, url string, header http.Header, body []byte) error {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, r)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("purge %s: unexpected status %d", url, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// HTTPPurger sends an HTTP PURGE request for the public URL of the resource, as
+// understood by Varnish, Nginx or Squid
+type HTTPPurger struct {
+	// URL of the resource behind the cache, "{alias}" being replaced by the alias
+	// (e.g. "https://cdn.example.com/resources/{alias}")
+	URL string
+	// Method of the request (defaults to PURGE)
+	Method string
+	// Header is sent with the request, e.g. a purge secret
+	Header http.Header
+	// Client sends the request (defaults to http.DefaultClient)
+	Client *http.Client
+}
+
+// Purge implements Purger
+func (p *HTTPPurger) Purge(ctx context.Context, s *Snapshot) error {
+	method := p.Method
+	if method == "" {
+		method = "PURGE"
+	}
+
+	return sendPurge(ctx, p.Client, method, purgeURL(p.URL, s), p.Header, nil)
+}
+
+// FastlyPurger purges the public URL of the resource from Fastly
+type FastlyPurger struct {
+	// URL of the resource on Fastly, "{alias}" being replaced by the alias
+	URL string
+	// APIToken of a Fastly user allowed to purge
+	APIToken string
+	// SoftPurge marks the content stale instead of evicting it
+	SoftPurge bool
+	// Client sends the request (defaults to http.DefaultClient)
+	Client *http.Client
+}
+
+// Purge implements Purger
+func (p *FastlyPurger) Purge(ctx context.Context, s *Snapshot) error {
+	header := http.Header{"Fastly-Key": {p.APIToken}}
+	if p.SoftPurge {
+		header.Set("Fastly-Soft-Purge", "1")
+	}
+
+	return sendPurge(ctx, p.Client, "PURGE", purgeURL(p.URL, s), header, nil)
+}
+
+// cloudflareAPI is the base URL of the Cloudflare API
+const cloudflareAPI = "https://api.cloudflare.com/client/v4"
+
+// CloudflarePurger purges the public URL of the resource from a Cloudflare zone
+type CloudflarePurger struct {
+	// ZoneID of the Cloudflare zone serving the resources
+	ZoneID string
+	// APIToken with the Cache Purge permission on the zone
+	APIToken string
+	// URL of the resource on Cloudflare, "{alias}" being replaced by the alias
+	URL string
+	// Endpoint of the Cloudflare API (defaults to https://api.cloudflare.com/client/v4)
+	Endpoint string
+	// Client sends the request (defaults to http.DefaultClient)
+	Client *http.Client
+}
+
+// Purge implements Purger
+func (p *CloudflarePurger) Purge(ctx context.Context, s *Snapshot) error {
+	endpoint := p.Endpoint
+	if endpoint == "" {
+		endpoint = cloudflareAPI
+	}
+
+	body, err := json.Marshal(map[string][]string{"files": {purgeURL(p.URL, s)}})
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{
+		"Authorization": {"Bearer " + p.APIToken},
+		"Content-Type":  {"application/json"},
+	}
+
+	return sendPurge(ctx, p.Client, http.MethodPost, endpoint+"/zones/"+p.ZoneID+"/purge_cache", header, body)
+}
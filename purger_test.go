@@ -0,0 +1,114 @@
+package routing_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestPurgeOnChange(t *testing.T) {
+	var version int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&version) == 0 {
+			w.Write([]byte(`{"version": 0}`))
+			return
+		}
+		w.Write([]byte(`{"version": 1}`))
+	}))
+	defer srv.Close()
+
+	purged := make(chan string, 10)
+	c := routing.NewResourceCacher(routing.WithPurger(routing.PurgerFunc(func(ctx context.Context, s *routing.Snapshot) error {
+		purged <- s.Alias
+		return nil
+	})))
+
+	if _, err := c.AddResource(&routing.Resource{
+		Alias:    "purged",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Hour,
+	}, nil); err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	// Unchanged content is not purged
+	if err := c.ForceRefresh("purged"); err != nil {
+		t.Fatalf("refresh error: %s", err)
+	}
+
+	atomic.StoreInt32(&version, 1)
+	if err := c.ForceRefresh("purged"); err != nil {
+		t.Fatalf("refresh error: %s", err)
+	}
+
+	select {
+	case alias := <-purged:
+		if alias != "purged" {
+			t.Errorf("<purge> alias not equal. expected %v obtained %v\n", "purged", alias)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("<purge> expected a purge after content change")
+	}
+
+	select {
+	case <-purged:
+		t.Errorf("<purge> expected a single purge\n")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPurgers(t *testing.T) {
+	type request struct {
+		method, path string
+		header       http.Header
+		body         map[string][]string
+	}
+
+	requests := make(chan request, 1)
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string][]string
+		json.NewDecoder(r.Body).Decode(&body)
+		requests <- request{r.Method, r.URL.Path, r.Header, body}
+	}))
+	defer cdn.Close()
+
+	snapshot := &routing.Snapshot{Alias: "image1"}
+
+	if err := (&routing.HTTPPurger{URL: cdn.URL + "/resources/{alias}"}).Purge(context.Background(), snapshot); err != nil {
+		t.Fatalf("http purge error: %s", err)
+	}
+	if req := <-requests; req.method != "PURGE" || req.path != "/resources/image1" {
+		t.Errorf("<http> request not equal. expected PURGE /resources/image1 obtained %s %s\n", req.method, req.path)
+	}
+
+	if err := (&routing.FastlyPurger{URL: cdn.URL + "/resources/{alias}", APIToken: "t0ken", SoftPurge: true}).Purge(context.Background(), snapshot); err != nil {
+		t.Fatalf("fastly purge error: %s", err)
+	}
+	if req := <-requests; req.method != "PURGE" || req.header.Get("Fastly-Key") != "t0ken" || req.header.Get("Fastly-Soft-Purge") != "1" {
+		t.Errorf("<fastly> request not equal. obtained %s %v\n", req.method, req.header)
+	}
+
+	cloudflare := &routing.CloudflarePurger{
+		ZoneID:   "zone",
+		APIToken: "t0ken",
+		URL:      "https://cdn.example.com/resources/{alias}",
+		Endpoint: cdn.URL,
+	}
+	if err := cloudflare.Purge(context.Background(), snapshot); err != nil {
+		t.Fatalf("cloudflare purge error: %s", err)
+	}
+	req := <-requests
+	if req.method != http.MethodPost || req.path != "/zones/zone/purge_cache" || req.header.Get("Authorization") != "Bearer t0ken" {
+		t.Errorf("<cloudflare> request not equal. obtained %s %s %v\n", req.method, req.path, req.header)
+	}
+	if files := req.body["files"]; len(files) != 1 || files[0] != "https://cdn.example.com/resources/image1" {
+		t.Errorf("<cloudflare> files not equal. expected [https://cdn.example.com/resources/image1] obtained %v\n", files)
+	}
+}
@@ -0,0 +1,269 @@
+package routing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// redisMissTTL is how long an alias missing from Redis is remembered locally
+	redisMissTTL = 5 * time.Second
+	// redisErrorTTL is how long a failed Redis read is returned again without retrying
+	redisErrorTTL = 5 * time.Second
+	// redisMaxSubscribeBackoff caps the delay between subscription attempts
+	redisMaxSubscribeBackoff = 30 * time.Second
+)
+
+// RedisClient is the subset of a Redis client used by RedisStore, typically a thin
+// adapter around go-redis or redigo
+type RedisClient interface {
+	// Get returns the value of key, nil when it does not exist
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte) error
+	Del(ctx context.Context, key string) error
+	Publish(ctx context.Context, channel string, message []byte) error
+	// Subscribe calls handler for every message published on channel until ctx is done
+	Subscribe(ctx context.Context, channel string, handler func(message []byte)) error
+}
+
+// RedisStore is a CacheStore shared by several ResourceCacher instances through Redis.
+// Entries are kept locally and invalidated through Redis pub/sub when another instance
+// updates them, missing ones and read errors being remembered for a few seconds so that an
+// unreachable Redis is not queried over and over. Subscription failures are logged by the
+// cacher using the store and retried, the local entries being dropped.
+type RedisStore struct {
+	client  RedisClient
+	prefix  string
+	id      string
+	timeout time.Duration
+	logger  Logger
+	logMu   sync.Mutex
+
+	local    map[string]*CacheEntry
+	misses   map[string]time.Time
+	err      error
+	erredAt  time.Time
+	watchers []func(alias string)
+	mu       sync.RWMutex
+	cancel   context.CancelFunc
+}
+
+// NewRedisStore creates a new Redis cache store, keys being prefixed with prefix (defaults to "routing:")
+func NewRedisStore(client RedisClient, prefix string) *RedisStore {
+	if prefix == "" {
+		prefix = "routing:"
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &RedisStore{
+		client:  client,
+		prefix:  prefix,
+		id:      hex.EncodeToString(id),
+		timeout: defaultTimeout,
+		local:   make(map[string]*CacheEntry),
+		misses:  make(map[string]time.Time),
+		logger:  NopLogger(),
+		cancel:  cancel,
+	}
+
+	go s.subscribe(ctx)
+
+	return s
+}
+
+// setLogger is called by the resource cacher using the store
+func (s *RedisStore) setLogger(logger Logger) {
+	s.logMu.Lock()
+	s.logger = logger
+	s.logMu.Unlock()
+}
+
+func (s *RedisStore) log() Logger {
+	s.logMu.Lock()
+	defer s.logMu.Unlock()
+
+	return s.logger
+}
+
+// subscribe listens to invalidations until ctx is done, subscribing again after failures
+func (s *RedisStore) subscribe(ctx context.Context) {
+	backoff := defaultRetryBackoff
+
+	for {
+		start := time.Now()
+		err := s.client.Subscribe(ctx, s.channel(), s.invalidate)
+		if ctx.Err() != nil {
+			return
+		}
+
+		s.log().Warn("redis subscription failed", F("channel", s.channel()), F("error", err))
+
+		// Invalidations may have been missed
+		s.mu.Lock()
+		s.local = make(map[string]*CacheEntry)
+		s.misses = make(map[string]time.Time)
+		s.mu.Unlock()
+		s.notify("")
+
+		if time.Since(start) > redisMaxSubscribeBackoff {
+			backoff = defaultRetryBackoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > redisMaxSubscribeBackoff {
+			backoff = redisMaxSubscribeBackoff
+		}
+	}
+}
+
+// Get returns the entry of an alias
+func (s *RedisStore) Get(alias string) (*CacheEntry, error) {
+	s.mu.RLock()
+	entry, ok := s.local[alias]
+	missed, isMissing := s.misses[alias]
+	lastErr, erredAt := s.err, s.erredAt
+	s.mu.RUnlock()
+	if ok {
+		return entry, nil
+	}
+
+	if isMissing && time.Since(missed) < redisMissTTL {
+		return nil, ErrCacheMiss
+	}
+
+	if lastErr != nil && time.Since(erredAt) < redisErrorTTL {
+		return nil, lastErr
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	b, err := s.client.Get(ctx, s.prefix+alias)
+
+	s.mu.Lock()
+	s.err, s.erredAt = err, time.Now()
+	s.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if b == nil {
+		s.mu.Lock()
+		s.misses[alias] = time.Now()
+		s.mu.Unlock()
+		return nil, ErrCacheMiss
+	}
+
+	entry = &CacheEntry{}
+	if err := json.Unmarshal(b, entry); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.local[alias] = entry
+	delete(s.misses, alias)
+	s.mu.Unlock()
+
+	return entry, nil
+}
+
+// Set stores the entry of an alias and invalidates it on the other instances when its hash changed
+func (s *RedisStore) Set(alias string, entry *CacheEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	if err := s.client.Set(ctx, s.prefix+alias, b); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	previous, ok := s.local[alias]
+	s.local[alias] = entry
+	delete(s.misses, alias)
+	s.mu.Unlock()
+
+	if ok && previous.Hash == entry.Hash {
+		return nil
+	}
+
+	return s.client.Publish(ctx, s.channel(), []byte(s.id+" "+alias))
+}
+
+// Delete removes the entry of an alias and invalidates it on the other instances
+func (s *RedisStore) Delete(alias string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	if err := s.client.Del(ctx, s.prefix+alias); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.local, alias)
+	s.mu.Unlock()
+
+	return s.client.Publish(ctx, s.channel(), []byte(s.id+" "+alias))
+}
+
+// Close stops listening to invalidations
+func (s *RedisStore) Close() error {
+	s.cancel()
+	return nil
+}
+
+func (s *RedisStore) channel() string {
+	return s.prefix + "invalidate"
+}
+
+// invalidate drops the local entry of an alias updated by another instance
+func (s *RedisStore) invalidate(message []byte) {
+	parts := strings.SplitN(string(message), " ", 2)
+	if len(parts) != 2 || parts[0] == s.id {
+		return
+	}
+
+	s.mu.Lock()
+	delete(s.local, parts[1])
+	delete(s.misses, parts[1])
+	s.mu.Unlock()
+
+	s.notify(parts[1])
+}
+
+// Watch implements CacheStoreWatcher, updated being called with the aliases invalidated
+// by other instances
+func (s *RedisStore) Watch(updated func(alias string)) {
+	s.mu.Lock()
+	s.watchers = append(s.watchers, updated)
+	s.mu.Unlock()
+}
+
+func (s *RedisStore) notify(alias string) {
+	s.mu.RLock()
+	watchers := s.watchers
+	s.mu.RUnlock()
+
+	for _, updated := range watchers {
+		updated(alias)
+	}
+}
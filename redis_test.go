@@ -0,0 +1,313 @@
+package routing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+// fakeRedis is an in-memory RedisClient shared by several stores
+type fakeRedis struct {
+	mu          sync.Mutex
+	values      map[string][]byte
+	subscribers map[string][]func(message []byte)
+	subscribed  sync.WaitGroup
+	gets        int
+	getErr      error
+}
+
+func newFakeRedis(subscribers int) *fakeRedis {
+	r := &fakeRedis{
+		values:      make(map[string][]byte),
+		subscribers: make(map[string][]func(message []byte)),
+	}
+	r.subscribed.Add(subscribers)
+	return r
+}
+
+func (r *fakeRedis) Get(ctx context.Context, key string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gets++
+	if r.getErr != nil {
+		return nil, r.getErr
+	}
+	return r.values[key], nil
+}
+
+func (r *fakeRedis) Set(ctx context.Context, key string, value []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[key] = value
+	return nil
+}
+
+func (r *fakeRedis) Del(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.values, key)
+	return nil
+}
+
+func (r *fakeRedis) Publish(ctx context.Context, channel string, message []byte) error {
+	r.mu.Lock()
+	handlers := r.subscribers[channel]
+	r.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(message)
+	}
+	return nil
+}
+
+func (r *fakeRedis) Subscribe(ctx context.Context, channel string, handler func(message []byte)) error {
+	r.mu.Lock()
+	r.subscribers[channel] = append(r.subscribers[channel], handler)
+	r.mu.Unlock()
+	r.subscribed.Done()
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRedisStore(t *testing.T) {
+	redis := newFakeRedis(2)
+
+	a := routing.NewRedisStore(redis, "")
+	defer a.Close()
+	b := routing.NewRedisStore(redis, "")
+	defer b.Close()
+
+	redis.subscribed.Wait()
+
+	if _, err := b.Get("shared"); err != routing.ErrCacheMiss {
+		t.Errorf("<store> expected cache miss obtained %v\n", err)
+	}
+
+	for _, content := range []string{"first", "second"} {
+		err := a.Set("shared", &routing.CacheEntry{
+			Content:    []byte(content),
+			Header:     http.Header{"Content-Type": []string{"text/plain"}},
+			StatusCode: http.StatusOK,
+			Hash:       content + "-hash",
+		})
+		if err != nil {
+			t.Fatalf("set error: %s", err)
+		}
+
+		entry, err := b.Get("shared")
+		if err != nil {
+			t.Fatalf("get error: %s", err)
+		}
+
+		if string(entry.Content) != content || entry.Hash != content+"-hash" || entry.Header.Get("Content-Type") != "text/plain" {
+			t.Errorf("<store> entry not equal. expected %s obtained %s %s\n", content, entry.Content, entry.Hash)
+		}
+	}
+
+	if err := a.Delete("shared"); err != nil {
+		t.Fatalf("delete error: %s", err)
+	}
+
+	if _, err := b.Get("shared"); err != routing.ErrCacheMiss {
+		t.Errorf("<store> expected cache miss after delete obtained %v\n", err)
+	}
+}
+
+func TestRedisStoreSharedServing(t *testing.T) {
+	redis := newFakeRedis(2)
+
+	fetching := routing.NewRedisStore(redis, "")
+	defer fetching.Close()
+	following := routing.NewRedisStore(redis, "")
+	defer following.Close()
+
+	redis.subscribed.Wait()
+
+	newResource := func(content string, err error) *routing.Resource {
+		return &routing.Resource{
+			Alias:    "clock",
+			Interval: time.Hour,
+			Fetcher: routing.FetcherFunc(func(ctx context.Context) ([]byte, http.Header, int, error) {
+				return []byte(content), nil, http.StatusOK, err
+			}),
+		}
+	}
+
+	a := routing.NewResourceCacher(&routing.Options{Store: fetching})
+	if _, err := a.AddResource(newResource("12:00", nil), nil); err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	// The second instance does not fetch anything itself
+	b := routing.NewResourceCacher(&routing.Options{Store: following})
+	if _, err := b.AddResource(newResource("", errors.New("not fetching")), nil); err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/?alias=clock", nil)
+	w := httptest.NewRecorder()
+	b.ServeHTTP(w, req)
+
+	if w.Body.String() != "12:00" {
+		t.Errorf("<response> shared content not equal. expected 12:00 obtained %s\n", w.Body.String())
+	}
+}
+
+func TestRedisStoreMissCached(t *testing.T) {
+	redis := newFakeRedis(2)
+
+	a := routing.NewRedisStore(redis, "")
+	defer a.Close()
+	b := routing.NewRedisStore(redis, "")
+	defer b.Close()
+
+	redis.subscribed.Wait()
+
+	for i := 0; i < 3; i++ {
+		if _, err := b.Get("missing"); err != routing.ErrCacheMiss {
+			t.Fatalf("<store> expected cache miss obtained %v\n", err)
+		}
+	}
+
+	redis.mu.Lock()
+	gets := redis.gets
+	redis.mu.Unlock()
+	if gets != 1 {
+		t.Errorf("<redis> gets not equal. expected %v obtained %v\n", 1, gets)
+	}
+
+	// Updates of other instances invalidate the miss
+	if err := a.Set("missing", &routing.CacheEntry{Content: []byte("found")}); err != nil {
+		t.Fatalf("set error: %s", err)
+	}
+
+	if entry, err := b.Get("missing"); err != nil || string(entry.Content) != "found" {
+		t.Errorf("<store> entry not equal. expected found obtained %v (%v)\n", entry, err)
+	}
+}
+
+// droppingRedis fails its first subscription once drop is closed
+type droppingRedis struct {
+	*fakeRedis
+	drop     chan struct{}
+	attempts int32
+}
+
+func (r *droppingRedis) Subscribe(ctx context.Context, channel string, handler func(message []byte)) error {
+	if atomic.AddInt32(&r.attempts, 1) > 1 {
+		return r.fakeRedis.Subscribe(ctx, channel, handler)
+	}
+
+	r.fakeRedis.subscribed.Done()
+	<-r.drop
+	return errors.New("connection reset")
+}
+
+// warnLogger records the warnings
+type warnLogger struct {
+	warnings chan string
+}
+
+func (l *warnLogger) Debug(msg string, fields ...routing.Field) {}
+func (l *warnLogger) Info(msg string, fields ...routing.Field)  {}
+func (l *warnLogger) Warn(msg string, fields ...routing.Field)  { l.warnings <- msg }
+func (l *warnLogger) Error(msg string, fields ...routing.Field) {}
+
+func TestRedisStoreResubscribe(t *testing.T) {
+	redis := &droppingRedis{fakeRedis: newFakeRedis(2), drop: make(chan struct{})}
+
+	store := routing.NewRedisStore(redis, "")
+	defer store.Close()
+
+	logger := &warnLogger{warnings: make(chan string, 1)}
+	routing.NewResourceCacher(routing.WithStore(store), routing.WithLogger(logger))
+
+	if err := store.Set("clock", &routing.CacheEntry{Content: []byte("12:00")}); err != nil {
+		t.Fatalf("set error: %s", err)
+	}
+
+	// Updated by another instance while the subscription is down
+	redis.Set(context.Background(), "routing:clock", []byte(`{"content":"MTM6MDA="}`))
+	close(redis.drop)
+
+	select {
+	case msg := <-logger.warnings:
+		if msg != "redis subscription failed" {
+			t.Errorf("<logger> warning not equal. expected %v obtained %v\n", "redis subscription failed", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("<logger> subscription failure not logged\n")
+	}
+
+	redis.subscribed.Wait()
+
+	if entry, err := store.Get("clock"); err != nil || string(entry.Content) != "13:00" {
+		t.Errorf("<store> entry not equal. expected 13:00 obtained %v (%v)\n", entry, err)
+	}
+}
+
+func TestRedisStoreWatch(t *testing.T) {
+	redis := newFakeRedis(2)
+
+	a := routing.NewRedisStore(redis, "")
+	defer a.Close()
+	b := routing.NewRedisStore(redis, "")
+	defer b.Close()
+
+	redis.subscribed.Wait()
+
+	var updated []string
+	b.Watch(func(alias string) {
+		updated = append(updated, alias)
+	})
+
+	if err := a.Set("clock", &routing.CacheEntry{Content: []byte("12:00"), Hash: "12:00-hash"}); err != nil {
+		t.Fatalf("set error: %s", err)
+	}
+
+	// Unchanged content is not notified
+	if err := a.Set("clock", &routing.CacheEntry{Content: []byte("12:00"), Hash: "12:00-hash"}); err != nil {
+		t.Fatalf("set error: %s", err)
+	}
+
+	// Updates made by the instance itself are not notified
+	if err := b.Set("weather", &routing.CacheEntry{Content: []byte("sunny"), Hash: "sunny-hash"}); err != nil {
+		t.Fatalf("set error: %s", err)
+	}
+
+	if len(updated) != 1 || updated[0] != "clock" {
+		t.Errorf("<watch> updated aliases not equal. expected %v obtained %v\n", []string{"clock"}, updated)
+	}
+}
+
+func TestRedisStoreErrorCached(t *testing.T) {
+	redis := newFakeRedis(1)
+	redis.getErr = errors.New("connection refused")
+
+	store := routing.NewRedisStore(redis, "")
+	defer store.Close()
+
+	redis.subscribed.Wait()
+
+	// Reads fail fast while Redis is unreachable
+	for _, alias := range []string{"clock", "weather", "clock"} {
+		if _, err := store.Get(alias); err == nil || err.Error() != "connection refused" {
+			t.Fatalf("<store> expected connection error obtained %v\n", err)
+		}
+	}
+
+	redis.mu.Lock()
+	gets := redis.gets
+	redis.mu.Unlock()
+	if gets != 1 {
+		t.Errorf("<redis> gets not equal. expected %v obtained %v\n", 1, gets)
+	}
+}
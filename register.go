@@ -0,0 +1,75 @@
+package routing
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Router is implemented by routers mounting handlers on a pattern, such as *http.ServeMux.
+// Patterns ending with a slash match every path under them, as with http.ServeMux.
+type Router interface {
+	Handle(pattern string, handler http.Handler)
+}
+
+// RouterFunc adapts a function to a Router, for routers whose Handle method has another
+// signature or matches exact paths only. With gorilla/mux:
+//
+//	routing.RouterFunc(func(pattern string, h http.Handler) {
+//		if strings.HasSuffix(pattern, "/") {
+//			r.PathPrefix(pattern).Handler(h)
+//			return
+//		}
+//		r.Handle(pattern, h)
+//	})
+type RouterFunc func(pattern string, handler http.Handler)
+
+// Handle calls f(pattern, handler)
+func (f RouterFunc) Handle(pattern string, handler http.Handler) {
+	f(pattern, handler)
+}
+
+// Middleware wraps a handler, matching the signature of gorilla/mux and chi middlewares
+type Middleware func(http.Handler) http.Handler
+
+// RegisterResourceCacher mounts a resource cacher (or one of its SSE variants) on router
+// under prefix, wrapped by the given middlewares:
+//
+//	{prefix}/resources/{alias}  cached resources, or their event stream for SSE variants
+//	{prefix}/resources/poll     long polling fallback, see PollHandler
+//	{prefix}/status             status of all resources, see StatusHandler
+//	{prefix}/admin/             admin API when an admin token is set, see AdminHandler
+//
+// With an admin token (see WithAdminToken), the status endpoint requires it as well.
+func RegisterResourceCacher(router Router, prefix string, rc http.Handler, mwf ...Middleware) {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	wrap := func(h http.Handler) http.Handler {
+		for i := len(mwf) - 1; i >= 0; i-- {
+			h = mwf[i](h)
+		}
+		return h
+	}
+
+	router.Handle(prefix+"/resources/", wrap(rc))
+
+	if p, ok := rc.(interface{ PollHandler() http.Handler }); ok {
+		router.Handle(prefix+"/resources/poll", wrap(p.PollHandler()))
+	}
+
+	token := ""
+	if a, ok := rc.(interface{ adminToken() string }); ok {
+		token = a.adminToken()
+	}
+
+	if s, ok := rc.(interface{ StatusHandler() http.Handler }); ok {
+		status := s.StatusHandler()
+		if token != "" {
+			status = requireToken(token, status)
+		}
+		router.Handle(prefix+"/status", wrap(status))
+	}
+
+	if a, ok := rc.(interface{ AdminHandler(string) http.Handler }); ok && token != "" {
+		router.Handle(prefix+"/admin/", wrap(http.StripPrefix(prefix+"/admin", a.AdminHandler(token))))
+	}
+}
@@ -0,0 +1,182 @@
+package routing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestRegisterResourceCacher(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	if _, err := c.AddResource(&routing.Resource{
+		Alias:    "registered",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Hour,
+	}, nil); err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	var order []string
+	middleware := func(name string) routing.Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	mux := http.NewServeMux()
+	routing.RegisterResourceCacher(mux, "/api/", c, middleware("first"), middleware("second"))
+
+	tests := []struct {
+		target     string
+		statusCode int
+	}{
+		{target: "/api/resources/registered", statusCode: http.StatusOK},
+		{target: "/api/status", statusCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		order = nil
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+		if w.Code != tt.statusCode {
+			t.Errorf("<%s> statusCode not equal. expected %v obtained %v\n", tt.target, tt.statusCode, w.Code)
+		}
+
+		if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+			t.Errorf("<%s> middlewares order not equal. expected [first second] obtained %v\n", tt.target, order)
+		}
+	}
+}
+
+// prefixRouter mimics gorilla/mux: Handle returns a route and matches exact paths only
+type prefixRouter struct {
+	exact    map[string]http.Handler
+	prefixes map[string]http.Handler
+}
+
+type prefixRoute struct{}
+
+func (r *prefixRouter) Handle(path string, h http.Handler) *prefixRoute {
+	r.exact[path] = h
+	return &prefixRoute{}
+}
+
+func (r *prefixRouter) PathPrefix(prefix string, h http.Handler) *prefixRoute {
+	r.prefixes[prefix] = h
+	return &prefixRoute{}
+}
+
+func (r *prefixRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if h, ok := r.exact[req.URL.Path]; ok {
+		h.ServeHTTP(w, req)
+		return
+	}
+
+	for prefix, h := range r.prefixes {
+		if strings.HasPrefix(req.URL.Path, prefix) {
+			h.ServeHTTP(w, req)
+			return
+		}
+	}
+
+	http.NotFound(w, req)
+}
+
+var (
+	_ routing.Router = http.NewServeMux()
+	_ routing.Router = routing.RouterFunc(nil)
+)
+
+func TestRegisterResourceCacherRouterFunc(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	if _, err := c.AddResource(&routing.Resource{
+		Alias:    "registered",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Hour,
+	}, nil); err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	r := &prefixRouter{exact: make(map[string]http.Handler), prefixes: make(map[string]http.Handler)}
+	routing.RegisterResourceCacher(routing.RouterFunc(func(pattern string, h http.Handler) {
+		if strings.HasSuffix(pattern, "/") {
+			r.PathPrefix(pattern, h)
+			return
+		}
+		r.Handle(pattern, h)
+	}), "/api", c)
+
+	for _, target := range []string{"/api/resources/registered", "/api/status"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("<%s> statusCode not equal. expected %v obtained %v\n", target, http.StatusOK, w.Code)
+		}
+	}
+}
+
+func TestRegisterResourceCacherAdmin(t *testing.T) {
+	c := routing.NewResourceCacher(routing.WithAdminToken("t0ken"))
+	if _, err := c.AddResource(&routing.Resource{
+		Alias:    "registered",
+		Interval: time.Hour,
+		Fetcher: routing.FetcherFunc(func(ctx context.Context) ([]byte, http.Header, int, error) {
+			return []byte(`{"status": "ok"}`), nil, http.StatusOK, nil
+		}),
+	}, nil); err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+	defer c.Stop()
+
+	mux := http.NewServeMux()
+	routing.RegisterResourceCacher(mux, "/api", c)
+
+	tests := []struct {
+		target     string
+		token      string
+		statusCode int
+	}{
+		{target: "/api/resources/registered", statusCode: http.StatusOK},
+		{target: "/api/status", statusCode: http.StatusUnauthorized},
+		{target: "/api/status", token: "t0ken", statusCode: http.StatusOK},
+		{target: "/api/admin/resources", statusCode: http.StatusUnauthorized},
+		{target: "/api/admin/resources/registered", token: "t0ken", statusCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if w.Code != tt.statusCode {
+			t.Errorf("<%s> statusCode not equal. expected %v obtained %v\n", tt.target, tt.statusCode, w.Code)
+		}
+	}
+}
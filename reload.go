@@ -0,0 +1,68 @@
+package routing
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ReloadConfig replaces the resources with those defined in a configuration file. Unchanged
+// resources keep their content and fetcher, and SSE clients of kept aliases stay connected.
+func (c *ResourceCacher) ReloadConfig(path string, onUpdate ResourceEvent) error {
+	resources, err := LoadResources(path)
+	if err != nil {
+		return err
+	}
+
+	return c.ReplaceResources(resources, onUpdate)
+}
+
+// WatchConfig reloads a configuration file on SIGHUP and, when pollInterval is not 0, whenever
+// its modification time changes. It blocks until ctx is done, failed reloads being logged
+// while the current resources are kept.
+func (c *ResourceCacher) WatchConfig(ctx context.Context, path string, pollInterval time.Duration, onUpdate ResourceEvent) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var poll <-chan time.Time
+	if pollInterval > 0 {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	modTime := configModTime(path)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+		case <-poll:
+			t := configModTime(path)
+			if t.Equal(modTime) {
+				continue
+			}
+			modTime = t
+		}
+
+		if err := c.ReloadConfig(path, onUpdate); err != nil {
+			c.opts.Logger.Error("config reload failed", F("path", path), F("error", err))
+			continue
+		}
+
+		c.opts.Logger.Info("config reloaded", F("path", path))
+	}
+}
+
+func configModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
@@ -0,0 +1,194 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrInvalidRepresentation is returned when a representation has no media type or no source
+var ErrInvalidRepresentation = errors.New("representation needs a media type and a URL or transformers")
+
+// Representation is an alternative media type of a resource served according to the client
+// Accept header, fetched from its own URL or derived from the resource content by Transformers
+type Representation struct {
+	MediaType    string
+	URL          string
+	Transformers []Transformer
+
+	entry  variantEntry
+	source string
+}
+
+// representationConfig represents the JSON definition of a representation
+type representationConfig struct {
+	MediaType    string            `json:"media_type"`
+	URL          string            `json:"url,omitempty"`
+	Transformers []json.RawMessage `json:"transformers,omitempty"`
+}
+
+// MarshalJSON encodes the representation, transformers being included when built from a definition
+func (rep *Representation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(representationConfig{
+		MediaType:    rep.MediaType,
+		URL:          rep.URL,
+		Transformers: transformerConfigs(rep.Transformers),
+	})
+}
+
+// UnmarshalJSON decodes a representation definition
+func (rep *Representation) UnmarshalJSON(b []byte) error {
+	var cfg representationConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return err
+	}
+
+	transformers, err := newTransformers(cfg.Transformers)
+	if err != nil {
+		return err
+	}
+
+	rep.MediaType, rep.URL, rep.Transformers = cfg.MediaType, cfg.URL, transformers
+
+	return nil
+}
+
+// validate checks the representation has a media type and a source
+func (rep *Representation) validate() error {
+	if rep.MediaType == "" || (rep.URL == "" && len(rep.Transformers) == 0) {
+		return ErrInvalidRepresentation
+	}
+
+	return nil
+}
+
+// snapshot returns the content of the representation, fetching it from its URL when older than
+// the resource interval or transforming base when the resource content changed
+func (rep *Representation) snapshot(ctx context.Context, res *Resource, base *Snapshot) (*Snapshot, error) {
+	e := &rep.entry
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.res == nil {
+		e.res = res.newVariant(rep.URL)
+		e.res.Transformers = append(append([]Transformer(nil), rep.Transformers...), Transformer{
+			Name: "media_type",
+			Transform: func(content []byte, header http.Header) ([]byte, http.Header, error) {
+				// Parameters like charset are kept when the media type matches
+				if mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type")); !strings.EqualFold(mediaType, rep.MediaType) {
+					header.Set("Content-Type", rep.MediaType)
+				}
+				return content, header, nil
+			},
+		})
+	}
+
+	if rep.URL != "" {
+		if time.Since(e.fetched) > res.Interval {
+			if err := e.res.FetchContext(ctx); err != nil && e.res.Hash == "" {
+				return nil, err
+			}
+			e.fetched = time.Now()
+		}
+	} else if rep.source != base.Hash {
+		header := base.Header.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+
+		content, header, err := e.res.transform(base.Content, header)
+		if err != nil {
+			return nil, err
+		}
+
+		e.res.Header = header
+		e.res.StatusCode = base.StatusCode
+		e.res.LastModified = base.LastModified
+		e.res.SetContent(content)
+		rep.source = base.Hash
+	}
+
+	return e.res.Snapshot(), nil
+}
+
+// representationSnapshot picks the representation of a resource matching the Accept header,
+// returning nil with 406 when none is acceptable
+func (c *ResourceCacher) representationSnapshot(r *http.Request, res *Resource, base *Snapshot) (*Snapshot, int) {
+	available := make([]string, 0, len(res.Representations)+1)
+	baseType, _, _ := mime.ParseMediaType(base.Header.Get("Content-Type"))
+	available = append(available, baseType)
+	for _, rep := range res.Representations {
+		available = append(available, rep.MediaType)
+	}
+
+	i := negotiateMediaType(r.Header.Get("Accept"), available)
+	switch {
+	case i < 0:
+		return nil, http.StatusNotAcceptable
+	case i == 0:
+		return base, http.StatusOK
+	}
+
+	s, err := res.Representations[i-1].snapshot(r.Context(), res, base)
+	if err != nil {
+		c.opts.Logger.Warn("representation failed", F("alias", res.Alias), F("media_type", available[i]), F("error", err))
+		return nil, http.StatusBadGateway
+	}
+
+	return s, http.StatusOK
+}
+
+// negotiateMediaType returns the index of the available media type preferred by an Accept
+// header, the first one when accept is empty and -1 when none is acceptable
+func negotiateMediaType(accept string, available []string) int {
+	if accept == "" {
+		return 0
+	}
+
+	var ranges []string
+	var qvalues []float64
+	for _, part := range strings.Split(accept, ",") {
+		name, q := parseQValue(part)
+		if name != "" {
+			ranges, qvalues = append(ranges, name), append(qvalues, q)
+		}
+	}
+
+	best, bestQ := -1, 0.0
+	for i, mediaType := range available {
+		// The most specific matching range gives the quality
+		q, specificity := 0.0, -1
+		for j, rng := range ranges {
+			if s := matchMediaRange(rng, strings.ToLower(mediaType)); s > specificity {
+				q, specificity = qvalues[j], s
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+
+	return best
+}
+
+// matchMediaRange returns how specifically a range like "text/*" matches a media type, -1 if not
+func matchMediaRange(rng, mediaType string) int {
+	switch {
+	case rng == "*/*":
+		return 0
+	case mediaType == "":
+		return -1
+	case rng == mediaType:
+		return 2
+	case strings.HasSuffix(rng, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(rng, "*")):
+		return 1
+	}
+
+	return -1
+}
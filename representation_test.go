@@ -0,0 +1,95 @@
+package routing_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestRepresentations(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"name":"a"}`))
+		case "/xml":
+			w.Header().Set("Content-Type", "application/xml")
+			w.Write([]byte(`<item><name>a</name></item>`))
+		}
+	}))
+	defer srv.Close()
+
+	var representations []*routing.Representation
+	if err := json.Unmarshal([]byte(`[
+		{"media_type": "application/xml", "url": "`+srv.URL+`/xml"},
+		{"media_type": "text/plain", "transformers": [{"type": "jsonpath", "path": "$.name"}]}
+	]`), &representations); err != nil {
+		t.Fatalf("unmarshal error: %s", err)
+	}
+
+	c := routing.NewResourceCacher(nil)
+	if _, err := c.AddResource(&routing.Resource{
+		Alias:           "item",
+		Method:          http.MethodGet,
+		URL:             srv.URL + "/json",
+		Interval:        time.Hour,
+		Representations: representations,
+	}, nil); err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	tests := []struct {
+		name        string
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"no accept", "", http.StatusOK, "application/json", `{"name":"a"}`},
+		{"json", "application/json", http.StatusOK, "application/json", `{"name":"a"}`},
+		{"xml", "application/xml", http.StatusOK, "application/xml", `<item><name>a</name></item>`},
+		{"quality", "application/json;q=0.5, application/xml", http.StatusOK, "application/xml", `<item><name>a</name></item>`},
+		{"wildcard subtype", "text/*", http.StatusOK, "text/plain", `"a"`},
+		{"specific excluded", "text/plain;q=0, */*", http.StatusOK, "application/json", `{"name":"a"}`},
+		{"not acceptable", "image/png", http.StatusNotAcceptable, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?alias=item", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			rec := httptest.NewRecorder()
+			c.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("<response> status not equal. expected %v obtained %v\n", tt.status, rec.Code)
+			}
+
+			if vary := strings.Join(rec.Header()["Vary"], ", "); !strings.Contains(vary, "Accept") {
+				t.Errorf("<response> Vary does not contain Accept. obtained %v\n", vary)
+			}
+
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			if ct := rec.Header().Get("Content-Type"); ct != tt.contentType {
+				t.Errorf("<response> Content-Type not equal. expected %v obtained %v\n", tt.contentType, ct)
+			}
+
+			body, _ := ioutil.ReadAll(rec.Body)
+			if string(body) != tt.body {
+				t.Errorf("<response> body not equal. expected %v obtained %v\n", tt.body, string(body))
+			}
+		})
+	}
+}
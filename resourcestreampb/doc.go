@@ -0,0 +1,5 @@
+// Package resourcestreampb holds the ResourceStream gRPC service generated from
+// resourcestream.proto by go generate, and its Server backed by routing.ResourceStream.
+// The Server is built with the grpc tag, the generated code and the gRPC module being
+// required then.
+package resourcestreampb
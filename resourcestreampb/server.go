@@ -0,0 +1,60 @@
+//go:build grpc
+// +build grpc
+
+package resourcestreampb
+
+import (
+	"context"
+
+	"go.lsl.digital/lardwaz/routing"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements ResourceStreamServer over a routing.ResourceStream
+type Server struct {
+	UnimplementedResourceStreamServer
+
+	stream *routing.ResourceStream
+}
+
+// NewServer creates a new gRPC server of the resources streamed by s
+func NewServer(s *routing.ResourceStream) *Server {
+	return &Server{stream: s}
+}
+
+// Subscribe implements ResourceStreamServer
+func (s *Server) Subscribe(req *SubscribeRequest, stream ResourceStream_SubscribeServer) error {
+	err := s.stream.Subscribe(stream.Context(), req.GetAliases(), func(snapshot *routing.Snapshot) error {
+		return stream.Send(newResource(snapshot))
+	})
+	if err == routing.ErrResourceNotFound {
+		return status.Error(codes.NotFound, err.Error())
+	}
+
+	return err
+}
+
+// Get implements ResourceStreamServer
+func (s *Server) Get(ctx context.Context, req *GetRequest) (*Resource, error) {
+	snapshot, err := s.stream.Get(req.GetAlias())
+	if err == routing.ErrResourceNotFound {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newResource(snapshot), nil
+}
+
+func newResource(s *routing.Snapshot) *Resource {
+	return &Resource{
+		Alias:            s.Alias,
+		Hash:             s.Hash,
+		Content:          s.Content,
+		ContentType:      s.Header.Get("Content-Type"),
+		StatusCode:       int32(s.StatusCode),
+		LastModifiedUnix: s.LastModified.Unix(),
+	}
+}
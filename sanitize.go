@@ -0,0 +1,312 @@
+package routing
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func init() {
+	RegisterTransformer("sanitize_html", func(config json.RawMessage) (Transformer, error) {
+		var def struct {
+			Elements map[string][]string `json:"elements"`
+			Schemes  []string            `json:"schemes"`
+		}
+		if err := json.Unmarshal(config, &def); err != nil {
+			return Transformer{}, err
+		}
+
+		policy := DefaultSanitizerPolicy()
+		if def.Elements != nil {
+			policy.AllowedElements = def.Elements
+		}
+		if def.Schemes != nil {
+			policy.AllowedSchemes = def.Schemes
+		}
+
+		return SanitizeHTML(policy), nil
+	})
+}
+
+// SanitizerPolicy defines the HTML elements and attributes kept by SanitizeHTML. Other
+// elements are dropped keeping their text, except those like script or style which are
+// dropped along with their content. Event handler attributes are never kept.
+type SanitizerPolicy struct {
+	// AllowedElements maps the kept elements to their kept attributes
+	AllowedElements map[string][]string
+	// AllowedSchemes of URL attributes such as href or src, relative URLs being always allowed
+	AllowedSchemes []string
+}
+
+// DefaultSanitizerPolicy keeps text formatting, lists, tables, links and images
+func DefaultSanitizerPolicy() *SanitizerPolicy {
+	elements := map[string][]string{
+		"a":   {"href", "title", "rel", "target"},
+		"img": {"src", "alt", "title", "width", "height"},
+	}
+	for _, name := range []string{
+		"p", "br", "hr", "div", "span", "b", "i", "u", "s", "em", "strong", "small", "sub", "sup", "mark",
+		"h1", "h2", "h3", "h4", "h5", "h6", "blockquote", "code", "pre", "ul", "ol", "li", "dl", "dt", "dd",
+		"table", "thead", "tbody", "tfoot", "tr", "th", "td", "caption", "figure", "figcaption",
+	} {
+		elements[name] = []string{"class", "title"}
+	}
+
+	return &SanitizerPolicy{
+		AllowedElements: elements,
+		AllowedSchemes:  []string{"http", "https", "mailto"},
+	}
+}
+
+// droppedWithContent lists the elements whose content is never text to keep
+var droppedWithContent = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"noscript": true, "template": true, "textarea": true, "title": true, "svg": true, "math": true,
+}
+
+// urlAttributes lists the attributes holding URLs
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "cite": true, "action": true, "formaction": true, "background": true, "poster": true,
+}
+
+// SanitizeHTML strips unsafe elements and attributes from fetched HTML according to the policy
+// (defaults to DefaultSanitizerPolicy)
+func SanitizeHTML(policy *SanitizerPolicy) Transformer {
+	if policy == nil {
+		policy = DefaultSanitizerPolicy()
+	}
+
+	return Transformer{
+		Name: "sanitize_html",
+		Transform: func(content []byte, header http.Header) ([]byte, http.Header, error) {
+			return []byte(policy.Sanitize(string(content))), header, nil
+		},
+	}
+}
+
+// Sanitize returns the sanitized HTML fragment
+func (p *SanitizerPolicy) Sanitize(s string) string {
+	var out strings.Builder
+
+	for len(s) > 0 {
+		lt := strings.IndexByte(s, '<')
+		if lt < 0 {
+			out.WriteString(s)
+			break
+		}
+
+		out.WriteString(s[:lt])
+		s = s[lt:]
+
+		switch {
+		case strings.HasPrefix(s, "<!--"):
+			s = skipPast(s, "-->")
+			continue
+		case strings.HasPrefix(s, "<!") || strings.HasPrefix(s, "<?"):
+			s = skipPast(s, ">")
+			continue
+		}
+
+		name, attrs, closing, selfClosing, rest, ok := parseTag(s)
+		if !ok {
+			out.WriteString("&lt;")
+			s = s[1:]
+			continue
+		}
+		s = rest
+
+		if droppedWithContent[name] {
+			if !closing && !selfClosing {
+				s = skipClosingTag(s, name)
+			}
+			continue
+		}
+
+		allowed, ok := p.AllowedElements[name]
+		if !ok {
+			continue
+		}
+
+		if closing {
+			out.WriteString("</" + name + ">")
+			continue
+		}
+
+		out.WriteString("<" + name)
+		for _, attr := range attrs {
+			if !p.allowAttribute(allowed, attr[0], attr[1]) {
+				continue
+			}
+			out.WriteString(" " + attr[0] + `="` + html.EscapeString(attr[1]) + `"`)
+		}
+		if selfClosing {
+			out.WriteString(" /")
+		}
+		out.WriteString(">")
+	}
+
+	return out.String()
+}
+
+// allowAttribute tells whether an attribute of an allowed element is kept
+func (p *SanitizerPolicy) allowAttribute(allowed []string, name, value string) bool {
+	if strings.HasPrefix(name, "on") {
+		return false
+	}
+
+	found := false
+	for _, a := range allowed {
+		if a == name {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return false
+	}
+
+	if !urlAttributes[name] {
+		return true
+	}
+
+	// Browsers ignore whitespace and control characters in schemes, e.g. "java\tscript:"
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, value)
+
+	u, err := url.Parse(cleaned)
+	if err != nil {
+		return false
+	}
+
+	if u.Scheme == "" {
+		return true
+	}
+
+	for _, scheme := range p.AllowedSchemes {
+		if strings.EqualFold(u.Scheme, scheme) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// skipPast returns s after the first occurrence of end, or nothing when missing
+func skipPast(s, end string) string {
+	i := strings.Index(s, end)
+	if i < 0 {
+		return ""
+	}
+
+	return s[i+len(end):]
+}
+
+// skipClosingTag returns s after the closing tag of name, or nothing when missing
+func skipClosingTag(s, name string) string {
+	lower := strings.ToLower(s)
+	for i := 0; ; {
+		j := strings.Index(lower[i:], "</"+name)
+		if j < 0 {
+			return ""
+		}
+		i += j
+
+		if _, _, closing, _, rest, ok := parseTag(s[i:]); ok && closing {
+			return rest
+		}
+		i += 2
+	}
+}
+
+// parseTag parses the tag at the start of s, returning its lowercased name, its attributes
+// with unescaped values and the rest of s
+func parseTag(s string) (name string, attrs [][2]string, closing, selfClosing bool, rest string, ok bool) {
+	i := 1
+	if i < len(s) && s[i] == '/' {
+		closing = true
+		i++
+	}
+
+	start := i
+	for i < len(s) && isTagNameChar(s[i], i == start) {
+		i++
+	}
+
+	if i == start {
+		return "", nil, false, false, "", false
+	}
+	name = strings.ToLower(s[start:i])
+
+	for {
+		for i < len(s) && (isSpace(s[i]) || s[i] == '/') {
+			if s[i] == '/' {
+				selfClosing = true
+			}
+			i++
+		}
+
+		if i >= len(s) {
+			return "", nil, false, false, "", false
+		}
+
+		if s[i] == '>' {
+			return name, attrs, closing, selfClosing, s[i+1:], true
+		}
+		selfClosing = false
+
+		start = i
+		for i < len(s) && !isSpace(s[i]) && s[i] != '=' && s[i] != '>' && s[i] != '/' {
+			i++
+		}
+		attr := strings.ToLower(s[start:i])
+
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+
+		value := ""
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && isSpace(s[i]) {
+				i++
+			}
+
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				quote := s[i]
+				end := strings.IndexByte(s[i+1:], quote)
+				if end < 0 {
+					return "", nil, false, false, "", false
+				}
+				value = s[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				start = i
+				for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				value = s[start:i]
+			}
+		}
+
+		attrs = append(attrs, [2]string{attr, html.UnescapeString(value)})
+	}
+}
+
+func isTagNameChar(c byte, first bool) bool {
+	if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+		return true
+	}
+
+	return !first && (c >= '0' && c <= '9' || c == '-')
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
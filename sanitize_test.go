@@ -0,0 +1,57 @@
+package routing_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "kept", input: `<p class="lead">Hello <b>world</b></p>`, expected: `<p class="lead">Hello <b>world</b></p>`},
+		{name: "script", input: `<p>a</p><script>alert("<p>x</p>")</script><p>b</p>`, expected: `<p>a</p><p>b</p>`},
+		{name: "script uppercase", input: `<SCRIPT src=x.js></SCRIPT >ok`, expected: `ok`},
+		{name: "style", input: `<style>p{}</style>text`, expected: `text`},
+		{name: "unknown element keeps text", input: `<blink>hey</blink>`, expected: `hey`},
+		{name: "event handler", input: `<img src="a.png" onerror="alert(1)">`, expected: `<img src="a.png">`},
+		{name: "javascript url", input: `<a href="javascript:alert(1)">x</a>`, expected: `<a>x</a>`},
+		{name: "obfuscated javascript url", input: `<a href="java&#x09;script:alert(1)">x</a>`, expected: `<a>x</a>`},
+		{name: "allowed url", input: `<a href='https://example.com/?a=1&amp;b=2' target=_blank>x</a>`, expected: `<a href="https://example.com/?a=1&amp;b=2" target="_blank">x</a>`},
+		{name: "disallowed attribute", input: `<div style="position:fixed" title="t">x</div>`, expected: `<div title="t">x</div>`},
+		{name: "comment", input: `a<!-- <script>alert(1)</script> -->b`, expected: `ab`},
+		{name: "stray lt", input: `1 < 2 <br/>`, expected: `1 &lt; 2 <br />`},
+		{name: "attribute quote escape", input: `<span title='a"><script>'>x</span>`, expected: `<span title="a&#34;&gt;&lt;script&gt;">x</span>`},
+	}
+
+	policy := routing.DefaultSanitizerPolicy()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if obtained := policy.Sanitize(tt.input); obtained != tt.expected {
+				t.Errorf("<sanitize> content not equal. expected %s obtained %s\n", tt.expected, obtained)
+			}
+		})
+	}
+}
+
+func TestSanitizeHTMLConfig(t *testing.T) {
+	tr, err := routing.NewTransformer(json.RawMessage(`{"type": "sanitize_html", "elements": {"a": ["href"]}, "schemes": ["https"]}`))
+	if err != nil {
+		t.Fatalf("new transformer error: %s", err)
+	}
+
+	b, _, err := tr.Transform([]byte(`<p><a href="http://example.com">x</a> <a href="https://example.com" title="t">y</a></p>`), http.Header{})
+	if err != nil {
+		t.Fatalf("transform error: %s", err)
+	}
+
+	expected := `<a>x</a> <a href="https://example.com">y</a>`
+	if string(b) != expected {
+		t.Errorf("<sanitize> content not equal. expected %s obtained %s\n", expected, b)
+	}
+}
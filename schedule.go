@@ -0,0 +1,138 @@
+package routing
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// nextInterval returns the delay until the next fetch, following Schedule when set
+// or else the base interval spread by the jitter
+func (r *Resource) nextInterval() time.Duration {
+	if r.cron != nil {
+		next := r.cron.next(time.Now())
+		if next.IsZero() {
+			// Never matches, check again much later
+			return 24 * time.Hour
+		}
+
+		return time.Until(next)
+	}
+
+	interval := r.baseInterval()
+
+	jitter := r.Jitter
+	if r.JitterFactor > 0 {
+		jitter = time.Duration(float64(interval) * r.JitterFactor)
+	}
+
+	if jitter <= 0 {
+		return interval
+	}
+
+	d := interval - jitter + time.Duration(rand.Int63n(int64(2*jitter)+1))
+	if d <= 0 {
+		return time.Millisecond
+	}
+
+	return d
+}
+
+// scheduleNext returns the delay until the next fetch, remembering when it is due
+func (r *Resource) scheduleNext() time.Duration {
+	d := r.nextInterval()
+
+	r.stateMu.Lock()
+	r.nextFetch = time.Now().Add(d)
+	r.stateMu.Unlock()
+
+	return d
+}
+
+// maxBackoff returns the longest delay between retries: the interval or, following Schedule,
+// the delay until the next run
+func (r *Resource) maxBackoff() time.Duration {
+	if r.cron != nil {
+		return r.nextInterval()
+	}
+
+	return r.Interval
+}
+
+// baseInterval returns Interval or, in adaptive mode, the upstream freshness lifetime within bounds
+func (r *Resource) baseInterval() time.Duration {
+	if !r.Adaptive {
+		return r.Interval
+	}
+
+	d := time.Duration(atomic.LoadInt64(&r.upstreamFreshness))
+	if d <= 0 {
+		d = r.Interval
+	}
+
+	if r.MinInterval > 0 && d < r.MinInterval {
+		d = r.MinInterval
+	}
+
+	if r.MaxInterval > 0 && d > r.MaxInterval {
+		d = r.MaxInterval
+	}
+
+	return d
+}
+
+// freshness returns how long a response stays fresh according to its Cache-Control
+// (s-maxage then max-age) or Expires headers, 0 when unknown or not cacheable
+func freshness(header http.Header, now time.Time) time.Duration {
+	var maxAge, sMaxAge = -1, -1
+
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+
+		switch {
+		case directive == "no-store" || directive == "no-cache":
+			return 0
+		case strings.HasPrefix(directive, "s-maxage="):
+			if v, err := strconv.Atoi(strings.TrimPrefix(directive, "s-maxage=")); err == nil {
+				sMaxAge = v
+			}
+		case strings.HasPrefix(directive, "max-age="):
+			if v, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil {
+				maxAge = v
+			}
+		}
+	}
+
+	var d time.Duration
+	switch {
+	case sMaxAge >= 0:
+		d = time.Duration(sMaxAge) * time.Second
+	case maxAge >= 0:
+		d = time.Duration(maxAge) * time.Second
+	default:
+		expires, err := http.ParseTime(header.Get("Expires"))
+		if err != nil {
+			return 0
+		}
+
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			now = date
+		}
+
+		d = expires.Sub(now)
+	}
+
+	// Time already spent in upstream caches
+	if age, err := strconv.Atoi(header.Get("Age")); err == nil {
+		d -= time.Duration(age) * time.Second
+	}
+
+	if d < 0 {
+		return 0
+	}
+
+	return d
+}
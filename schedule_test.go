@@ -0,0 +1,129 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNextIntervalJitter(t *testing.T) {
+	tests := []struct {
+		name     string
+		res      *Resource
+		min, max time.Duration
+	}{
+		{
+			name: "none",
+			res:  &Resource{Interval: time.Second},
+			min:  time.Second,
+			max:  time.Second,
+		},
+		{
+			name: "absolute",
+			res:  &Resource{Interval: time.Second, Jitter: 200 * time.Millisecond},
+			min:  800 * time.Millisecond,
+			max:  1200 * time.Millisecond,
+		},
+		{
+			name: "factor",
+			res:  &Resource{Interval: time.Second, Jitter: time.Hour, JitterFactor: 0.1},
+			min:  900 * time.Millisecond,
+			max:  1100 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := make(map[time.Duration]bool)
+			for i := 0; i < 100; i++ {
+				d := tt.res.nextInterval()
+				if d < tt.min || d > tt.max {
+					t.Fatalf("<schedule> interval out of bounds. expected [%v, %v] obtained %v\n", tt.min, tt.max, d)
+				}
+				seen[d] = true
+			}
+
+			if spread := tt.min != tt.max; spread && len(seen) < 10 {
+				t.Errorf("<schedule> intervals not spread. obtained %d distinct values\n", len(seen))
+			}
+		})
+	}
+}
+
+func TestFreshness(t *testing.T) {
+	now := time.Date(2020, 1, 6, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		expected time.Duration
+	}{
+		{"none", http.Header{}, 0},
+		{"max-age", http.Header{"Cache-Control": {"public, max-age=60"}}, time.Minute},
+		{"s-maxage", http.Header{"Cache-Control": {"max-age=60, s-maxage=120"}}, 2 * time.Minute},
+		{"no-cache", http.Header{"Cache-Control": {"no-cache, max-age=60"}}, 0},
+		{"age", http.Header{"Cache-Control": {"max-age=60"}, "Age": {"20"}}, 40 * time.Second},
+		{"expires", http.Header{"Expires": {now.Add(5 * time.Minute).Format(http.TimeFormat)}}, 5 * time.Minute},
+		{"expired", http.Header{"Expires": {now.Add(-time.Minute).Format(http.TimeFormat)}}, 0},
+	}
+
+	for _, test := range tests {
+		if d := freshness(test.header, now); d != test.expected {
+			t.Errorf("<freshness> %s not equal. expected %v obtained %v\n", test.name, test.expected, d)
+		}
+	}
+}
+
+func TestAdaptiveInterval(t *testing.T) {
+	res := &Resource{Interval: time.Minute, Adaptive: true, MinInterval: 10 * time.Second, MaxInterval: time.Hour}
+
+	if d := res.nextInterval(); d != time.Minute {
+		t.Errorf("<adaptive> fallback not equal. expected %v obtained %v\n", time.Minute, d)
+	}
+
+	for _, test := range []struct{ freshness, expected time.Duration }{
+		{5 * time.Minute, 5 * time.Minute},
+		{time.Second, 10 * time.Second},
+		{24 * time.Hour, time.Hour},
+	} {
+		res.upstreamFreshness = int64(test.freshness)
+		if d := res.nextInterval(); d != test.expected {
+			t.Errorf("<adaptive> interval not equal. expected %v obtained %v\n", test.expected, d)
+		}
+	}
+}
+
+func TestRetryBackoffSchedule(t *testing.T) {
+	cron, err := parseCron("0 0 * * *")
+	if err != nil {
+		t.Fatalf("parse error: %s", err)
+	}
+
+	var attempts []time.Time
+	r := &Resource{
+		Alias:        "nightly",
+		Schedule:     "0 0 * * *",
+		MaxRetries:   2,
+		RetryBackoff: 20 * time.Millisecond,
+		Fetcher: FetcherFunc(func(ctx context.Context) ([]byte, http.Header, int, error) {
+			attempts = append(attempts, time.Now())
+			return nil, nil, 0, errors.New("unavailable")
+		}),
+		cron: cron,
+	}
+
+	if err := r.fetchWithRetry(context.Background()); err == nil {
+		t.Fatalf("<resource> expected error with a failing upstream\n")
+	}
+
+	if len(attempts) != 3 {
+		t.Fatalf("<resource> attempts not equal. expected %v obtained %v\n", 3, len(attempts))
+	}
+
+	// The second retry waits twice the first delay, not capped by a zero interval
+	if d := attempts[2].Sub(attempts[1]); d < 40*time.Millisecond {
+		t.Errorf("<resource> second backoff too short. expected at least %v obtained %v\n", 40*time.Millisecond, d)
+	}
+}
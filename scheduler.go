@@ -0,0 +1,159 @@
+package routing
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// scheduler drives the fetches of all resources from a single goroutine, which only
+// runs while fetches are scheduled
+type scheduler struct {
+	mu      sync.Mutex
+	queue   scheduleQueue
+	wake    chan struct{}
+	running bool
+}
+
+// scheduleItem is a pending fetch of a resource
+type scheduleItem struct {
+	res   *Resource
+	ctx   context.Context
+	due   time.Time
+	index int
+}
+
+// fetchScheduler is the scheduler shared by all resources
+var fetchScheduler = &scheduler{wake: make(chan struct{}, 1)}
+
+// schedule adds or moves the pending fetch of an item
+func (s *scheduler) schedule(item *scheduleItem, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item.due = time.Now().Add(d)
+	if item.index >= 0 && item.index < len(s.queue) && s.queue[item.index] == item {
+		heap.Fix(&s.queue, item.index)
+	} else {
+		heap.Push(&s.queue, item)
+	}
+
+	if !s.running {
+		s.running = true
+		go s.loop()
+		return
+	}
+
+	if item.index == 0 {
+		// Earlier than what the loop is waiting for
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// unschedule removes the pending fetch of an item
+func (s *scheduler) unschedule(item *scheduleItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if item.index >= 0 && item.index < len(s.queue) && s.queue[item.index] == item {
+		heap.Remove(&s.queue, item.index)
+	}
+}
+
+func (s *scheduler) loop() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.running = false
+			s.mu.Unlock()
+			return
+		}
+
+		next := s.queue[0]
+		d := time.Until(next.due)
+		if d <= 0 {
+			heap.Pop(&s.queue)
+			s.mu.Unlock()
+
+			s.tick(next)
+			continue
+		}
+		s.mu.Unlock()
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(d)
+
+		select {
+		case <-timer.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// tick schedules the next fetch of an item and fetches it unless a fetch is still running
+func (s *scheduler) tick(item *scheduleItem) {
+	r := item.res
+	if item.ctx.Err() != nil {
+		return
+	}
+
+	s.schedule(item, r.scheduleNext())
+
+	if !atomic.CompareAndSwapInt32(&r.fetching, 0, 1) {
+		// Previous fetch still running, skip this tick
+		return
+	}
+
+	r.inflight.Add(1)
+	go func() {
+		defer r.inflight.Done()
+		defer atomic.StoreInt32(&r.fetching, 0)
+
+		if keep, _ := r.fetchAndTrack(item.ctx); !keep {
+			r.StopFetcher()
+		}
+	}()
+}
+
+// scheduleQueue is a min-heap of items ordered by due time
+type scheduleQueue []*scheduleItem
+
+func (q scheduleQueue) Len() int { return len(q) }
+
+func (q scheduleQueue) Less(i, j int) bool { return q[i].due.Before(q[j].due) }
+
+func (q scheduleQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *scheduleQueue) Push(x interface{}) {
+	item := x.(*scheduleItem)
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *scheduleQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*q = old[:n-1]
+
+	return item
+}
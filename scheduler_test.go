@@ -0,0 +1,49 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerDrivesAllResources(t *testing.T) {
+	var numFetches int32
+	fetcher := FetcherFunc(func(ctx context.Context) ([]byte, http.Header, int, error) {
+		atomic.AddInt32(&numFetches, 1)
+		return []byte("ok"), nil, http.StatusOK, nil
+	})
+
+	resources := make([]*Resource, 100)
+	for i := range resources {
+		resources[i] = &Resource{
+			Alias:    fmt.Sprintf("res%d", i),
+			Interval: 10 * time.Millisecond,
+			Fetcher:  fetcher,
+		}
+		resources[i].StartFetcher()
+	}
+
+	time.Sleep(55 * time.Millisecond)
+
+	for _, res := range resources {
+		res.StopFetcher()
+	}
+
+	if n := atomic.LoadInt32(&numFetches); n < 300 {
+		t.Errorf("<scheduler> expected at least 300 fetches obtained %d\n", n)
+	}
+
+	fetchScheduler.mu.Lock()
+	defer fetchScheduler.mu.Unlock()
+
+	for _, item := range fetchScheduler.queue {
+		for _, res := range resources {
+			if item.res == res {
+				t.Fatalf("<scheduler> expected stopped resource %s to be unscheduled\n", res.Alias)
+			}
+		}
+	}
+}
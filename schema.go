@@ -0,0 +1,365 @@
+package routing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+)
+
+// ErrInvalidContent is returned when fetched content does not match the JSONSchema of its resource
+var ErrInvalidContent = errors.New("invalid content")
+
+// JSONSchema validates JSON content against a subset of JSON Schema: type, enum, const,
+// properties, required, additionalProperties, items, minItems, maxItems, minLength,
+// maxLength, pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum, allOf,
+// anyOf, oneOf and not
+type JSONSchema struct {
+	raw json.RawMessage
+
+	types                []string
+	enum                 []interface{}
+	constant             interface{}
+	hasConst             bool
+	properties           map[string]*JSONSchema
+	required             []string
+	additional           *JSONSchema
+	noAdditional         bool
+	items                *JSONSchema
+	minItems, maxItems   *int
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+	minimum, maximum     *float64
+	exclMin, exclMax     *float64
+	allOf, anyOf, oneOf  []*JSONSchema
+	not                  *JSONSchema
+}
+
+// ParseJSONSchema parses a JSON Schema document
+func ParseJSONSchema(b []byte) (*JSONSchema, error) {
+	s := &JSONSchema{}
+	if err := s.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// MarshalJSON returns the schema document
+func (s *JSONSchema) MarshalJSON() ([]byte, error) {
+	if s.raw == nil {
+		return []byte("{}"), nil
+	}
+
+	return s.raw, nil
+}
+
+// UnmarshalJSON parses a JSON Schema document
+func (s *JSONSchema) UnmarshalJSON(b []byte) error {
+	*s = JSONSchema{raw: append(json.RawMessage(nil), b...)}
+
+	var def map[string]json.RawMessage
+	if err := json.Unmarshal(b, &def); err != nil {
+		return fmt.Errorf("invalid schema: %v", err)
+	}
+
+	if t, ok := def["type"]; ok {
+		var single string
+		if err := json.Unmarshal(t, &single); err == nil {
+			s.types = []string{single}
+		} else if err := json.Unmarshal(t, &s.types); err != nil {
+			return fmt.Errorf("invalid schema type: %s", t)
+		}
+	}
+
+	if v, ok := def["enum"]; ok {
+		if err := json.Unmarshal(v, &s.enum); err != nil {
+			return fmt.Errorf("invalid schema enum: %v", err)
+		}
+	}
+
+	if v, ok := def["const"]; ok {
+		s.hasConst = true
+		if err := json.Unmarshal(v, &s.constant); err != nil {
+			return fmt.Errorf("invalid schema const: %v", err)
+		}
+	}
+
+	if v, ok := def["properties"]; ok {
+		if err := json.Unmarshal(v, &s.properties); err != nil {
+			return fmt.Errorf("invalid schema properties: %v", err)
+		}
+	}
+
+	if v, ok := def["required"]; ok {
+		if err := json.Unmarshal(v, &s.required); err != nil {
+			return fmt.Errorf("invalid schema required: %v", err)
+		}
+	}
+
+	if v, ok := def["additionalProperties"]; ok {
+		var allowed bool
+		if err := json.Unmarshal(v, &allowed); err == nil {
+			s.noAdditional = !allowed
+		} else if err := json.Unmarshal(v, &s.additional); err != nil {
+			return fmt.Errorf("invalid schema additionalProperties: %v", err)
+		}
+	}
+
+	if v, ok := def["items"]; ok {
+		if err := json.Unmarshal(v, &s.items); err != nil {
+			return fmt.Errorf("invalid schema items: %v", err)
+		}
+	}
+
+	if v, ok := def["pattern"]; ok {
+		var pattern string
+		if err := json.Unmarshal(v, &pattern); err != nil {
+			return fmt.Errorf("invalid schema pattern: %v", err)
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid schema pattern: %v", err)
+		}
+		s.pattern = re
+	}
+
+	ints := map[string]**int{"minItems": &s.minItems, "maxItems": &s.maxItems, "minLength": &s.minLength, "maxLength": &s.maxLength}
+	for name, dst := range ints {
+		if v, ok := def[name]; ok {
+			if err := json.Unmarshal(v, dst); err != nil {
+				return fmt.Errorf("invalid schema %s: %v", name, err)
+			}
+		}
+	}
+
+	floats := map[string]**float64{"minimum": &s.minimum, "maximum": &s.maximum, "exclusiveMinimum": &s.exclMin, "exclusiveMaximum": &s.exclMax}
+	for name, dst := range floats {
+		if v, ok := def[name]; ok {
+			if err := json.Unmarshal(v, dst); err != nil {
+				return fmt.Errorf("invalid schema %s: %v", name, err)
+			}
+		}
+	}
+
+	lists := map[string]*[]*JSONSchema{"allOf": &s.allOf, "anyOf": &s.anyOf, "oneOf": &s.oneOf}
+	for name, dst := range lists {
+		if v, ok := def[name]; ok {
+			if err := json.Unmarshal(v, dst); err != nil {
+				return fmt.Errorf("invalid schema %s: %v", name, err)
+			}
+		}
+	}
+
+	if v, ok := def["not"]; ok {
+		if err := json.Unmarshal(v, &s.not); err != nil {
+			return fmt.Errorf("invalid schema not: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks JSON content against the schema, errors wrapping ErrInvalidContent
+func (s *JSONSchema) Validate(content []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(content, &v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidContent, err)
+	}
+
+	if err := s.validate(v, "$"); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidContent, err)
+	}
+
+	return nil
+}
+
+// jsonType returns the JSON Schema type of a decoded value
+func jsonType(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// validate checks a decoded value at path against the schema
+func (s *JSONSchema) validate(v interface{}, path string) error {
+	if len(s.types) > 0 {
+		t, ok := jsonType(v), false
+		for _, expected := range s.types {
+			if expected == t || (expected == "number" && t == "integer") {
+				ok = true
+				break
+			}
+		}
+
+		if !ok {
+			return fmt.Errorf("%s: expected %v, got %s", path, s.types, t)
+		}
+	}
+
+	if s.enum != nil {
+		found := false
+		for _, e := range s.enum {
+			if reflect.DeepEqual(e, v) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("%s: value not in enum", path)
+		}
+	}
+
+	if s.hasConst && !reflect.DeepEqual(s.constant, v) {
+		return fmt.Errorf("%s: value not equal to const", path)
+	}
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if err := s.validateObject(value, path); err != nil {
+			return err
+		}
+
+	case []interface{}:
+		if s.minItems != nil && len(value) < *s.minItems {
+			return fmt.Errorf("%s: expected at least %d items", path, *s.minItems)
+		}
+
+		if s.maxItems != nil && len(value) > *s.maxItems {
+			return fmt.Errorf("%s: expected at most %d items", path, *s.maxItems)
+		}
+
+		if s.items != nil {
+			for i, item := range value {
+				if err := s.items.validate(item, path+"["+strconv.Itoa(i)+"]"); err != nil {
+					return err
+				}
+			}
+		}
+
+	case string:
+		length := utf8.RuneCountInString(value)
+		if s.minLength != nil && length < *s.minLength {
+			return fmt.Errorf("%s: expected at least %d characters", path, *s.minLength)
+		}
+
+		if s.maxLength != nil && length > *s.maxLength {
+			return fmt.Errorf("%s: expected at most %d characters", path, *s.maxLength)
+		}
+
+		if s.pattern != nil && !s.pattern.MatchString(value) {
+			return fmt.Errorf("%s: does not match %s", path, s.pattern)
+		}
+
+	case float64:
+		if s.minimum != nil && value < *s.minimum {
+			return fmt.Errorf("%s: expected minimum %v", path, *s.minimum)
+		}
+
+		if s.maximum != nil && value > *s.maximum {
+			return fmt.Errorf("%s: expected maximum %v", path, *s.maximum)
+		}
+
+		if s.exclMin != nil && value <= *s.exclMin {
+			return fmt.Errorf("%s: expected more than %v", path, *s.exclMin)
+		}
+
+		if s.exclMax != nil && value >= *s.exclMax {
+			return fmt.Errorf("%s: expected less than %v", path, *s.exclMax)
+		}
+	}
+
+	return s.validateCombinations(v, path)
+}
+
+// validateObject checks the properties of an object
+func (s *JSONSchema) validateObject(obj map[string]interface{}, path string) error {
+	for _, name := range s.required {
+		if _, ok := obj[name]; !ok {
+			return fmt.Errorf("%s: missing required property %q", path, name)
+		}
+	}
+
+	for name, value := range obj {
+		if prop, ok := s.properties[name]; ok {
+			if err := prop.validate(value, path+"."+name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if s.noAdditional {
+			return fmt.Errorf("%s: unexpected property %q", path, name)
+		}
+
+		if s.additional != nil {
+			if err := s.additional.validate(value, path+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateCombinations checks the allOf, anyOf, oneOf and not subschemas
+func (s *JSONSchema) validateCombinations(v interface{}, path string) error {
+	for _, sub := range s.allOf {
+		if err := sub.validate(v, path); err != nil {
+			return err
+		}
+	}
+
+	if len(s.anyOf) > 0 {
+		matched := false
+		for _, sub := range s.anyOf {
+			if sub.validate(v, path) == nil {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return fmt.Errorf("%s: matches none of anyOf", path)
+		}
+	}
+
+	if len(s.oneOf) > 0 {
+		matches := 0
+		for _, sub := range s.oneOf {
+			if sub.validate(v, path) == nil {
+				matches++
+			}
+		}
+
+		if matches != 1 {
+			return fmt.Errorf("%s: matches %d of oneOf", path, matches)
+		}
+	}
+
+	if s.not != nil && s.not.validate(v, path) == nil {
+		return fmt.Errorf("%s: matches not", path)
+	}
+
+	return nil
+}
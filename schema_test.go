@@ -0,0 +1,131 @@
+package routing_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"required": ["status", "items"],
+	"additionalProperties": false,
+	"properties": {
+		"status": {"enum": ["ok", "partial"]},
+		"count": {"type": "integer", "minimum": 0},
+		"items": {
+			"type": "array",
+			"maxItems": 2,
+			"items": {
+				"type": "object",
+				"properties": {
+					"id": {"type": "string", "pattern": "^[a-z]+$", "maxLength": 5},
+					"price": {"anyOf": [{"type": "number", "exclusiveMinimum": 0}, {"type": "null"}]}
+				}
+			}
+		}
+	}
+}`
+
+func TestJSONSchema(t *testing.T) {
+	schema, err := routing.ParseJSONSchema([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("parse error: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{name: "valid", content: `{"status": "ok", "count": 2, "items": [{"id": "abc", "price": 1.5}, {"id": "de", "price": null}]}`, valid: true},
+		{name: "not json", content: `<html>`},
+		{name: "missing required", content: `{"status": "ok"}`},
+		{name: "enum", content: `{"status": "ko", "items": []}`},
+		{name: "integer", content: `{"status": "ok", "count": 1.5, "items": []}`},
+		{name: "minimum", content: `{"status": "ok", "count": -1, "items": []}`},
+		{name: "additional", content: `{"status": "ok", "items": [], "extra": true}`},
+		{name: "max items", content: `{"status": "ok", "items": [{}, {}, {}]}`},
+		{name: "pattern", content: `{"status": "ok", "items": [{"id": "ABC"}]}`},
+		{name: "max length", content: `{"status": "ok", "items": [{"id": "abcdef"}]}`},
+		{name: "any of", content: `{"status": "ok", "items": [{"price": 0}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.Validate([]byte(tt.content))
+			if tt.valid && err != nil {
+				t.Errorf("<schema> expected valid content, obtained %s\n", err)
+			}
+
+			if !tt.valid && !errors.Is(err, routing.ErrInvalidContent) {
+				t.Errorf("<schema> error not equal. expected %v obtained %v\n", routing.ErrInvalidContent, err)
+			}
+		})
+	}
+}
+
+func TestJSONSchemaKeepsPreviousContent(t *testing.T) {
+	var garbage int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&garbage) == 1 {
+			w.Write([]byte(`{"error": "maintenance"}`))
+			return
+		}
+		w.Write([]byte(`{"status": "ok", "items": []}`))
+	}))
+	defer srv.Close()
+
+	schema, err := routing.ParseJSONSchema([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("parse error: %s", err)
+	}
+
+	var fetchErrors int32
+	c := routing.NewResourceCacher(nil)
+	c.OnFetchError = func(res *routing.Resource, err error) {
+		atomic.AddInt32(&fetchErrors, 1)
+	}
+
+	res, err := c.AddResource(&routing.Resource{
+		Alias:      "validated",
+		Method:     http.MethodGet,
+		URL:        srv.URL,
+		Interval:   time.Hour,
+		JSONSchema: schema,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	atomic.StoreInt32(&garbage, 1)
+	if err := c.ForceRefresh("validated"); !errors.Is(err, routing.ErrInvalidContent) {
+		t.Errorf("<refresh> error not equal. expected %v obtained %v\n", routing.ErrInvalidContent, err)
+	}
+
+	if string(res.Snapshot().Content) != `{"status": "ok", "items": []}` {
+		t.Errorf("<resource> expected previous content to be kept, obtained %s\n", res.Snapshot().Content)
+	}
+
+	if !res.Degraded() || !res.Status().Degraded {
+		t.Errorf("<resource> expected resource to be degraded\n")
+	}
+
+	if atomic.LoadInt32(&fetchErrors) != 1 {
+		t.Errorf("<resource> fetch errors not equal. expected 1 obtained %v\n", atomic.LoadInt32(&fetchErrors))
+	}
+
+	atomic.StoreInt32(&garbage, 0)
+	if err := c.ForceRefresh("validated"); err != nil {
+		t.Fatalf("refresh error: %s", err)
+	}
+
+	if res.Degraded() {
+		t.Errorf("<resource> expected resource to recover\n")
+	}
+}
@@ -0,0 +1,157 @@
+package routing
+
+import (
+	"net/http"
+	"time"
+)
+
+// Snapshot is an immutable view of the cached content of a resource, replaced as a whole
+// on each fetch so that readers never see a new hash along with old content
+type Snapshot struct {
+	Alias      string
+	Content    []byte
+	Header     http.Header
+	StatusCode int
+	Hash       string
+	// LastModified is when the content last changed, to the second
+	LastModified time.Time
+	// ChangedAt is when the content hash last changed
+	ChangedAt time.Time
+
+	encoded         map[string][]byte
+	responseHeaders map[string]http.Header
+}
+
+// Snapshot returns the current cached content of the resource, never nil
+func (r *Resource) Snapshot() *Snapshot {
+	if s, ok := r.snapshot.Load().(*Snapshot); ok {
+		return s
+	}
+
+	return &Snapshot{Alias: r.Alias}
+}
+
+// view returns a resource holding the content of s, for hooks to see the content of an event
+// rather than the latest one
+func (r *Resource) view(s *Snapshot) *Resource {
+	v := &Resource{
+		Alias:      r.Alias,
+		Method:     r.Method,
+		URL:        r.URL,
+		Interval:   r.Interval,
+		Content:    s.Content,
+		Header:     s.Header,
+		StatusCode: s.StatusCode,
+		Hash:       s.Hash,
+		EventName:  r.EventName,
+
+		LastModified: s.LastModified,
+		ChangedAt:    s.ChangedAt,
+	}
+	v.snapshot.Store(s)
+
+	return v
+}
+
+// publish atomically replaces the snapshot with the current content of the resource
+func (r *Resource) publish() {
+	s := &Snapshot{
+		Alias:      r.Alias,
+		Content:    r.Content,
+		Header:     r.Header,
+		StatusCode: r.StatusCode,
+		Hash:       r.Hash,
+
+		LastModified: r.LastModified,
+		ChangedAt:    r.ChangedAt,
+	}
+
+	if r.prepareSnapshot != nil {
+		r.prepareSnapshot(s)
+	}
+
+	r.snapshot.Store(s)
+	r.record(s)
+}
+
+// record appends a snapshot with new content to the version history
+func (r *Resource) record(s *Snapshot) {
+	if r.MaxVersions <= 0 || s.Hash == "" {
+		return
+	}
+
+	r.historyMu.Lock()
+	defer r.historyMu.Unlock()
+
+	if n := len(r.history); n > 0 && r.history[n-1].Hash == s.Hash {
+		return
+	}
+
+	r.history = append(r.history, s)
+	if extra := len(r.history) - r.MaxVersions; extra > 0 {
+		r.history = append([]*Snapshot(nil), r.history[extra:]...)
+	}
+}
+
+// Versions returns the past contents of the resource kept according to MaxVersions, oldest first
+func (r *Resource) Versions() []*Snapshot {
+	r.historyMu.RLock()
+	defer r.historyMu.RUnlock()
+
+	return append([]*Snapshot(nil), r.history...)
+}
+
+// Version returns the content of the resource having a hash, current or kept in history
+func (r *Resource) Version(hash string) (*Snapshot, bool) {
+	if s := r.Snapshot(); s.Hash == hash && hash != "" {
+		return s, true
+	}
+
+	r.historyMu.RLock()
+	defer r.historyMu.RUnlock()
+
+	for i := len(r.history) - 1; i >= 0; i-- {
+		if r.history[i].Hash == hash {
+			return r.history[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// lastModified returns the upstream Last-Modified time, or now when missing, invalid or in the future
+func lastModified(header http.Header, now time.Time) time.Time {
+	now = now.UTC().Truncate(time.Second)
+
+	t, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil || t.After(now) {
+		return now
+	}
+
+	return t.UTC()
+}
+
+// notModified tells whether the client copy of a snapshot is still valid, If-None-Match
+// taking precedence over If-Modified-Since
+func notModified(r *http.Request, s *Snapshot, etag string) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		return etag == match
+	}
+
+	if s.LastModified.IsZero() {
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+
+	return !s.LastModified.After(since)
+}
+
+// prepareSnapshot precomputes the encoded variants and response headers of a snapshot
+func (c *ResourceCacher) prepareSnapshot(s *Snapshot) {
+	c.encode(s)
+	c.prepareHeaders(s)
+}
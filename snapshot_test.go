@@ -0,0 +1,83 @@
+package routing_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestVersionHistory(t *testing.T) {
+	var version int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("v" + strconv.Itoa(int(atomic.LoadInt32(&version)))))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	res, err := c.AddResource(&routing.Resource{
+		Alias:       "doc",
+		Method:      http.MethodGet,
+		URL:         srv.URL,
+		Interval:    time.Hour,
+		MaxVersions: 2,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	var hashes []string
+	for i := 0; i < 3; i++ {
+		atomic.StoreInt32(&version, int32(i))
+		if err := res.Fetch(); err != nil {
+			t.Fatalf("fetch error: %s", err)
+		}
+		// Unchanged content is not recorded twice
+		if err := res.Fetch(); err != nil {
+			t.Fatalf("fetch error: %s", err)
+		}
+		hashes = append(hashes, res.Snapshot().Hash)
+	}
+
+	versions := res.Versions()
+	if len(versions) != 2 || versions[0].Hash != hashes[1] || versions[1].Hash != hashes[2] {
+		t.Fatalf("<history> versions not equal. expected %v obtained %v\n", hashes[1:], versions)
+	}
+
+	tests := []struct {
+		name    string
+		version string
+		status  int
+		body    string
+	}{
+		{"current", hashes[2], http.StatusOK, "v2"},
+		{"previous", hashes[1], http.StatusOK, "v1"},
+		{"evicted", hashes[0], http.StatusNotFound, ""},
+		{"unknown", "nope", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?alias=doc&version="+tt.version, nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("<response> status not equal. expected %v obtained %v\n", tt.status, rec.Code)
+			}
+
+			body, _ := ioutil.ReadAll(rec.Body)
+			if tt.status == http.StatusOK && string(body) != tt.body {
+				t.Errorf("<response> body not equal. expected %v obtained %v\n", tt.body, string(body))
+			}
+
+			if tt.status == http.StatusOK && rec.Header().Get("Etag") != tt.version {
+				t.Errorf("<response> Etag not equal. expected %v obtained %v\n", tt.version, rec.Header().Get("Etag"))
+			}
+		})
+	}
+}
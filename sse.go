@@ -1,24 +1,78 @@
 package routing
 
 import (
-	"fmt"
 	"net/http"
-
-	"github.com/JulesMike/go-sse"
+	"time"
 )
 
 // SSEOptions augments Resource Cacher Options
 type SSEOptions struct {
 	*Options
 
+	// RetryInterval is the reconnection delay of clients in ms (defaults to 5000)
 	RetryInterval int
+	// QueueSize bounds the events pending for a client, slower clients being disconnected (defaults to 64)
+	QueueSize int
+	// SlowClientPolicy is applied to clients whose queue is full (defaults to SlowClientDisconnect)
+	SlowClientPolicy SlowClientPolicy
+	// MaxClients bounds the number of clients connected at once, others getting 503 (0 = unlimited)
+	MaxClients int
+	// MaxClientsPerChannel bounds the number of clients of each channel (0 = unlimited)
+	MaxClientsPerChannel int
+	// ReplaySize bounds the events kept per channel for clients reconnecting with Last-Event-ID (defaults to 32)
+	ReplaySize int
+	// Compress gzips the streams of clients accepting it, each event being flushed
+	Compress bool
+	// HeartbeatInterval is the delay after which idle streams get a ": ping" comment (disabled when zero)
+	HeartbeatInterval time.Duration
+	// WriteTimeout bounds each write to a client, dead clients being disconnected (defaults to 10s)
+	WriteTimeout time.Duration
+
+	// Authorize is called before subscribing a client to a resource, e.g. checking a token or a
+	// cookie. Errors deny the subscription with 401, or 403 when wrapping ErrForbidden
+	Authorize func(r *http.Request, alias string) error
+
+	// MessageFilter returns the content of a resource sent to a client, e.g. redacted according
+	// to its credentials, false suppressing the event. res holds the content of the event, which
+	// the resource may have replaced since
+	MessageFilter func(client SSEClient, res *Resource) ([]byte, bool)
+
+	// Delta sends the updates of JSON resources as RFC 6902 patches from the previous content,
+	// in "<event name>.patch" events. It is ignored when a MessageFilter is set
+	Delta bool
+	// SnapshotInterval is the number of updates after which the full content is sent again in Delta mode (defaults to 10)
+	SnapshotInterval int
+
+	// PayloadEncoding controls how events carry contents, binary ones being sent in base64 by default.
+	// Unless sent as text, SSEResourceCacher events carry the JSON message of CSSEResourceCacher
+	PayloadEncoding PayloadEncoding
+	// ContentURL is where clients fetch the contents sent as metadata, {alias} being replaced (e.g. "/resources/{alias}")
+	ContentURL string
+
+	// OnClientConnect is called when an EventSource client connects to a channel, see Clients for details
+	OnClientConnect func(channel string, remoteAddr string)
+	// OnClientDisconnect is called when an EventSource client drops
+	OnClientDisconnect func(channel string, remoteAddr string)
 }
 
+// SlowClientPolicy tells what happens to SSE clients too slow to keep up with the events
+type SlowClientPolicy int
+
+const (
+	// SlowClientDisconnect disconnects the client, which reconnects with Last-Event-ID
+	SlowClientDisconnect SlowClientPolicy = iota
+	// SlowClientDropOldest drops its oldest pending events, the client missing some updates.
+	// It does not suit Delta, clients needing every patch
+	SlowClientDropOldest
+)
+
 // SSEResourceCacher is an SSE variant of Resource Cacher
 type SSEResourceCacher struct {
 	*ResourceCacher
 
-	server *sse.Server
+	server  *sseServer
+	sseOpts *SSEOptions
+	delta   *sseDelta
 }
 
 // NewSSEResourceCacher returns a new SSE resource cachner
@@ -27,66 +81,39 @@ func NewSSEResourceCacher(opts *SSEOptions) *SSEResourceCacher {
 		opts = &SSEOptions{}
 	}
 
-	c := &SSEResourceCacher{ResourceCacher: NewResourceCacher(opts.Options)}
+	c := &SSEResourceCacher{ResourceCacher: NewResourceCacher(opts.Options), sseOpts: opts, delta: newSSEDelta(opts)}
 
 	// Increase default retry interval to 5s
 	if opts.RetryInterval == 0 {
 		opts.RetryInterval = 5 * 1000
 	}
 
-	// Create new SSE Server
-	c.server = sse.NewServer(&sse.Options{
-		RetryInterval: opts.RetryInterval,
-		Headers: map[string]string{
-			"Access-Control-Allow-Methods": "GET, OPTIONS",
-			"Access-Control-Allow-Headers": "Keep-Alive,X-Requested-With,Cache-Control,Content-Type,Last-Event-ID",
-		},
-		OnClientConnect: func(client *sse.Client) {
-			alias := client.Channel()
-
-			res, ok := c.resources[alias]
-			if !ok {
-				return
-			}
-
-			// Replay last message
-			client.SendMessage(sse.NewMessage(res.Hash, string(res.Content), "message"))
-		},
-		ChannelNameFunc: func(r *http.Request) string {
-			// Use alias query in url as channel name
-			alias, err := getAliasFromRequest(r)
-			if err != nil {
-				return r.URL.Path
-			}
-
-			return alias
-		},
-		Logger: c.ResourceCacher.opts.Logger,
-	})
-
-	c.OnResourceAdded = func(res *Resource) {
-		if c.server == nil || c.server.HasChannel(res.Alias) {
-			return
+	// Create new SSE Server, each resource having its own channel
+	c.server = newSSEServer(opts, c.opts.Logger, func(client *sseClient) []*sseEvent {
+		res, ok := c.GetResource(client.channel)
+		if !ok {
+			return nil
 		}
 
-		c.server.AddChannel(res.Alias)
-	}
-
-	c.OnResourceUpdated = func(res *Resource) {
-		if c.server == nil || !c.server.HasChannel(res.Alias) {
-			return
+		// Replay last message
+		snapshot := res.Snapshot()
+		data, err := encodeSSEData(opts, res, snapshot.Content)
+		if err != nil {
+			return nil
 		}
 
-		c.server.SendMessage(res.Alias, sse.NewMessage(res.Hash, string(res.Content), "message"))
-	}
+		e := newSSEEvent(snapshot.Hash, data, res.eventName())
+		e.res, e.snapshot = res, snapshot
+		return []*sseEvent{e}
+	})
+	c.server.filter = newSSEFilter(opts.MessageFilter, func(res *Resource, payload []byte) (string, error) {
+		return encodeSSEData(opts, res, payload)
+	})
 
-	c.OnResourceRemoved = func(res *Resource) {
-		if c.server == nil || !c.server.HasChannel(res.Alias) {
-			return
-		}
+	c.clientCount = c.server.ClientCount
+	c.sseStats = c.server.Stats
 
-		c.server.CloseChannel(res.Alias)
-	}
+	c.AddPublisher(c)
 
 	c.OnStarted = func() {
 		if c.server == nil {
@@ -107,6 +134,41 @@ func NewSSEResourceCacher(opts *SSEOptions) *SSEResourceCacher {
 	return c
 }
 
+// Publish implements Publisher, a channel being open for each resource
+func (c *SSEResourceCacher) Publish(event Event) error {
+	if c.server == nil {
+		return nil
+	}
+
+	alias := event.Snapshot.Alias
+	switch event.Type {
+	case EventAdded:
+		c.delta.forget(alias)
+		if !c.server.HasChannel(alias) {
+			c.server.AddChannel(alias)
+		}
+	case EventUpdated:
+		if c.server.HasChannel(alias) {
+			payload, name := c.delta.encode(event.Resource, event.Snapshot)
+			data, err := encodeSSEData(c.sseOpts, event.Resource, payload)
+			if err != nil {
+				return err
+			}
+
+			e := newSSEEvent(event.Snapshot.Hash, data, name)
+			e.res, e.snapshot = event.Resource, event.Snapshot
+			c.server.SendMessage(alias, e)
+		}
+	case EventRemoved:
+		c.delta.forget(alias)
+		if c.server.HasChannel(alias) {
+			c.server.CloseChannel(alias)
+		}
+	}
+
+	return nil
+}
+
 func (c *SSEResourceCacher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if c.server == nil {
 		w.WriteHeader(http.StatusServiceUnavailable)
@@ -114,28 +176,78 @@ func (c *SSEResourceCacher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	alias, err := getAliasFromRequest(r)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(fmt.Sprintf("%v", err)))
+	resource, ok := c.resolveRequest(w, r)
+	if !ok {
 		return
 	}
 
-	resource, ok := c.resources[alias]
-	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Invalid alias"))
+	if isPreflight(r) {
+		writePreflight(w, r, c.corsFor(resource))
 		return
 	}
 
-	origin := r.Header.Get("Origin")
-	if !resource.IsOriginAllowed(origin) {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("Invalid Origin"))
-		return
+	if c.sseOpts.Authorize != nil {
+		if err := c.sseOpts.Authorize(r, resource.Alias); err != nil {
+			c.rejectUnauthenticated(w, resource, err)
+			return
+		}
 	}
 
 	writeCommonHeaders(w, r)
+	writeCORSHeaders(w, c.corsFor(resource))
+
+	// Blocks until the client is gone
+	c.server.ServeHTTP(w, r, resource.Alias)
+}
+
+// Clients returns the connected EventSource clients, oldest first
+func (c *SSEResourceCacher) Clients() []SSEClient {
+	if c.server == nil {
+		return nil
+	}
+
+	return c.server.Clients()
+}
+
+// newSSEFilter adapts a MessageFilter to the SSE server, encode building the event data. The
+// filter is given the resource as it was when the event fired, not its latest content.
+func newSSEFilter(filter func(client SSEClient, res *Resource) ([]byte, bool), encode func(res *Resource, payload []byte) (string, error)) func(cl *sseClient, e *sseEvent) (*sseEvent, bool) {
+	if filter == nil {
+		return nil
+	}
+
+	return func(cl *sseClient, e *sseEvent) (*sseEvent, bool) {
+		if e.res == nil {
+			return e, true
+		}
+
+		res := e.res
+		if e.snapshot != nil {
+			res = res.view(e.snapshot)
+		}
+
+		payload, ok := filter(cl.info(), res)
+		if !ok {
+			return nil, false
+		}
+
+		data, err := encode(res, payload)
+		if err != nil {
+			return nil, false
+		}
+
+		filtered := *e
+		filtered.data = data
+
+		return &filtered, true
+	}
+}
+
+// eventName returns the type of the SSE events of the resource
+func (r *Resource) eventName() string {
+	if r.EventName != "" {
+		return r.EventName
+	}
 
-	c.server.ServeHTTP(w, r)
+	return r.Alias
 }
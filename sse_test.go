@@ -0,0 +1,485 @@
+package routing_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+type clientEvent struct {
+	kind    string
+	channel string
+}
+
+// subscribe opens an EventSource-like stream, waits for the first event then disconnects
+func subscribe(t *testing.T, url string) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatalf("subscribe error: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := bufio.NewReader(resp.Body).ReadString('\n'); err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+}
+
+func waitClientEvents(t *testing.T, events chan clientEvent, expected []clientEvent) {
+	t.Helper()
+
+	for _, e := range expected {
+		select {
+		case got := <-events:
+			if got != e {
+				t.Errorf("<callback> event not equal. expected %v obtained %v\n", e, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("<callback> timed out waiting for %v\n", e)
+		}
+	}
+}
+
+func TestSSEClientCallbacks(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer upstream.Close()
+
+	newOpts := func(events chan clientEvent) *routing.SSEOptions {
+		return &routing.SSEOptions{
+			OnClientConnect: func(channel string, remoteAddr string) {
+				events <- clientEvent{"connect", channel}
+			},
+			OnClientDisconnect: func(channel string, remoteAddr string) {
+				events <- clientEvent{"disconnect", channel}
+			},
+		}
+	}
+
+	res := func() *routing.Resource {
+		return &routing.Resource{
+			Alias:    "clock",
+			Method:   http.MethodGet,
+			URL:      upstream.URL,
+			Interval: time.Second,
+		}
+	}
+
+	t.Run("sse", func(t *testing.T) {
+		events := make(chan clientEvent, 2)
+		c := routing.NewSSEResourceCacher(newOpts(events))
+		if _, err := c.AddResource(res(), nil); err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+
+		srv := httptest.NewServer(c)
+		defer srv.Close()
+
+		subscribe(t, srv.URL+"/?alias=clock")
+		waitClientEvents(t, events, []clientEvent{{"connect", "clock"}, {"disconnect", "clock"}})
+	})
+
+	t.Run("csse", func(t *testing.T) {
+		events := make(chan clientEvent, 2)
+		c := routing.NewCSSEResourceCacher(newOpts(events))
+		if _, err := c.AddResource(res(), nil); err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+		c.Start()
+
+		srv := httptest.NewServer(c)
+		defer srv.Close()
+
+		subscribe(t, srv.URL+"/")
+		waitClientEvents(t, events, []clientEvent{{"connect", "common"}, {"disconnect", "common"}})
+	})
+}
+
+// readEvent reads the first event of an SSE stream and returns its type and data
+func readEvent(t *testing.T, req *http.Request) (string, string) {
+	t.Helper()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("subscribe error: %s", err)
+	}
+	defer resp.Body.Close()
+
+	var name, data string
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read error: %s", err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return name, data
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+// readEventName reads the first event of an SSE stream and returns its type
+func readEventName(t *testing.T, url string) string {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+
+	name, _ := readEvent(t, req)
+
+	return name
+}
+
+func TestSSEEventName(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name      string
+		eventName string
+		expected  string
+	}{
+		{"default", "", "clock"},
+		{"custom", "tick", "tick"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := routing.NewSSEResourceCacher(nil)
+			res := &routing.Resource{
+				Alias:     "clock",
+				Method:    http.MethodGet,
+				URL:       upstream.URL,
+				Interval:  time.Second,
+				EventName: tt.eventName,
+			}
+			if _, err := c.AddResource(res, nil); err != nil {
+				t.Fatalf("add resource error: %s", err)
+			}
+
+			srv := httptest.NewServer(c)
+			defer srv.Close()
+
+			if name := readEventName(t, srv.URL+"/?alias=clock"); name != tt.expected {
+				t.Errorf("<event> name not equal. expected %v obtained %v\n", tt.expected, name)
+			}
+		})
+	}
+}
+
+func TestCSSEAliases(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer upstream.Close()
+
+	c := routing.NewCSSEResourceCacher(nil)
+	for _, alias := range []string{"clock", "weather"} {
+		res := &routing.Resource{
+			Alias:    alias,
+			Method:   http.MethodGet,
+			URL:      upstream.URL,
+			Interval: time.Second,
+		}
+		if _, err := c.AddResource(res, nil); err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+	}
+	c.Start()
+	defer c.Stop()
+
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	for i := 0; i < 5; i++ {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/?aliases=weather", nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+
+		if _, data := readEvent(t, req); !strings.HasPrefix(data, `{"alias":"weather",`) {
+			t.Fatalf("<event> data not equal. expected weather obtained %v\n", data)
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "/?aliases=weather,unknown")
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("<response> code not equal. expected %v obtained %v\n", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestSSEMessageFilter(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer upstream.Close()
+
+	c := routing.NewCSSEResourceCacher(&routing.SSEOptions{
+		MessageFilter: func(client routing.SSEClient, res *routing.Resource) ([]byte, bool) {
+			if res.Alias == "clock" {
+				return nil, false
+			}
+			if client.Request.Header.Get("X-Role") != "admin" {
+				return []byte(`{"status": "redacted"}`), true
+			}
+			return res.Snapshot().Content, true
+		},
+	})
+	for _, alias := range []string{"clock", "weather"} {
+		res := &routing.Resource{
+			Alias:    alias,
+			Method:   http.MethodGet,
+			URL:      upstream.URL,
+			Interval: time.Second,
+		}
+		if _, err := c.AddResource(res, nil); err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+	}
+	c.Start()
+	defer c.Stop()
+
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	tests := []struct {
+		role     string
+		expected string
+	}{
+		{"", `{"alias":"weather","payload":"{\"status\": \"redacted\"}"}`},
+		{"admin", `{"alias":"weather","payload":"{\"status\": \"ok\"}"}`},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if err != nil {
+			t.Fatalf("request error: %s", err)
+		}
+		req.Header.Set("X-Role", tt.role)
+
+		if _, data := readEvent(t, req); data != tt.expected {
+			t.Errorf("<event> data not equal. expected %v obtained %v\n", tt.expected, data)
+		}
+	}
+}
+
+func TestCSSEInitDone(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer upstream.Close()
+
+	c := routing.NewCSSEResourceCacher(nil)
+	res := &routing.Resource{
+		Alias:    "clock",
+		Method:   http.MethodGet,
+		URL:      upstream.URL,
+		Interval: time.Second,
+	}
+	if _, err := c.AddResource(res, nil); err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+	c.Start()
+	defer c.Stop()
+
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("subscribe error: %s", err)
+	}
+	defer resp.Body.Close()
+
+	expected := []string{
+		"id: clock-" + res.Snapshot().Hash,
+		"retry: 5000",
+		"event: snapshot",
+		`data: {"alias":"clock","payload":"{\"status\": \"ok\"}"}`,
+		"",
+		"retry: 5000",
+		"event: init-done",
+		`data: {"aliases":["clock"]}`,
+		"",
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	for _, e := range expected {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read error: %s", err)
+		}
+		if line = strings.TrimSuffix(line, "\n"); line != e {
+			t.Errorf("<response> line not equal. expected %v obtained %v\n", e, line)
+		}
+	}
+}
+
+func TestSSEClients(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer upstream.Close()
+
+	connected := make(chan struct{}, 1)
+	c := routing.NewCSSEResourceCacher(&routing.SSEOptions{
+		OnClientConnect: func(channel string, remoteAddr string) {
+			connected <- struct{}{}
+		},
+	})
+	for _, alias := range []string{"clock", "weather"} {
+		res := &routing.Resource{
+			Alias:    alias,
+			Method:   http.MethodGet,
+			URL:      upstream.URL,
+			Interval: time.Second,
+		}
+		if _, err := c.AddResource(res, nil); err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+	}
+	c.Start()
+	defer c.Stop()
+
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/?aliases=weather,clock", nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	req.Header.Set("X-Client", "dashboard")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("subscribe error: %s", err)
+	}
+	defer resp.Body.Close()
+
+	select {
+	case <-connected:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("<callback> timed out waiting for connect\n")
+	}
+
+	clients := c.Clients()
+	if len(clients) != 1 {
+		t.Fatalf("<clients> count not equal. expected %v obtained %v\n", 1, len(clients))
+	}
+
+	client := clients[0]
+	if client.Channel != "common" {
+		t.Errorf("<client> channel not equal. expected %v obtained %v\n", "common", client.Channel)
+	}
+	if got := strings.Join(client.Aliases, ","); got != "clock,weather" {
+		t.Errorf("<client> aliases not equal. expected %v obtained %v\n", "clock,weather", got)
+	}
+	if got := client.Header.Get("X-Client"); got != "dashboard" {
+		t.Errorf("<client> header not equal. expected %v obtained %v\n", "dashboard", got)
+	}
+}
+
+func TestSSEAuthorize(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer upstream.Close()
+
+	opts := func() *routing.SSEOptions {
+		return &routing.SSEOptions{
+			Authorize: func(r *http.Request, alias string) error {
+				switch r.URL.Query().Get("token") {
+				case "":
+					return routing.ErrUnauthenticated
+				case "secret":
+					return nil
+				default:
+					return fmt.Errorf("token not allowed for %s: %w", alias, routing.ErrForbidden)
+				}
+			},
+		}
+	}
+
+	res := func() *routing.Resource {
+		return &routing.Resource{
+			Alias:    "clock",
+			Method:   http.MethodGet,
+			URL:      upstream.URL,
+			Interval: time.Second,
+		}
+	}
+
+	sse := routing.NewSSEResourceCacher(opts())
+	if _, err := sse.AddResource(res(), nil); err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	csse := routing.NewCSSEResourceCacher(opts())
+	if _, err := csse.AddResource(res(), nil); err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+	csse.Start()
+	defer csse.Stop()
+
+	tests := []struct {
+		name     string
+		handler  http.Handler
+		query    string
+		expected int
+	}{
+		{"sse missing token", sse, "?alias=clock", http.StatusUnauthorized},
+		{"sse wrong token", sse, "?alias=clock&token=guess", http.StatusForbidden},
+		{"sse", sse, "?alias=clock&token=secret", http.StatusOK},
+		{"csse missing token", csse, "", http.StatusUnauthorized},
+		{"csse", csse, "?token=secret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			resp, err := http.Get(srv.URL + "/" + tt.query)
+			if err != nil {
+				t.Fatalf("request error: %s", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.expected {
+				t.Errorf("<response> code not equal. expected %v obtained %v\n", tt.expected, resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package routing
+
+// SSEStats counts the events of a resource streamed to SSE clients
+type SSEStats struct {
+	// Events is the number of events broadcast
+	Events int64 `json:"events"`
+	// DroppedEvents is the number of events not delivered to clients too slow to keep up
+	DroppedEvents int64 `json:"dropped_events"`
+	// SendErrors is the number of failed writes to clients
+	SendErrors int64 `json:"send_errors"`
+	// AvgPayloadSize is the average size of the events data in bytes
+	AvgPayloadSize int64 `json:"avg_payload_size"`
+}
+
+type sseCounters struct {
+	events  int64
+	dropped int64
+	errors  int64
+	bytes   int64
+}
+
+// statsKey returns the alias an event is counted for, its topic for CSSE and its channel otherwise
+func statsKey(channel string, e *sseEvent) string {
+	if e != nil && e.topic != "" {
+		return e.topic
+	}
+
+	return channel
+}
+
+// count updates the counters of an alias
+func (s *sseServer) count(key string, update func(c *sseCounters)) {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+
+	c, ok := s.stats[key]
+	if !ok {
+		c = &sseCounters{}
+		s.stats[key] = c
+	}
+	update(c)
+}
+
+// Stats returns the counters of an alias, nil when no event was streamed
+func (s *sseServer) Stats(key string) *SSEStats {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+
+	c, ok := s.stats[key]
+	if !ok {
+		return nil
+	}
+
+	stats := &SSEStats{Events: c.events, DroppedEvents: c.dropped, SendErrors: c.errors}
+	if c.events > 0 {
+		stats.AvgPayloadSize = c.bytes / c.events
+	}
+
+	return stats
+}
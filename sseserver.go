@@ -0,0 +1,670 @@
+package routing
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSSEQueueSize    = 64
+	defaultSSEReplaySize   = 32
+	defaultSSEWriteTimeout = 10 * time.Second
+)
+
+// sseHeartbeat is a comment line, ignored by EventSource clients
+const sseHeartbeat = ": ping\n\n"
+
+// sseShutdownEvent is the last event sent to clients when the server shuts down
+const sseShutdownEvent = "server-shutdown"
+
+// sseDataLines splits data on every line terminator of the event stream format, so that a
+// lone "\r" cannot end a data line early on the client
+var sseDataLines = strings.NewReplacer("\r\n", "\ndata: ", "\r", "\ndata: ", "\n", "\ndata: ")
+
+// writeDeadliner is implemented by the ResponseWriter of net/http servers
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// sseEvent is a server-sent event
+type sseEvent struct {
+	id    string
+	event string
+	data  string
+
+	// topic restricts the event to the clients subscribed to it, when set
+	topic string
+	// res is the resource whose content the event carries, if any, snapshot being that content
+	res      *Resource
+	snapshot *Snapshot
+}
+
+// newSSEEvent returns an event of type "message" unless event is set
+func newSSEEvent(id, data, event string) *sseEvent {
+	return &sseEvent{id: id, event: event, data: data}
+}
+
+// writeTo writes the event in the text/event-stream format, along with the reconnection delay in ms
+func (e *sseEvent) writeTo(w io.Writer, retry int) error {
+	var b strings.Builder
+
+	if e.id != "" {
+		b.WriteString("id: " + e.id + "\n")
+	}
+
+	if retry > 0 {
+		b.WriteString("retry: " + strconv.Itoa(retry) + "\n")
+	}
+
+	if e.event != "" {
+		b.WriteString("event: " + e.event + "\n")
+	}
+
+	if e.data != "" {
+		b.WriteString("data: " + sseDataLines.Replace(e.data) + "\n")
+	}
+
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// SSEClient describes an EventSource client
+type SSEClient struct {
+	// Channel is the alias of the resource streamed, or "common" for CSSE
+	Channel string
+	// RemoteAddr is the address of the client
+	RemoteAddr string
+	// Aliases are the resources the client subscribed to, all when empty
+	Aliases []string
+	// Header holds the headers of the request which opened the stream
+	Header http.Header
+	// ConnectedAt is when the stream was opened
+	ConnectedAt time.Time
+	// Request is the request which opened the stream, carrying e.g. credentials
+	Request *http.Request
+}
+
+// sseClient is a connected EventSource along with its queue of pending events
+type sseClient struct {
+	channel     string
+	topics      map[string]struct{}
+	r           *http.Request
+	send        chan *sseEvent
+	done        chan struct{}
+	once        sync.Once
+	dropOldest  bool
+	connectedAt time.Time
+	goodbye     *sseEvent
+	exited      chan struct{}
+
+	lastEventID string
+	mu          sync.Mutex
+}
+
+// push queues an event, unless the client already got it. It returns false when the
+// queue is full, the client being too slow to keep up, unless its oldest events are
+// dropped, discard being called with each of them if set.
+func (cl *sseClient) push(e *sseEvent, discard func(old *sseEvent)) bool {
+	if !cl.subscribed(e.topic) {
+		return true
+	}
+
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if e.id != "" && e.id == cl.lastEventID {
+		return true
+	}
+
+	for {
+		select {
+		case cl.send <- e:
+			if e.id != "" {
+				cl.lastEventID = e.id
+			}
+			return true
+		default:
+		}
+
+		if !cl.dropOldest {
+			return false
+		}
+
+		select {
+		case old := <-cl.send:
+			if discard != nil {
+				discard(old)
+			}
+		default:
+		}
+	}
+}
+
+// subscribed tells whether the client gets the events of a topic, clients connected
+// without topics getting every event
+func (cl *sseClient) subscribed(topic string) bool {
+	if topic == "" || cl.topics == nil {
+		return true
+	}
+
+	_, ok := cl.topics[topic]
+
+	return ok
+}
+
+// seen tells whether the client already got an event, recording it as the last one otherwise
+func (cl *sseClient) seen(e *sseEvent) bool {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if e.id == "" {
+		return false
+	}
+	if e.id == cl.lastEventID {
+		return true
+	}
+	cl.lastEventID = e.id
+
+	return false
+}
+
+// LastEventID returns the id of the last event queued, initially the Last-Event-ID header
+func (cl *sseClient) LastEventID() string {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	return cl.lastEventID
+}
+
+// info describes the client to applications
+func (cl *sseClient) info() SSEClient {
+	var aliases []string
+	for alias := range cl.topics {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	return SSEClient{
+		Channel:     cl.channel,
+		RemoteAddr:  cl.r.RemoteAddr,
+		Aliases:     aliases,
+		Header:      cl.r.Header,
+		ConnectedAt: cl.connectedAt,
+		Request:     cl.r,
+	}
+}
+
+// drop disconnects the client
+func (cl *sseClient) drop() {
+	cl.once.Do(func() { close(cl.done) })
+}
+
+// leave disconnects the client once it got a last event
+func (cl *sseClient) leave(goodbye *sseEvent) {
+	cl.mu.Lock()
+	cl.goodbye = goodbye
+	cl.mu.Unlock()
+
+	cl.drop()
+}
+
+// farewell returns the last event of a disconnected client, if any
+func (cl *sseClient) farewell() *sseEvent {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	return cl.goodbye
+}
+
+// sseServer streams events to EventSource clients grouped in channels
+type sseServer struct {
+	opts   *SSEOptions
+	logger Logger
+	replay func(cl *sseClient) []*sseEvent
+	// filter rewrites the events sent to a client, false suppressing them
+	filter func(cl *sseClient, e *sseEvent) (*sseEvent, bool)
+
+	channels map[string]map[*sseClient]struct{}
+	history  map[string][]*sseEvent
+	closed   bool
+	mu       sync.RWMutex
+
+	stats   map[string]*sseCounters
+	statsMu sync.Mutex
+}
+
+// newSSEServer returns an SSE server, replay returning the events sent to new clients
+func newSSEServer(opts *SSEOptions, logger Logger, replay func(cl *sseClient) []*sseEvent) *sseServer {
+	return &sseServer{
+		opts:     opts,
+		logger:   logger,
+		replay:   replay,
+		channels: make(map[string]map[*sseClient]struct{}),
+		history:  make(map[string][]*sseEvent),
+		stats:    make(map[string]*sseCounters),
+	}
+}
+
+// AddChannel opens a channel, doing nothing when it exists
+func (s *sseServer) AddChannel(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.channels[name]; !ok {
+		s.channels[name] = make(map[*sseClient]struct{})
+	}
+}
+
+// HasChannel tells whether a channel is open
+func (s *sseServer) HasChannel(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.channels[name]
+
+	return ok
+}
+
+// CloseChannel disconnects the clients of a channel and closes it, forgetting its counters
+func (s *sseServer) CloseChannel(name string) {
+	s.mu.Lock()
+	s.closeChannel(name)
+	s.mu.Unlock()
+
+	s.statsMu.Lock()
+	delete(s.stats, name)
+	s.statsMu.Unlock()
+}
+
+func (s *sseServer) closeChannel(name string) {
+	for cl := range s.channels[name] {
+		cl.drop()
+	}
+	delete(s.channels, name)
+	delete(s.history, name)
+}
+
+// full tells whether a client may not connect to a channel, according to the connection limits
+func (s *sseServer) full(channel string) bool {
+	if s.opts.MaxClientsPerChannel > 0 && len(s.channels[channel]) >= s.opts.MaxClientsPerChannel {
+		return true
+	}
+
+	if s.opts.MaxClients > 0 {
+		clients := 0
+		for _, cls := range s.channels {
+			clients += len(cls)
+		}
+		if clients >= s.opts.MaxClients {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientCount returns the number of clients of a channel
+func (s *sseServer) ClientCount(name string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.channels[name])
+}
+
+// Clients returns the connected clients, oldest first
+func (s *sseServer) Clients() []SSEClient {
+	s.mu.RLock()
+	clients := make([]SSEClient, 0, len(s.channels))
+	for _, cls := range s.channels {
+		for cl := range cls {
+			clients = append(clients, cl.info())
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].ConnectedAt.Before(clients[j].ConnectedAt)
+	})
+
+	return clients
+}
+
+// SendMessage queues an event for the clients of a channel, disconnecting the clients
+// whose queue is full so that they reconnect with Last-Event-ID
+func (s *sseServer) SendMessage(channel string, e *sseEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e.id != "" {
+		s.remember(channel, e)
+	}
+
+	s.count(statsKey(channel, e), func(c *sseCounters) {
+		c.events++
+		c.bytes += int64(len(e.data))
+	})
+
+	discard := func(old *sseEvent) {
+		s.count(statsKey(channel, old), func(c *sseCounters) { c.dropped++ })
+	}
+
+	for cl := range s.channels[channel] {
+		if !cl.push(e, discard) {
+			discard(e)
+			s.logger.Warn("slow sse client dropped", F("channel", channel), F("remote_addr", cl.r.RemoteAddr))
+			cl.drop()
+		}
+	}
+}
+
+// remember keeps an event in the replay buffer of a channel, dropping the oldest ones
+func (s *sseServer) remember(channel string, e *sseEvent) {
+	size := s.opts.ReplaySize
+	if size <= 0 {
+		size = defaultSSEReplaySize
+	}
+
+	history := append(s.history[channel], e)
+	if len(history) > size {
+		history = append([]*sseEvent(nil), history[len(history)-size:]...)
+	}
+	s.history[channel] = history
+}
+
+// missed returns the events of a channel sent after lastEventID, false when it is no
+// longer buffered
+func (s *sseServer) missed(channel, lastEventID string) ([]*sseEvent, bool) {
+	history := s.history[channel]
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].id == lastEventID {
+			return append([]*sseEvent(nil), history[i+1:]...), true
+		}
+	}
+
+	return nil, false
+}
+
+// Restart disconnects all clients and closes all channels, accepting new clients
+func (s *sseServer) Restart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name := range s.channels {
+		s.closeChannel(name)
+	}
+	s.closed = false
+}
+
+// Shutdown sends a last event suggesting a retry delay to all clients, disconnecting them once
+// written, and refuses new clients until restarted
+func (s *sseServer) Shutdown() {
+	goodbye := newSSEEvent("", fmt.Sprintf(`{"retry":%d}`, s.opts.RetryInterval), sseShutdownEvent)
+
+	s.mu.Lock()
+	var clients []*sseClient
+	for name, cls := range s.channels {
+		for cl := range cls {
+			cl.leave(goodbye)
+			clients = append(clients, cl)
+		}
+		delete(s.channels, name)
+		delete(s.history, name)
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	// Each write being bounded, so is the drain
+	timeout := time.After(s.writeTimeout())
+	for _, cl := range clients {
+		select {
+		case <-cl.exited:
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// ServeHTTP streams the events of a channel to a client until it disconnects or is dropped,
+// restricted to the given topics if any
+func (s *sseServer) ServeHTTP(w http.ResponseWriter, r *http.Request, channel string, topics ...string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported.", http.StatusInternalServerError)
+		return
+	}
+
+	h := w.Header()
+	h.Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	h.Set("Access-Control-Allow-Headers", "Keep-Alive,X-Requested-With,Cache-Control,Content-Type,Last-Event-ID")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodOptions:
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	queueSize := s.opts.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultSSEQueueSize
+	}
+
+	cl := &sseClient{
+		channel:     channel,
+		r:           r,
+		send:        make(chan *sseEvent, queueSize),
+		done:        make(chan struct{}),
+		lastEventID: r.Header.Get("Last-Event-ID"),
+		dropOldest:  s.opts.SlowClientPolicy == SlowClientDropOldest,
+		connectedAt: time.Now(),
+		exited:      make(chan struct{}),
+	}
+	defer close(cl.exited)
+	if len(topics) > 0 {
+		cl.topics = make(map[string]struct{}, len(topics))
+		for _, topic := range topics {
+			cl.topics[topic] = struct{}{}
+		}
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		http.Error(w, "SSE server stopped", http.StatusServiceUnavailable)
+		return
+	}
+	if s.full(channel) {
+		s.mu.Unlock()
+		s.logger.Warn("too many sse clients", F("channel", channel), F("remote_addr", r.RemoteAddr))
+		h.Set("Retry-After", strconv.Itoa(s.retryAfter()))
+		http.Error(w, "Too many SSE clients", http.StatusServiceUnavailable)
+		return
+	}
+	if _, ok := s.channels[channel]; !ok {
+		s.channels[channel] = make(map[*sseClient]struct{})
+	}
+	s.channels[channel][cl] = struct{}{}
+	missed, resumed := s.missed(channel, cl.lastEventID)
+	s.mu.Unlock()
+
+	if s.opts.OnClientConnect != nil {
+		s.opts.OnClientConnect(channel, r.RemoteAddr)
+	}
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.channels[channel], cl)
+		s.mu.Unlock()
+
+		if s.opts.OnClientDisconnect != nil {
+			s.opts.OnClientDisconnect(channel, r.RemoteAddr)
+		}
+	}()
+
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Connection", "keep-alive")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+
+	st := &sseStream{w: w, out: w, flusher: flusher}
+	if s.opts.Compress {
+		h.Add("Vary", "Accept-Encoding")
+		if negotiateEncoding(r.Header.Get("Accept-Encoding"), []Encoding{GzipEncoding}) == GzipEncoding.Name {
+			h.Set("Content-Encoding", GzipEncoding.Name)
+			st.gz = gzip.NewWriter(w)
+			st.out = st.gz
+		}
+	}
+	defer st.close()
+
+	w.WriteHeader(http.StatusOK)
+	st.flush()
+
+	// Reconnecting clients get the events they missed, falling back on the current
+	// content. Updates queued meanwhile are newer than the replayed ones.
+	replay := missed
+	if !resumed && s.replay != nil {
+		replay = s.replay(cl)
+	}
+	for _, e := range replay {
+		if !cl.subscribed(e.topic) || cl.seen(e) {
+			continue
+		}
+		if err := s.deliver(st, cl, e); err != nil {
+			return
+		}
+	}
+
+	// Idle streams get a heartbeat so that proxies keep them open
+	var heartbeat <-chan time.Time
+	if s.opts.HeartbeatInterval > 0 {
+		ticker := time.NewTicker(s.opts.HeartbeatInterval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+	idle := true
+
+	for {
+		select {
+		case e := <-cl.send:
+			if err := s.deliver(st, cl, e); err != nil {
+				return
+			}
+			idle = false
+		case <-heartbeat:
+			if !idle {
+				idle = true
+				continue
+			}
+			if err := s.write(st, nil); err != nil {
+				s.count(channel, func(c *sseCounters) { c.errors++ })
+				return
+			}
+		case <-cl.done:
+			if e := cl.farewell(); e != nil {
+				s.write(st, e)
+			}
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// retryAfter returns the delay in seconds after which rejected clients may retry
+func (s *sseServer) retryAfter() int {
+	if seconds := s.opts.RetryInterval / 1000; seconds > 0 {
+		return seconds
+	}
+
+	return 1
+}
+
+// writeTimeout returns the time a client has to read each event
+func (s *sseServer) writeTimeout() time.Duration {
+	if s.opts.WriteTimeout > 0 {
+		return s.opts.WriteTimeout
+	}
+
+	return defaultSSEWriteTimeout
+}
+
+// deliver sends an event to a client unless filtered out
+func (s *sseServer) deliver(st *sseStream, cl *sseClient, e *sseEvent) error {
+	if s.filter != nil {
+		var ok bool
+		if e, ok = s.filter(cl, e); !ok {
+			return nil
+		}
+	}
+
+	if err := s.write(st, e); err != nil {
+		s.count(statsKey(cl.channel, e), func(c *sseCounters) { c.errors++ })
+		return err
+	}
+
+	return nil
+}
+
+// write sends an event, or a heartbeat when nil, failing when the client does not
+// read it in time
+func (s *sseServer) write(st *sseStream, e *sseEvent) error {
+	if dw, ok := st.w.(writeDeadliner); ok {
+		dw.SetWriteDeadline(time.Now().Add(s.writeTimeout()))
+	}
+
+	var err error
+	if e == nil {
+		_, err = io.WriteString(st.out, sseHeartbeat)
+	} else {
+		err = e.writeTo(st.out, s.opts.RetryInterval)
+	}
+	if err != nil {
+		return err
+	}
+
+	return st.flush()
+}
+
+// sseStream is the response to a client, gzipped when negotiated
+type sseStream struct {
+	w       http.ResponseWriter
+	out     io.Writer
+	gz      *gzip.Writer
+	flusher http.Flusher
+}
+
+// flush sends the events written so far to the client
+func (st *sseStream) flush() error {
+	if st.gz != nil {
+		if err := st.gz.Flush(); err != nil {
+			return err
+		}
+	}
+
+	st.flusher.Flush()
+
+	return nil
+}
+
+// close ends the compressed stream, if any
+func (st *sseStream) close() {
+	if st.gz == nil {
+		return
+	}
+
+	st.gz.Close()
+	st.flusher.Flush()
+}
@@ -0,0 +1,311 @@
+package routing
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSSEEventFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    *sseEvent
+		retry    int
+		expected string
+	}{
+		{"data", newSSEEvent("", "hello", ""), 0, "data: hello\n\n"},
+		{"full", newSSEEvent("1", "hello", "update"), 3000, "id: 1\nretry: 3000\nevent: update\ndata: hello\n\n"},
+		{"multiline", newSSEEvent("2", "a\nb", ""), 0, "id: 2\ndata: a\ndata: b\n\n"},
+		{"crlf", newSSEEvent("3", "a\r\nb\rc\n\rd", ""), 0, "id: 3\ndata: a\ndata: b\ndata: c\ndata: \ndata: d\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := tt.event.writeTo(&b, tt.retry); err != nil {
+				t.Fatalf("write error: %s", err)
+			}
+			if b.String() != tt.expected {
+				t.Errorf("<event> not equal. expected %q obtained %q\n", tt.expected, b.String())
+			}
+		})
+	}
+}
+
+func TestSSEServerSlowClient(t *testing.T) {
+	s := newSSEServer(&SSEOptions{}, NopLogger(), nil)
+
+	cl := &sseClient{
+		channel: "clock",
+		r:       httptest.NewRequest(http.MethodGet, "/", nil),
+		send:    make(chan *sseEvent, 1),
+		done:    make(chan struct{}),
+	}
+	s.AddChannel("clock")
+	s.channels["clock"][cl] = struct{}{}
+
+	s.SendMessage("clock", newSSEEvent("1", "a", ""))
+	s.SendMessage("clock", newSSEEvent("1", "a", ""))
+	select {
+	case <-cl.done:
+		t.Fatalf("<client> dropped on a duplicate event\n")
+	default:
+	}
+
+	s.SendMessage("clock", newSSEEvent("2", "b", ""))
+	select {
+	case <-cl.done:
+	default:
+		t.Errorf("<client> not dropped with a full queue\n")
+	}
+}
+
+func TestSSEFilterEventSnapshot(t *testing.T) {
+	res := &Resource{Alias: "clock", Content: []byte("12:01")}
+	res.publish()
+
+	filter := newSSEFilter(func(client SSEClient, res *Resource) ([]byte, bool) {
+		return res.Snapshot().Content, string(res.Content) == string(res.Snapshot().Content)
+	}, func(res *Resource, payload []byte) (string, error) {
+		return string(payload), nil
+	})
+
+	// The event fired before the resource got newer content
+	e := newSSEEvent("1", "12:00", "")
+	e.res, e.snapshot = res, &Snapshot{Alias: "clock", Content: []byte("12:00"), Hash: "1"}
+
+	cl := &sseClient{channel: "clock", r: httptest.NewRequest(http.MethodGet, "/", nil)}
+	filtered, ok := filter(cl, e)
+	if !ok || filtered.data != "12:00" {
+		t.Errorf("<filter> data not equal. expected %v obtained %v (%v)\n", "12:00", filtered, ok)
+	}
+}
+
+func TestSSEServerShutdown(t *testing.T) {
+	s := newSSEServer(&SSEOptions{}, NopLogger(), nil)
+	s.Shutdown()
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil), "clock")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("<response> code not equal. expected %v obtained %v\n", http.StatusServiceUnavailable, w.Code)
+	}
+}
+
+func TestSSEServerReplay(t *testing.T) {
+	s := newSSEServer(&SSEOptions{ReplaySize: 2}, NopLogger(), func(cl *sseClient) []*sseEvent {
+		return []*sseEvent{newSSEEvent("3", "c", "")}
+	})
+	s.AddChannel("clock")
+	for _, id := range []string{"1", "2", "3"} {
+		s.SendMessage("clock", newSSEEvent(id, id, ""))
+	}
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		expected    string
+	}{
+		{"fresh", "", "id: 3\ndata: c\n\n"},
+		{"missed", "2", "id: 3\ndata: 3\n\n"},
+		{"up to date", "3", ""},
+		{"evicted", "1", "id: 3\ndata: c\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+			if tt.lastEventID != "" {
+				r.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r, "clock")
+			if w.Body.String() != tt.expected {
+				t.Errorf("<response> body not equal. expected %q obtained %q\n", tt.expected, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSSEServerHeartbeat(t *testing.T) {
+	s := newSSEServer(&SSEOptions{HeartbeatInterval: 20 * time.Millisecond}, NopLogger(), nil)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.ServeHTTP(w, r, "clock")
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("subscribe error: %s", err)
+	}
+	defer resp.Body.Close()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+	if line != ": ping\n" {
+		t.Errorf("<response> line not equal. expected %q obtained %q\n", ": ping\n", line)
+	}
+
+	s.Shutdown()
+}
+
+func TestSSEServerDropOldest(t *testing.T) {
+	cl := &sseClient{send: make(chan *sseEvent, 2), done: make(chan struct{}), dropOldest: true}
+
+	for _, id := range []string{"1", "2", "3"} {
+		if !cl.push(newSSEEvent(id, id, ""), nil) {
+			t.Fatalf("<client> push %s failed\n", id)
+		}
+	}
+
+	for _, expected := range []string{"2", "3"} {
+		if e := <-cl.send; e.id != expected {
+			t.Errorf("<event> id not equal. expected %v obtained %v\n", expected, e.id)
+		}
+	}
+}
+
+func TestSSEServerMaxClients(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     *SSEOptions
+		channel  string
+		expected int
+	}{
+		{"global", &SSEOptions{MaxClients: 2}, "weather", http.StatusServiceUnavailable},
+		{"per channel", &SSEOptions{MaxClientsPerChannel: 2}, "clock", http.StatusServiceUnavailable},
+		{"other channel", &SSEOptions{MaxClientsPerChannel: 2}, "weather", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSSEServer(tt.opts, NopLogger(), nil)
+			s.AddChannel("clock")
+			for i := 0; i < 2; i++ {
+				s.channels["clock"][&sseClient{}] = struct{}{}
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx), tt.channel)
+			if w.Code != tt.expected {
+				t.Errorf("<response> code not equal. expected %v obtained %v\n", tt.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestSSEServerGracefulShutdown(t *testing.T) {
+	s := newSSEServer(&SSEOptions{RetryInterval: 5000}, NopLogger(), nil)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.ServeHTTP(w, r, "clock")
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("subscribe error: %s", err)
+	}
+	defer resp.Body.Close()
+
+	for s.ClientCount("clock") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	s.Shutdown()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+
+	expected := "retry: 5000\nevent: server-shutdown\ndata: {\"retry\":5000}\n\n"
+	if string(b) != expected {
+		t.Errorf("<response> body not equal. expected %q obtained %q\n", expected, string(b))
+	}
+}
+
+func TestSSEServerCompress(t *testing.T) {
+	s := newSSEServer(&SSEOptions{Compress: true}, NopLogger(), func(cl *sseClient) []*sseEvent {
+		return []*sseEvent{newSSEEvent("1", "hello", "")}
+	})
+	defer s.Shutdown()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.ServeHTTP(w, r, "clock")
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("subscribe error: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("<response> Content-Encoding not equal. expected %v obtained %v\n", "gzip", got)
+	}
+
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("gzip error: %s", err)
+	}
+
+	reader := bufio.NewReader(zr)
+	for _, expected := range []string{"id: 1\n", "data: hello\n"} {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read error: %s", err)
+		}
+		if line != expected {
+			t.Errorf("<response> line not equal. expected %q obtained %q\n", expected, line)
+		}
+	}
+}
+
+func TestSSEServerStats(t *testing.T) {
+	s := newSSEServer(&SSEOptions{}, NopLogger(), nil)
+	s.AddChannel("common")
+	s.channels["common"][&sseClient{
+		channel: "common",
+		r:       httptest.NewRequest(http.MethodGet, "/", nil),
+		send:    make(chan *sseEvent, 1),
+		done:    make(chan struct{}),
+	}] = struct{}{}
+
+	if stats := s.Stats("clock"); stats != nil {
+		t.Errorf("<stats> not nil before any event: %+v\n", stats)
+	}
+
+	for _, data := range []string{"ab", "abcd"} {
+		e := newSSEEvent(data, data, "")
+		e.topic = "clock"
+		s.SendMessage("common", e)
+	}
+
+	expected := SSEStats{Events: 2, DroppedEvents: 1, AvgPayloadSize: 3}
+	if stats := s.Stats("clock"); stats == nil || *stats != expected {
+		t.Errorf("<stats> not equal. expected %+v obtained %+v\n", expected, stats)
+	}
+}
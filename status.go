@@ -0,0 +1,127 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ResourceStatus describes the fetching state of a resource
+type ResourceStatus struct {
+	Alias       string    `json:"alias"`
+	URL         string    `json:"url,omitempty"`
+	Running     bool      `json:"running"`
+	Healthy     bool      `json:"healthy"`
+	Stale       bool      `json:"stale"`
+	Degraded    bool      `json:"degraded,omitempty"`
+	StatusCode  int       `json:"status_code"`
+	Hash        string    `json:"hash"`
+	ContentSize int       `json:"content_size"`
+	Failures    int       `json:"failures"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastFetch   time.Time `json:"last_fetch"`
+	ChangedAt   time.Time `json:"changed_at"`
+	NextFetch   time.Time `json:"next_fetch"`
+	Clients     int       `json:"clients,omitempty"`
+	Variants    int       `json:"variants,omitempty"`
+	SSE         *SSEStats `json:"sse,omitempty"`
+}
+
+// Status returns the fetching state of the resource
+func (r *Resource) Status() ResourceStatus {
+	s := r.Snapshot()
+
+	r.stateMu.Lock()
+	failures, lastError, unhealthy := r.failures, r.lastError, r.unhealthy
+	lastFetch, nextFetch := r.lastFetch, r.nextFetch
+	r.stateMu.Unlock()
+
+	status := ResourceStatus{
+		Alias:       r.Alias,
+		URL:         r.URL,
+		Running:     r.Running(),
+		Healthy:     s.Hash != "" && failures == 0,
+		Stale:       atomic.LoadInt32(&r.stale) == 1,
+		Degraded:    r.Degraded(),
+		StatusCode:  s.StatusCode,
+		Hash:        s.Hash,
+		ContentSize: len(s.Content),
+		Failures:    failures,
+		LastFetch:   lastFetch,
+		ChangedAt:   s.ChangedAt,
+	}
+
+	if r.parameterized() {
+		status.Healthy = true
+		status.Variants = r.variants.len()
+	}
+
+	if r.UnhealthyThreshold > 0 {
+		// Tolerate failures until the threshold is reached
+		status.Healthy = s.Hash != "" && !unhealthy
+	}
+
+	if status.Running {
+		status.NextFetch = nextFetch
+	}
+
+	if lastError != nil {
+		status.LastError = lastError.Error()
+	}
+
+	return status
+}
+
+// Degraded tells whether the last fetched content was rejected by the JSONSchema of the resource
+func (r *Resource) Degraded() bool {
+	return atomic.LoadInt32(&r.degraded) == 1
+}
+
+// Status returns the fetching state of all resources sorted by alias
+func (c *ResourceCacher) Status() []ResourceStatus {
+	resources := c.ListResources()
+
+	statuses := make([]ResourceStatus, len(resources))
+	for i, res := range resources {
+		statuses[i] = c.resourceStatus(res)
+	}
+
+	return statuses
+}
+
+// resourceStatus returns the status of a resource along with its connected clients
+func (c *ResourceCacher) resourceStatus(res *Resource) ResourceStatus {
+	status := res.Status()
+	if c.clientCount != nil {
+		status.Clients = c.clientCount(res.Alias)
+	}
+	if c.sseStats != nil {
+		status.SSE = c.sseStats(res.Alias)
+	}
+
+	return status
+}
+
+// StatusHandler returns a handler writing the status of all resources as JSON, e.g. to
+// mount on /resources/status. It answers 503 when any resource is unhealthy or has no
+// content yet, so it can be used as a load balancer health check. URLs and errors are
+// left out, as they may hold credentials or upstream details only the admin API reports.
+func (c *ResourceCacher) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		statuses := c.Status()
+
+		code := http.StatusOK
+		for i, status := range statuses {
+			if !status.Healthy {
+				code = http.StatusServiceUnavailable
+			}
+			statuses[i].URL, statuses[i].LastError = "", ""
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(statuses)
+	})
+}
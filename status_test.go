@@ -0,0 +1,121 @@
+package routing_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestStatusHandler(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	_, err := c.AddResource(&routing.Resource{
+		Alias:    "up",
+		Method:   http.MethodGet,
+		URL:      srv.URL + "/up",
+		Interval: time.Hour,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+	defer c.Stop()
+
+	w := httptest.NewRecorder()
+	c.StatusHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/resources/status", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("<response> status code not equal. expected %v obtained %v\n", http.StatusOK, w.Code)
+	}
+
+	var statuses []routing.ResourceStatus
+	if err := json.NewDecoder(w.Body).Decode(&statuses); err != nil {
+		t.Fatalf("decode status error: %s", err)
+	}
+
+	if len(statuses) != 1 {
+		t.Fatalf("<status> resources not equal. expected 1 obtained %d\n", len(statuses))
+	}
+
+	status := statuses[0]
+	if !status.Running || !status.Healthy || status.ContentSize != 16 || status.LastFetch.IsZero() || status.NextFetch.IsZero() {
+		t.Errorf("<status> unexpected status %+v\n", status)
+	}
+
+	if status.URL != "" {
+		t.Errorf("<status> URL exposed %v\n", status.URL)
+	}
+
+	_, err = c.AddResource(&routing.Resource{
+		Alias:    "down",
+		Method:   http.MethodGet,
+		URL:      srv.URL + "/down",
+		Interval: time.Hour,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	w = httptest.NewRecorder()
+	c.StatusHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/resources/status", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("<response> status code not equal. expected %v obtained %v\n", http.StatusServiceUnavailable, w.Code)
+	}
+
+	// Upstream errors are only reported by the admin API
+	if strings.Contains(w.Body.String(), "last_error") || strings.Contains(w.Body.String(), srv.URL) {
+		t.Errorf("<status> upstream details exposed %s\n", w.Body.String())
+	}
+}
+
+func TestStatusWhileFetching(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher()
+	res, err := c.AddResource(&routing.Resource{
+		Alias:    "flaky",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: 5 * time.Millisecond,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+	defer c.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			c.ForceRefresh("flaky")
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			if status := res.Status(); status.Failures == 0 || status.LastError == "" {
+				t.Errorf("<status> failures not reported: %+v\n", status)
+			}
+			return
+		default:
+			res.Status()
+		}
+	}
+}
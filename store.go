@@ -0,0 +1,273 @@
+package routing
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrCacheMiss is returned by a CacheStore when it has no entry for an alias
+var ErrCacheMiss = errors.New("cache miss")
+
+// CacheEntry represents the cached content of a resource
+type CacheEntry struct {
+	Content    []byte      `json:"content"`
+	Header     http.Header `json:"header"`
+	StatusCode int         `json:"status_code"`
+	Hash       string      `json:"hash"`
+	FetchedAt  time.Time   `json:"fetched_at,omitempty"`
+}
+
+// CacheStore stores the cached content of resources by alias
+type CacheStore interface {
+	Get(alias string) (*CacheEntry, error)
+	Set(alias string, entry *CacheEntry) error
+	Delete(alias string) error
+}
+
+// CacheStoreWatcher is implemented by the cache stores notifying the entries set by other
+// instances, for followers to serve the content fetched by the leader. An empty alias means
+// that any entry may have changed.
+type CacheStoreWatcher interface {
+	Watch(updated func(alias string))
+}
+
+// MemoryStore is the default in-memory CacheStore
+type MemoryStore struct {
+	entries  map[string]*CacheEntry
+	watchers []func(alias string)
+	mu       sync.RWMutex
+}
+
+// NewMemoryStore creates a new in-memory cache store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*CacheEntry)}
+}
+
+// Get returns the entry of an alias
+func (s *MemoryStore) Get(alias string) (*CacheEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.entries[alias]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+
+	return entry, nil
+}
+
+// Set stores the entry of an alias
+func (s *MemoryStore) Set(alias string, entry *CacheEntry) error {
+	s.mu.Lock()
+	s.entries[alias] = entry
+	watchers := s.watchers
+	s.mu.Unlock()
+
+	for _, updated := range watchers {
+		updated(alias)
+	}
+
+	return nil
+}
+
+// Watch implements CacheStoreWatcher, for the cachers sharing the store
+func (s *MemoryStore) Watch(updated func(alias string)) {
+	s.mu.Lock()
+	s.watchers = append(s.watchers, updated)
+	s.mu.Unlock()
+}
+
+// Delete removes the entry of an alias
+func (s *MemoryStore) Delete(alias string) error {
+	s.mu.Lock()
+	delete(s.entries, alias)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// storeEntry saves the freshly fetched content of a resource to the cache store
+func (c *ResourceCacher) storeEntry(res *Resource) {
+	if res.Hash == "" {
+		return
+	}
+
+	err := c.opts.Store.Set(res.Alias, &CacheEntry{
+		Content:    res.Content,
+		Header:     res.Header,
+		StatusCode: res.StatusCode,
+		Hash:       res.Hash,
+		FetchedAt:  res.fetchedAt,
+	})
+	if err != nil {
+		c.opts.Logger.Warn("cache store failed", F("alias", res.Alias), F("error", err))
+	}
+}
+
+// deleteEntry removes the content of a resource from the cache store
+func (c *ResourceCacher) deleteEntry(res *Resource) {
+	if err := c.opts.Store.Delete(res.Alias); err != nil {
+		c.opts.Logger.Warn("cache delete failed", F("alias", res.Alias), F("error", err))
+	}
+}
+
+// FileStore is a CacheStore persisting entries to disk, one JSON file per alias,
+// so that a restarted cacher can serve last known content right away
+type FileStore struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+// NewFileStore creates a new disk cache store in dir
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &FileStore{dir: dir}, nil
+}
+
+// Get returns the entry of an alias
+func (s *FileStore) Get(alias string) (*CacheEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, err := ioutil.ReadFile(s.path(alias))
+	if os.IsNotExist(err) {
+		return nil, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &CacheEntry{}
+	if err := json.Unmarshal(b, entry); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// Set stores the entry of an alias
+func (s *FileStore) Set(alias string, entry *CacheEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Write then rename so a crash never leaves a truncated entry
+	tmp := s.path(alias) + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path(alias))
+}
+
+// Delete removes the entry of an alias
+func (s *FileStore) Delete(alias string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.path(alias)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *FileStore) path(alias string) string {
+	return filepath.Join(s.dir, url.PathEscape(alias)+".json")
+}
+
+// warmStart loads the stored content of a resource not fetched yet
+func (c *ResourceCacher) warmStart(res *Resource) {
+	if res.Hash != "" {
+		return
+	}
+
+	entry, err := c.opts.Store.Get(res.Alias)
+	if err != nil {
+		return
+	}
+
+	res.adopt(entry)
+}
+
+// storeUpdated refreshes the resources from the entries set by other instances, typically
+// the leader when following it
+func (c *ResourceCacher) storeUpdated(alias string) {
+	if alias == "" {
+		for _, res := range c.ListResources() {
+			go c.follow(res)
+		}
+		return
+	}
+
+	if res, ok := c.GetResource(alias); ok {
+		go c.follow(res)
+	}
+}
+
+// follow loads the stored content of a resource when newer than its own, notifying the
+// clients like a fetch would
+func (c *ResourceCacher) follow(res *Resource) {
+	res.mu.Lock()
+	defer res.mu.Unlock()
+
+	entry, err := c.opts.Store.Get(res.Alias)
+	if err != nil {
+		if err != ErrCacheMiss {
+			c.opts.Logger.Warn("cache load failed", F("alias", res.Alias), F("error", err))
+		}
+		return
+	}
+
+	if res.adopt(entry) {
+		res.executeEvents(res.onFollowEvents)
+	}
+}
+
+// adopt loads a cache store entry newer than the content of the resource, reporting whether it did
+func (r *Resource) adopt(entry *CacheEntry) bool {
+	if entry.Hash == "" || entry.Hash == r.Hash || (!r.fetchedAt.IsZero() && !entry.FetchedAt.After(r.fetchedAt)) {
+		return false
+	}
+
+	fetchedAt := entry.FetchedAt
+	if fetchedAt.IsZero() {
+		fetchedAt = time.Now()
+	}
+
+	r.fetchedAt = fetchedAt
+	r.OldHash = r.Hash
+	r.Content = entry.Content
+	r.Header = entry.Header
+	r.StatusCode = entry.StatusCode
+	r.Hash = entry.Hash
+	r.ChangedAt = fetchedAt
+	r.LastModified = lastModified(entry.Header, time.Now())
+
+	r.publish()
+
+	// The stored content is as fresh as the fetch of the leader
+	r.stateMu.Lock()
+	r.lastFetch = fetchedAt
+	r.failures = 0
+	r.lastError = nil
+	r.stateMu.Unlock()
+	atomic.StoreInt32(&r.stale, 0)
+
+	return true
+}
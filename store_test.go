@@ -0,0 +1,175 @@
+package routing_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+type recordingStore struct {
+	*routing.MemoryStore
+	gets, sets, deletes int32
+}
+
+func (s *recordingStore) Get(alias string) (*routing.CacheEntry, error) {
+	atomic.AddInt32(&s.gets, 1)
+	return s.MemoryStore.Get(alias)
+}
+
+func (s *recordingStore) Set(alias string, entry *routing.CacheEntry) error {
+	atomic.AddInt32(&s.sets, 1)
+	return s.MemoryStore.Set(alias, entry)
+}
+
+func (s *recordingStore) Delete(alias string) error {
+	atomic.AddInt32(&s.deletes, 1)
+	return s.MemoryStore.Delete(alias)
+}
+
+func TestCacheStore(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	store := &recordingStore{MemoryStore: routing.NewMemoryStore()}
+	c := routing.NewResourceCacher(&routing.Options{Store: store})
+
+	if _, err := c.AddResource(&routing.Resource{
+		Alias:    "stored",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Hour,
+	}, nil); err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	entry, err := store.Get("stored")
+	if err != nil {
+		t.Fatalf("store get error: %s", err)
+	}
+
+	if atomic.LoadInt32(&store.sets) != 1 || string(entry.Content) != `{"status": "ok"}` || entry.StatusCode != http.StatusOK {
+		t.Errorf("<store> entry not equal. obtained %d sets %d %s\n", store.sets, entry.StatusCode, entry.Content)
+	}
+
+	serve := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?alias=stored", nil))
+		return w
+	}
+
+	// Content stored by someone else is ignored unless newer than the local one
+	store.Set("stored", &routing.CacheEntry{
+		Content:    []byte("outdated"),
+		Header:     http.Header{"Content-Type": []string{"text/plain"}},
+		StatusCode: http.StatusOK,
+		Hash:       "outdated-hash",
+		FetchedAt:  time.Now().Add(-time.Hour),
+	})
+	store.Set("stored", &routing.CacheEntry{
+		Content:    []byte("shared"),
+		Header:     http.Header{"Content-Type": []string{"text/plain"}},
+		StatusCode: http.StatusOK,
+		Hash:       "shared-hash",
+		FetchedAt:  time.Now(),
+	})
+
+	res, _ := c.GetResource("stored")
+	waitPublished(func() bool { return res.Snapshot().Hash == "shared-hash" })
+
+	gets := atomic.LoadInt32(&store.gets)
+	if w := serve(); w.Body.String() != "shared" || w.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("<response> store content not served. obtained %s %v\n", w.Body.String(), w.Header())
+	}
+
+	// Requests are served from the local snapshot only
+	if obtained := atomic.LoadInt32(&store.gets); obtained != gets {
+		t.Errorf("<store> gets while serving not equal. expected %v obtained %v\n", 0, obtained-gets)
+	}
+
+	if _, err := c.RemoveResource("stored"); err != nil {
+		t.Fatalf("remove resource error: %s", err)
+	}
+
+	if _, err := store.Get("stored"); err != routing.ErrCacheMiss || atomic.LoadInt32(&store.deletes) != 1 {
+		t.Errorf("<store> entry not deleted. obtained %v after %d deletes\n", err, atomic.LoadInt32(&store.deletes))
+	}
+}
+
+// countingFileStore counts the entries read from disk
+type countingFileStore struct {
+	*routing.FileStore
+	gets *int32
+}
+
+func (s *countingFileStore) Get(alias string) (*routing.CacheEntry, error) {
+	atomic.AddInt32(s.gets, 1)
+	return s.FileStore.Get(alias)
+}
+
+func TestFileStoreWarmStart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "routing")
+	if err != nil {
+		t.Fatalf("temp dir error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+
+	var reads int32
+	newCacher := func() *routing.ResourceCacher {
+		store, err := routing.NewFileStore(dir)
+		if err != nil {
+			t.Fatalf("file store error: %s", err)
+		}
+
+		c := routing.NewResourceCacher(&routing.Options{Store: &countingFileStore{FileStore: store, gets: &reads}})
+		if _, err := c.AddResource(&routing.Resource{
+			Alias:    "persisted resource",
+			Method:   http.MethodGet,
+			URL:      srv.URL,
+			Interval: time.Hour,
+		}, nil); err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+
+		return c
+	}
+
+	newCacher()
+
+	// Restart while upstream is down
+	srv.Close()
+	atomic.StoreInt32(&reads, 0)
+	c := newCacher()
+
+	var w *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		w = httptest.NewRecorder()
+		c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?alias=persisted+resource", nil))
+	}
+
+	// The file is only read at warm start
+	if obtained := atomic.LoadInt32(&reads); obtained != 1 {
+		t.Errorf("<store> file reads not equal. expected %v obtained %v\n", 1, obtained)
+	}
+
+	if w.Code != http.StatusOK || w.Body.String() != `{"status": "ok"}` {
+		t.Errorf("<response> persisted content not served. obtained %d %s\n", w.Code, w.Body.String())
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("<response> Content-Type not equal. expected application/json obtained %s\n", ct)
+	}
+}
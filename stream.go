@@ -0,0 +1,157 @@
+package routing
+
+//go:generate protoc --go_out=. --go_opt=module=go.lsl.digital/lardwaz/routing --go-grpc_out=. --go-grpc_opt=module=go.lsl.digital/lardwaz/routing resourcestream.proto
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// ResourceStream serves the content of resources to streaming consumers, such as the
+// ResourceStream gRPC service defined in resourcestream.proto (see resourcestreampb)
+type ResourceStream struct {
+	cacher *ResourceCacher
+	subs   map[*streamSubscription]struct{}
+	mu     sync.RWMutex
+}
+
+// streamSubscription holds the latest undelivered content of each alias of a subscriber,
+// so that slow consumers skip intermediate updates instead of blocking the cacher
+type streamSubscription struct {
+	aliases map[string]bool
+	pending map[string]*Snapshot
+	hashes  map[string]string
+	notify  chan struct{}
+	mu      sync.Mutex
+}
+
+// NewResourceStream returns a stream of the resources of a cacher, registered as its publisher
+func NewResourceStream(c *ResourceCacher) *ResourceStream {
+	s := &ResourceStream{cacher: c, subs: make(map[*streamSubscription]struct{})}
+	c.AddPublisher(s)
+
+	return s
+}
+
+// Get returns the current content of a resource
+func (s *ResourceStream) Get(alias string) (*Snapshot, error) {
+	res, ok := s.cacher.GetResource(alias)
+	if !ok {
+		return nil, ErrResourceNotFound
+	}
+
+	return res.Snapshot(), nil
+}
+
+// Subscribe calls send with the current content of the resources, all of them when aliases
+// is empty, then with their updates until ctx is done or send fails
+func (s *ResourceStream) Subscribe(ctx context.Context, aliases []string, send func(*Snapshot) error) error {
+	sub := &streamSubscription{
+		pending: make(map[string]*Snapshot),
+		hashes:  make(map[string]string),
+		notify:  make(chan struct{}, 1),
+	}
+
+	var initial []*Resource
+	if len(aliases) == 0 {
+		initial = s.cacher.ListResources()
+	} else {
+		sub.aliases = make(map[string]bool, len(aliases))
+		for _, alias := range aliases {
+			res, ok := s.cacher.GetResource(alias)
+			if !ok {
+				return ErrResourceNotFound
+			}
+			sub.aliases[alias] = true
+			initial = append(initial, res)
+		}
+	}
+
+	s.mu.Lock()
+	s.subs[sub] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.subs, sub)
+		s.mu.Unlock()
+	}()
+
+	for _, res := range initial {
+		if snapshot := res.Snapshot(); snapshot.Hash != "" {
+			sub.push(snapshot)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-sub.notify:
+		}
+
+		for _, snapshot := range sub.take() {
+			if err := send(snapshot); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Publish implements Publisher
+func (s *ResourceStream) Publish(event Event) error {
+	if event.Type != EventUpdated || !event.Changed {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for sub := range s.subs {
+		if sub.aliases == nil || sub.aliases[event.Snapshot.Alias] {
+			sub.push(event.Snapshot)
+		}
+	}
+
+	return nil
+}
+
+// push replaces the pending content of an alias and wakes the subscriber up, content already
+// pushed being skipped
+func (sub *streamSubscription) push(snapshot *Snapshot) {
+	sub.mu.Lock()
+	if sub.hashes[snapshot.Alias] == snapshot.Hash {
+		sub.mu.Unlock()
+		return
+	}
+	sub.hashes[snapshot.Alias] = snapshot.Hash
+	sub.pending[snapshot.Alias] = snapshot
+	sub.mu.Unlock()
+
+	select {
+	case sub.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the pending contents sorted by alias, clearing them
+func (sub *streamSubscription) take() []*Snapshot {
+	sub.mu.Lock()
+	pending := sub.pending
+	sub.pending = make(map[string]*Snapshot)
+	sub.mu.Unlock()
+
+	aliases := make([]string, 0, len(pending))
+	for alias := range pending {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	snapshots := make([]*Snapshot, len(aliases))
+	for i, alias := range aliases {
+		snapshots[i] = pending[alias]
+	}
+
+	return snapshots
+}
@@ -0,0 +1,95 @@
+package routing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestResourceStream(t *testing.T) {
+	var version int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&version) == 0 {
+			w.Write([]byte(r.URL.Path + " v0"))
+		} else {
+			w.Write([]byte(r.URL.Path + " v1"))
+		}
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	stream := routing.NewResourceStream(c)
+
+	for _, alias := range []string{"a", "b"} {
+		if _, err := c.AddResource(&routing.Resource{
+			Alias:    alias,
+			Method:   http.MethodGet,
+			URL:      srv.URL + "/" + alias,
+			Interval: time.Hour,
+		}, nil); err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+	}
+
+	if s, err := stream.Get("a"); err != nil || string(s.Content) != "/a v0" {
+		t.Errorf("<stream> get not equal. expected %v obtained %v (%v)\n", "/a v0", s, err)
+	}
+
+	if _, err := stream.Get("missing"); !errors.Is(err, routing.ErrResourceNotFound) {
+		t.Errorf("<stream> get error not equal. expected %v obtained %v\n", routing.ErrResourceNotFound, err)
+	}
+
+	if err := stream.Subscribe(context.Background(), []string{"missing"}, nil); !errors.Is(err, routing.ErrResourceNotFound) {
+		t.Errorf("<stream> subscribe error not equal. expected %v obtained %v\n", routing.ErrResourceNotFound, err)
+	}
+
+	received := make(chan string, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- stream.Subscribe(ctx, []string{"b"}, func(s *routing.Snapshot) error {
+			received <- string(s.Content)
+			return nil
+		})
+	}()
+
+	expect := func(expected string) {
+		select {
+		case content := <-received:
+			if content != expected {
+				t.Errorf("<stream> content not equal. expected %v obtained %v\n", expected, content)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("<stream> %v not received\n", expected)
+		}
+	}
+
+	expect("/b v0")
+
+	atomic.StoreInt32(&version, 1)
+	for _, alias := range []string{"a", "b"} {
+		if err := c.ForceRefresh(alias); err != nil {
+			t.Fatalf("refresh error: %s", err)
+		}
+	}
+
+	// Updates of a are filtered out
+	expect("/b v1")
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("<stream> subscribe error not equal. expected %v obtained %v\n", context.Canceled, err)
+	}
+
+	select {
+	case content := <-received:
+		t.Errorf("<stream> unexpected content %v\n", content)
+	default:
+	}
+}
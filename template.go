@@ -0,0 +1,93 @@
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	texttemplate "text/template"
+)
+
+func init() {
+	RegisterTransformer("template", func(config json.RawMessage) (Transformer, error) {
+		var def struct {
+			Template string `json:"template"`
+			File     string `json:"file"`
+			Text     bool   `json:"text"`
+		}
+		if err := json.Unmarshal(config, &def); err != nil {
+			return Transformer{}, err
+		}
+
+		src := def.Template
+		if def.File != "" {
+			b, err := ioutil.ReadFile(def.File)
+			if err != nil {
+				return Transformer{}, err
+			}
+			src = string(b)
+		}
+
+		if src == "" {
+			return Transformer{}, errors.New("missing template")
+		}
+
+		if def.Text {
+			tmpl, err := texttemplate.New("resource").Parse(src)
+			if err != nil {
+				return Transformer{}, err
+			}
+			return TextTemplate(tmpl), nil
+		}
+
+		tmpl, err := htmltemplate.New("resource").Parse(src)
+		if err != nil {
+			return Transformer{}, err
+		}
+		return HTMLTemplate(tmpl), nil
+	})
+}
+
+// templateExecutor is implemented by text and html templates
+type templateExecutor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// renderTemplate renders decoded JSON content through a template
+func renderTemplate(name string, tmpl templateExecutor, contentType string) Transformer {
+	return Transformer{
+		Name: name,
+		Transform: func(content []byte, header http.Header) ([]byte, http.Header, error) {
+			data, err := decodeJSON(content)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, data); err != nil {
+				return nil, nil, err
+			}
+
+			if header == nil {
+				header = make(http.Header)
+			}
+			header.Set("Content-Type", contentType)
+
+			return buf.Bytes(), header, nil
+		},
+	}
+}
+
+// HTMLTemplate renders JSON content through an HTML template, e.g. to serve pre-rendered
+// widgets, values being escaped according to their context
+func HTMLTemplate(tmpl *htmltemplate.Template) Transformer {
+	return renderTemplate("html_template", tmpl, "text/html; charset=utf-8")
+}
+
+// TextTemplate renders JSON content through a text template
+func TextTemplate(tmpl *texttemplate.Template) Transformer {
+	return renderTemplate("text_template", tmpl, "text/plain; charset=utf-8")
+}
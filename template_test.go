@@ -0,0 +1,63 @@
+package routing_test
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"testing"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestTemplateTransformers(t *testing.T) {
+	content := []byte(`{"symbol": "<ACME>", "price": 12.50, "changes": [1, -2]}`)
+
+	tests := []struct {
+		name        string
+		transformer func(t *testing.T) routing.Transformer
+		expected    string
+		contentType string
+	}{
+		{
+			name: "html",
+			transformer: func(t *testing.T) routing.Transformer {
+				return routing.HTMLTemplate(template.Must(template.New("ticker").Parse(`<b>{{.symbol}}</b> {{.price}}{{range .changes}} {{.}}{{end}}`)))
+			},
+			expected:    `<b>&lt;ACME&gt;</b> 12.50 1 -2`,
+			contentType: "text/html; charset=utf-8",
+		},
+		{
+			name: "text config",
+			transformer: func(t *testing.T) routing.Transformer {
+				tr, err := routing.NewTransformer(json.RawMessage(`{"type": "template", "text": true, "template": "{{.symbol}}={{.price}}"}`))
+				if err != nil {
+					t.Fatalf("new transformer error: %s", err)
+				}
+				return tr
+			},
+			expected:    `<ACME>=12.50`,
+			contentType: "text/plain; charset=utf-8",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, header, err := tt.transformer(t).Transform(content, http.Header{"Content-Type": {"application/json"}})
+			if err != nil {
+				t.Fatalf("transform error: %s", err)
+			}
+
+			if string(b) != tt.expected {
+				t.Errorf("<template> content not equal. expected %s obtained %s\n", tt.expected, b)
+			}
+
+			if header.Get("Content-Type") != tt.contentType {
+				t.Errorf("<template> Content-Type not equal. expected %v obtained %v\n", tt.contentType, header.Get("Content-Type"))
+			}
+		})
+	}
+
+	if _, err := routing.NewTransformer(json.RawMessage(`{"type": "template", "template": "{{.unclosed"}`)); err == nil {
+		t.Errorf("<template> expected invalid template to be rejected\n")
+	}
+}
@@ -0,0 +1,58 @@
+package routing
+
+import (
+	"context"
+	"net/http"
+)
+
+// Tracer starts tracing spans and propagates the trace context to upstream requests.
+// It is a small subset of OpenTelemetry, an adapter being a few lines, e.g.
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, routing.Span) {
+//		ctx, span := t.tracer.Start(ctx, name)
+//		return ctx, otelSpan{span}
+//	}
+//
+//	func (t otelTracer) Inject(ctx context.Context, header http.Header) {
+//		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+//	}
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+	Inject(ctx context.Context, header http.Header)
+}
+
+// Span represents a traced operation
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// serveTraced serves a request within a span recording the alias, cache and response status
+func (c *ResourceCacher) serveTraced(w http.ResponseWriter, r *http.Request) {
+	ctx, span := c.opts.Tracer.Start(r.Context(), "routing.serve")
+	defer span.End()
+
+	if alias, err := c.aliasFromRequest(r); err == nil {
+		span.SetAttribute("routing.alias", alias)
+
+		if res, ok := c.GetResource(alias); ok {
+			span.SetAttribute("routing.cache", res.cacheStatus(res.Snapshot()))
+		}
+	}
+
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	c.serveHTTP(sw, r.WithContext(ctx))
+
+	span.SetAttribute("http.status_code", sw.status)
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,95 @@
+package routing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordingSpan
+}
+
+type recordingSpan struct {
+	name  string
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string) (context.Context, routing.Span) {
+	span := &recordingSpan{name: name, attrs: make(map[string]interface{})}
+
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+
+	return ctx, span
+}
+
+func (t *recordingTracer) Inject(ctx context.Context, header http.Header) {
+	header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+}
+
+func (s *recordingSpan) SetAttribute(key string, value interface{}) {
+	s.attrs[key] = value
+}
+
+func (s *recordingSpan) RecordError(err error) {
+	s.err = err
+}
+
+func (s *recordingSpan) End() {
+	s.ended = true
+}
+
+func TestTracing(t *testing.T) {
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	tracer := &recordingTracer{}
+	c := routing.NewResourceCacher(&routing.Options{Tracer: tracer})
+	_, err := c.AddResource(&routing.Resource{
+		Alias:    "traced",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Hour,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+	defer c.Stop()
+
+	if traceparent == "" {
+		t.Errorf("<tracing> expected trace context propagated upstream\n")
+	}
+
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?alias=traced", nil))
+
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("<tracing> spans not equal. expected 2 obtained %d\n", len(tracer.spans))
+	}
+
+	fetch, serve := tracer.spans[0], tracer.spans[1]
+	if fetch.name != "routing.fetch" || fetch.attrs["routing.alias"] != "traced" || fetch.attrs["http.status_code"] != http.StatusOK || !fetch.ended {
+		t.Errorf("<tracing> unexpected fetch span %+v\n", fetch)
+	}
+
+	if serve.name != "routing.serve" || serve.attrs["routing.cache"] != "HIT" || serve.attrs["http.status_code"] != http.StatusOK || !serve.ended {
+		t.Errorf("<tracing> unexpected serve span %+v\n", serve)
+	}
+}
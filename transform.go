@@ -0,0 +1,143 @@
+package routing
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Transformer rewrites fetched content and headers before they are cached
+type Transformer struct {
+	// Name identifies the transformer in errors
+	Name string
+	// Transform returns the new content and header, an error aborting the update
+	Transform func(content []byte, header http.Header) ([]byte, http.Header, error)
+
+	// config is the definition the transformer was built from, if any
+	config json.RawMessage
+}
+
+// TransformerFactory builds a transformer from its JSON definition
+type TransformerFactory func(config json.RawMessage) (Transformer, error)
+
+var (
+	transformerFactories   = make(map[string]TransformerFactory)
+	transformerFactoriesMu sync.RWMutex
+)
+
+// RegisterTransformer makes a transformer type available in resource definitions as
+// {"type": kind, ...}, the whole definition being given to the factory
+func RegisterTransformer(kind string, factory TransformerFactory) {
+	transformerFactoriesMu.Lock()
+	transformerFactories[kind] = factory
+	transformerFactoriesMu.Unlock()
+}
+
+// NewTransformer builds a transformer from a JSON definition like {"type": "jsonpath", "path": "$.data"}
+func NewTransformer(config json.RawMessage) (Transformer, error) {
+	var def struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(config, &def); err != nil {
+		return Transformer{}, err
+	}
+
+	transformerFactoriesMu.RLock()
+	factory, ok := transformerFactories[def.Type]
+	transformerFactoriesMu.RUnlock()
+
+	if !ok {
+		return Transformer{}, fmt.Errorf("unknown transformer type %q", def.Type)
+	}
+
+	t, err := factory(config)
+	if err != nil {
+		return Transformer{}, fmt.Errorf("transformer %s: %w", def.Type, err)
+	}
+
+	if t.Name == "" {
+		t.Name = def.Type
+	}
+	t.config = config
+
+	return t, nil
+}
+
+// transform runs the content through the Transformers of the resource, in order
+func (r *Resource) transform(content []byte, header http.Header) ([]byte, http.Header, error) {
+	for i, t := range r.Transformers {
+		if t.Transform == nil {
+			continue
+		}
+
+		var err error
+		content, header, err = t.Transform(content, header)
+		if err != nil {
+			name := t.Name
+			if name == "" {
+				name = strconv.Itoa(i)
+			}
+			return nil, nil, fmt.Errorf("transformer %s: %w", name, err)
+		}
+
+		if header == nil {
+			header = make(http.Header)
+		}
+	}
+
+	if len(r.Transformers) > 0 {
+		header.Set("Content-Length", strconv.Itoa(len(content)))
+	}
+
+	return content, header, nil
+}
+
+// transformerConfigs returns the definitions of the transformers built by NewTransformer
+func transformerConfigs(transformers []Transformer) []json.RawMessage {
+	var configs []json.RawMessage
+	for _, t := range transformers {
+		if t.config != nil {
+			configs = append(configs, t.config)
+		}
+	}
+
+	return configs
+}
+
+// newTransformers builds transformers from their definitions
+func newTransformers(configs []json.RawMessage) ([]Transformer, error) {
+	var transformers []Transformer
+	for _, config := range configs {
+		t, err := NewTransformer(config)
+		if err != nil {
+			return nil, err
+		}
+		transformers = append(transformers, t)
+	}
+
+	return transformers, nil
+}
+
+// SetContent replaces the cached content, recomputing its Hash, Etag and Content-Length,
+// e.g. from an update event
+func (r *Resource) SetContent(content []byte) {
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+
+	hash := fmt.Sprintf("%x", sha1.Sum(content))
+	if hash != r.Hash {
+		r.ChangedAt = time.Now()
+	}
+
+	r.Content = content
+	r.Hash = hash
+	r.Header.Set("Etag", r.Hash)
+	r.Header.Set("Content-Length", strconv.Itoa(len(content)))
+
+	r.publish()
+}
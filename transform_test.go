@@ -0,0 +1,98 @@
+package routing_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestTransformers(t *testing.T) {
+	var broken int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&broken) == 1 {
+			w.Write([]byte(`broken`))
+			return
+		}
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer srv.Close()
+
+	var errs []error
+	c := routing.NewResourceCacher(nil)
+	c.OnResourceError = func(res *routing.Resource, err error) {
+		errs = append(errs, err)
+	}
+
+	errBroken := errors.New("broken content")
+	res, err := c.AddResource(&routing.Resource{
+		Alias:    "transformed",
+		Method:   http.MethodGet,
+		URL:      srv.URL,
+		Interval: time.Hour,
+		Transformers: []routing.Transformer{
+			{
+				Name: "validate",
+				Transform: func(content []byte, header http.Header) ([]byte, http.Header, error) {
+					if !bytes.HasPrefix(content, []byte("{")) {
+						return nil, nil, errBroken
+					}
+					return content, header, nil
+				},
+			},
+			{
+				Name: "upper",
+				Transform: func(content []byte, header http.Header) ([]byte, http.Header, error) {
+					return bytes.ToUpper(content), header, nil
+				},
+			},
+			{
+				Name: "wrap",
+				Transform: func(content []byte, header http.Header) ([]byte, http.Header, error) {
+					header.Set("X-Transformed", "true")
+					return append(append([]byte(`{"data": `), content...), '}'), header, nil
+				},
+			},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	expected := []byte(`{"data": {"STATUS": "OK"}}`)
+	expectedHash := fmt.Sprintf("%x", sha1.Sum(expected))
+
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/resources/transformed", nil))
+
+	if !bytes.Equal(w.Body.Bytes(), expected) {
+		t.Errorf("<response> content not equal. expected %s obtained %s\n", expected, w.Body.Bytes())
+	}
+
+	for k, v := range map[string]string{"Etag": expectedHash, "Content-Length": fmt.Sprint(len(expected)), "X-Transformed": "true"} {
+		if obtained := w.Header().Get(k); obtained != v {
+			t.Errorf("<response> %s not equal. expected %v obtained %v\n", k, v, obtained)
+		}
+	}
+
+	// A failing transformer keeps the previous content
+	atomic.StoreInt32(&broken, 1)
+	if err := c.ForceRefresh("transformed"); !errors.Is(err, errBroken) {
+		t.Errorf("<refresh> error not equal. expected %v obtained %v\n", errBroken, err)
+	}
+
+	if res.Hash != expectedHash {
+		t.Errorf("<resource> hash not equal. expected %v obtained %v\n", expectedHash, res.Hash)
+	}
+
+	if len(errs) != 1 {
+		t.Errorf("<resource> errors not equal. expected 1 obtained %v\n", errs)
+	}
+}
@@ -0,0 +1,274 @@
+package routing
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultMaxVariants = 100
+
+var urlParamPattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// urlParams returns the names of the {param} placeholders of a URL template
+func urlParams(template string) []string {
+	var params []string
+	for _, m := range urlParamPattern.FindAllStringSubmatch(template, -1) {
+		params = append(params, m[1])
+	}
+
+	return params
+}
+
+// expandURL replaces the placeholders of a URL template, escaping values for the path or query
+func expandURL(template string, values map[string]string) string {
+	query := strings.IndexByte(template, '?')
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range urlParamPattern.FindAllStringSubmatchIndex(template, -1) {
+		b.WriteString(template[last:loc[0]])
+
+		value := values[template[loc[2]:loc[3]]]
+		if query >= 0 && loc[0] > query {
+			b.WriteString(url.QueryEscape(value))
+		} else {
+			b.WriteString(url.PathEscape(value))
+		}
+
+		last = loc[1]
+	}
+	b.WriteString(template[last:])
+
+	return b.String()
+}
+
+// variantEntry is a cached variant of a parameterized resource
+type variantEntry struct {
+	key     string
+	res     *Resource
+	mu      sync.Mutex
+	fetched time.Time
+}
+
+// variantCache is an LRU of the variants of a parameterized resource
+type variantCache struct {
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+// get returns the entry of a key, creating it and evicting the least recently used ones as needed
+func (vc *variantCache) get(key string, size int, create func() *Resource) *variantEntry {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	if vc.items == nil {
+		vc.ll, vc.items = list.New(), make(map[string]*list.Element)
+	}
+
+	if el, ok := vc.items[key]; ok {
+		vc.ll.MoveToFront(el)
+		return el.Value.(*variantEntry)
+	}
+
+	e := &variantEntry{key: key, res: create()}
+	vc.items[key] = vc.ll.PushFront(e)
+
+	for vc.ll.Len() > size {
+		oldest := vc.ll.Back()
+		vc.ll.Remove(oldest)
+		delete(vc.items, oldest.Value.(*variantEntry).key)
+	}
+
+	return e
+}
+
+// len returns the number of cached variants
+func (vc *variantCache) len() int {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	if vc.ll == nil {
+		return 0
+	}
+
+	return vc.ll.Len()
+}
+
+// clear drops all variants
+func (vc *variantCache) clear() {
+	vc.mu.Lock()
+	vc.ll, vc.items = nil, nil
+	vc.mu.Unlock()
+}
+
+// parameterized tells whether the resource is fetched per variant of the client request,
+// its URL being a template filled from the request query or its content localized
+func (r *Resource) parameterized() bool {
+	return len(r.params) > 0 || len(r.Languages) > 0
+}
+
+// localize makes the variant fetch the content in lang, forwarding it as Accept-Language
+// and answering it as Content-Language unless upstream did
+func (r *Resource) localize(lang string) {
+	header := make(http.Header, len(r.RequestHeaders)+1)
+	for k, v := range r.RequestHeaders {
+		header[k] = v
+	}
+	header.Set("Accept-Language", lang)
+	r.RequestHeaders = header
+
+	r.Transformers = append(append([]Transformer(nil), r.Transformers...), Transformer{
+		Name: "content_language",
+		Transform: func(content []byte, header http.Header) ([]byte, http.Header, error) {
+			if header.Get("Content-Language") == "" {
+				header.Set("Content-Language", lang)
+			}
+			return content, header, nil
+		},
+	})
+}
+
+// negotiateLanguage returns the supported language preferred by an Accept-Language header,
+// the first one when none matches
+func negotiateLanguage(accept string, languages []string) string {
+	best, bestQ := languages[0], 0.0
+	if accept == "" {
+		return best
+	}
+
+	var ranges []string
+	var qvalues []float64
+	for _, part := range strings.Split(accept, ",") {
+		name, q := parseQValue(part)
+		if name != "" {
+			ranges, qvalues = append(ranges, name), append(qvalues, q)
+		}
+	}
+
+	for _, lang := range languages {
+		// The most specific matching range gives the quality
+		q, specificity := 0.0, -1
+		for j, rng := range ranges {
+			if s := matchLanguageRange(rng, strings.ToLower(lang)); s > specificity {
+				q, specificity = qvalues[j], s
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+
+	return best
+}
+
+// matchLanguageRange returns how specifically a range like "en-us" matches a language, -1 if not,
+// "en" and "en-us" matching each other as a prefix
+func matchLanguageRange(rng, lang string) int {
+	switch {
+	case rng == lang:
+		return 2
+	case strings.HasPrefix(lang, rng+"-"), strings.HasPrefix(rng, lang+"-"):
+		return 1
+	case rng == "*":
+		return 0
+	}
+
+	return -1
+}
+
+// newVariant returns a resource fetching the URL of a variant with the upstream settings of r
+func (r *Resource) newVariant(rawURL string) *Resource {
+	return &Resource{
+		Alias:               r.Alias,
+		Method:              r.Method,
+		URL:                 rawURL,
+		Interval:            r.Interval,
+		Timeout:             r.Timeout,
+		RequestHeaders:      r.RequestHeaders,
+		HTTPClient:          r.HTTPClient,
+		Transport:           r.Transport,
+		TLSConfig:           r.TLSConfig,
+		Auth:                r.Auth,
+		Body:                r.Body,
+		BodyFunc:            r.BodyFunc,
+		ContentType:         r.ContentType,
+		MaxContentSize:      r.MaxContentSize,
+		RequestInterceptors: r.RequestInterceptors,
+		JSONSchema:          r.JSONSchema,
+		Transformers:        r.Transformers,
+		CachePolicy:         r.CachePolicy,
+
+		tracer:          r.tracer,
+		fetchSem:        r.fetchSem,
+		prepareSnapshot: r.prepareSnapshot,
+	}
+}
+
+// variantSnapshot returns the snapshot of the variant matching the request query, fetching it
+// when missing or older than VariantTTL. Stale content is served when fetching fails.
+func (c *ResourceCacher) variantSnapshot(ctx context.Context, r *http.Request, res *Resource) (*Snapshot, int) {
+	query := r.URL.Query()
+
+	values := make(map[string]string, len(res.params))
+	var key strings.Builder
+	for _, param := range res.params {
+		value, ok := query[param]
+		if !ok {
+			return nil, http.StatusBadRequest
+		}
+
+		values[param] = value[0]
+		key.WriteString(url.QueryEscape(value[0]) + "&")
+	}
+
+	var lang string
+	if len(res.Languages) != 0 {
+		lang = negotiateLanguage(r.Header.Get("Accept-Language"), res.Languages)
+		values["lang"] = lang
+		key.WriteString("lang=" + lang)
+	}
+
+	size := res.MaxVariants
+	if size <= 0 {
+		size = defaultMaxVariants
+	}
+
+	ttl := res.VariantTTL
+	if ttl <= 0 {
+		ttl = res.Interval
+	}
+
+	e := res.variants.get(key.String(), size, func() *Resource {
+		v := res.newVariant(expandURL(res.URL, values))
+		if lang != "" {
+			v.localize(lang)
+		}
+		return v
+	})
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if time.Since(e.fetched) > ttl {
+		if err := e.res.FetchContext(ctx); err != nil {
+			c.opts.Logger.Warn("variant fetch failed", F("alias", res.Alias), F("url", e.res.URL), F("error", err))
+		} else {
+			e.fetched = time.Now()
+		}
+	}
+
+	s := e.res.Snapshot()
+	if s.Hash == "" {
+		return nil, http.StatusBadGateway
+	}
+
+	return s, http.StatusOK
+}
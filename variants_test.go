@@ -0,0 +1,147 @@
+package routing_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestParameterizedResource(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte(r.URL.Path + " " + r.URL.Query().Get("id")))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	res, err := c.AddResource(&routing.Resource{
+		Alias:       "item",
+		Method:      http.MethodGet,
+		URL:         srv.URL + "/items/{kind}?id={id}",
+		Interval:    time.Hour,
+		MaxVariants: 2,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	get := func(query string) (int, string) {
+		rec := httptest.NewRecorder()
+		c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?alias=item&"+query, nil))
+		body, _ := ioutil.ReadAll(rec.Body)
+		return rec.Code, string(body)
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		body   string
+		hits   int32
+	}{
+		{"first", "kind=a&id=1", http.StatusOK, "/items/a 1", 1},
+		{"cached", "kind=a&id=1", http.StatusOK, "/items/a 1", 1},
+		{"escaped", "kind=a%2Fb&id=x%26y", http.StatusOK, "/items/a/b x&y", 2},
+		{"other", "kind=b&id=2", http.StatusOK, "/items/b 2", 3},
+		{"evicted", "kind=a&id=1", http.StatusOK, "/items/a 1", 4},
+		{"missing param", "kind=a", http.StatusBadRequest, "", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := get(tt.query)
+			if status != tt.status {
+				t.Errorf("<response> status not equal. expected %v obtained %v\n", tt.status, status)
+			}
+
+			if tt.status == http.StatusOK && body != tt.body {
+				t.Errorf("<response> body not equal. expected %v obtained %v\n", tt.body, body)
+			}
+
+			if h := atomic.LoadInt32(&hits); h != tt.hits {
+				t.Errorf("<upstream> hits not equal. expected %v obtained %v\n", tt.hits, h)
+			}
+		})
+	}
+
+	if status := res.Status(); !status.Healthy || status.Variants != 2 {
+		t.Errorf("<status> not equal. expected healthy with %v variants obtained %+v\n", 2, status)
+	}
+
+	if err := c.ForceRefresh("item"); err != nil {
+		t.Fatalf("refresh error: %s", err)
+	}
+
+	if status := res.Status(); status.Variants != 0 {
+		t.Errorf("<status> variants not equal. expected %v obtained %v\n", 0, status.Variants)
+	}
+}
+
+func TestLocalizedResource(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte(r.URL.Path + " " + r.Header.Get("Accept-Language")))
+	}))
+	defer srv.Close()
+
+	c := routing.NewResourceCacher(nil)
+	if _, err := c.AddResource(&routing.Resource{
+		Alias:     "news",
+		Method:    http.MethodGet,
+		URL:       srv.URL + "/news/{lang}",
+		Interval:  time.Hour,
+		Languages: []string{"en", "fr", "pt-BR"},
+	}, nil); err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	tests := []struct {
+		name   string
+		accept string
+		lang   string
+		hits   int32
+	}{
+		{"default", "", "en", 1},
+		{"exact", "fr", "fr", 2},
+		{"cached", "fr-CA, en;q=0.5", "fr", 2},
+		{"quality", "en;q=0.2, pt;q=0.8", "pt-BR", 3},
+		{"unsupported", "de", "en", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?alias=news", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept-Language", tt.accept)
+			}
+
+			rec := httptest.NewRecorder()
+			c.ServeHTTP(rec, req)
+
+			body, _ := ioutil.ReadAll(rec.Body)
+			if expected := "/news/" + tt.lang + " " + tt.lang; string(body) != expected {
+				t.Errorf("<response> body not equal. expected %v obtained %v\n", expected, string(body))
+			}
+
+			if lang := rec.Header().Get("Content-Language"); lang != tt.lang {
+				t.Errorf("<response> Content-Language not equal. expected %v obtained %v\n", tt.lang, lang)
+			}
+
+			if vary := strings.Join(rec.Header()["Vary"], ", "); !strings.Contains(vary, "Accept-Language") {
+				t.Errorf("<response> Vary does not contain Accept-Language. obtained %v\n", vary)
+			}
+
+			if h := atomic.LoadInt32(&hits); h != tt.hits {
+				t.Errorf("<upstream> hits not equal. expected %v obtained %v\n", tt.hits, h)
+			}
+		})
+	}
+}
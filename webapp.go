@@ -2,6 +2,7 @@ package routing //import "go.lsl.digital/lardwaz/routing"
 
 import (
 	"net/http"
+	"runtime/debug"
 )
 
 // ErrorHandler defines a custom error handler
@@ -58,3 +59,29 @@ func WrapWithFallback(handler http.Handler, fallback []byte, headers map[string]
 		w.Write(fallback)
 	}))
 }
+
+// WrapWithRecovery wraps an http.Handler function in order to recover from panics and respond with a 500
+func WrapWithRecovery(next http.Handler, logger Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+
+			// Let net/http abort the response as intended
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+
+			if logger != nil {
+				logger.Error("panic serving request", F("method", r.Method), F("path", r.URL.Path),
+					F("panic", err), F("stack", string(debug.Stack())))
+			}
+
+			w.WriteHeader(http.StatusInternalServerError)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
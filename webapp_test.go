@@ -0,0 +1,88 @@
+package routing_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+type testLogger struct {
+	entries []string
+}
+
+func (l *testLogger) Debug(msg string, fields ...routing.Field) {}
+func (l *testLogger) Info(msg string, fields ...routing.Field)  {}
+func (l *testLogger) Warn(msg string, fields ...routing.Field)  {}
+
+func (l *testLogger) Error(msg string, fields ...routing.Field) {
+	l.entries = append(l.entries, fmt.Sprint(msg, fields))
+}
+
+func TestWrapWithRecovery(t *testing.T) {
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	tests := []struct {
+		name       string
+		handler    func(logger routing.Logger) http.Handler
+		content    string
+		statusCode int
+	}{
+		{
+			name: "recovery only",
+			handler: func(logger routing.Logger) http.Handler {
+				return routing.WrapWithRecovery(panicking, logger)
+			},
+			content:    "",
+			statusCode: http.StatusInternalServerError,
+		},
+		{
+			name: "recovery with fallback",
+			handler: func(logger routing.Logger) http.Handler {
+				return routing.WrapWithFallback(routing.WrapWithRecovery(panicking, logger), []byte("fallback"), nil)
+			},
+			content:    "fallback",
+			statusCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &testLogger{}
+
+			req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+			w := httptest.NewRecorder()
+			tt.handler(logger).ServeHTTP(w, req)
+			r := w.Result()
+
+			b, err := ioutil.ReadAll(r.Body)
+			defer r.Body.Close()
+			if err != nil {
+				t.Errorf("read error: %s", err)
+				return
+			}
+
+			if tt.statusCode != r.StatusCode {
+				t.Errorf("<response> statusCode not equal. expected %v obtained %v\n", tt.statusCode, r.StatusCode)
+			}
+
+			if tt.content != string(b) {
+				t.Errorf("<response> content not equal. expected %s obtained %s\n", tt.content, b)
+			}
+
+			if len(logger.entries) != 1 {
+				t.Fatalf("<logger> expected 1 entry obtained %d\n", len(logger.entries))
+			}
+
+			if !strings.Contains(logger.entries[0], "boom") || !strings.Contains(logger.entries[0], "goroutine") {
+				t.Errorf("<logger> expected panic value and stack obtained %s\n", logger.entries[0])
+			}
+		})
+	}
+}
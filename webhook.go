@@ -0,0 +1,175 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WebhookSignatureHeader carries the "sha256=<hex>" HMAC-SHA256 of a webhook body
+const WebhookSignatureHeader = "X-Webhook-Signature"
+
+const (
+	defaultWebhookRetries = 3
+	defaultWebhookBackoff = time.Second
+)
+
+// WebhookEvent is the JSON body posted by a WebhookNotifier when a resource changes
+type WebhookEvent struct {
+	Alias     string    `json:"alias"`
+	Hash      string    `json:"hash"`
+	OldHash   string    `json:"old_hash,omitempty"`
+	Size      int       `json:"size"`
+	Timestamp time.Time `json:"timestamp"`
+	Payload   string    `json:"payload,omitempty"`
+}
+
+// WebhookNotifier posts a WebhookEvent to URLs whenever the content of a resource changes
+type WebhookNotifier struct {
+	URLs []string
+	// Secret signs the body in the X-Webhook-Signature header (nil = unsigned)
+	Secret []byte
+	// IncludePayload adds the content of the resource to the event
+	IncludePayload bool
+	// MaxRetries of a failed delivery (defaults to 3, negative = no retry)
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, doubled on each attempt (defaults to 1s)
+	RetryBackoff time.Duration
+	// Header is sent with each request
+	Header http.Header
+	// Client sends the requests (defaults to http.DefaultClient)
+	Client *http.Client
+}
+
+// Notify delivers an event to every URL, returning the errors of failed deliveries
+func (n *WebhookNotifier) Notify(ctx context.Context, event WebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, url := range n.URLs {
+		if err := n.deliver(ctx, url, body); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+
+	if len(failed) != 0 {
+		return fmt.Errorf("webhook: %s", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// deliver posts body to url, retrying with exponential backoff
+func (n *WebhookNotifier) deliver(ctx context.Context, url string, body []byte) error {
+	retries := n.MaxRetries
+	if retries == 0 {
+		retries = defaultWebhookRetries
+	}
+
+	backoff := n.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultWebhookBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := n.post(ctx, url, body)
+		if err == nil || attempt >= retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+// post sends a single delivery, failing on non 2xx answers
+func (n *WebhookNotifier) post(ctx context.Context, url string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	for k, v := range n.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if n.Secret != nil {
+		mac := hmac.New(sha256.New, n.Secret)
+		mac.Write(body)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s: unexpected status %d", url, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// newWebhookEvent describes the content of a resource, the payload being optional
+func newWebhookEvent(s *Snapshot, oldHash string, payload bool) WebhookEvent {
+	event := WebhookEvent{
+		Alias:     s.Alias,
+		Hash:      s.Hash,
+		OldHash:   oldHash,
+		Size:      len(s.Content),
+		Timestamp: time.Now().UTC(),
+	}
+	if payload {
+		event.Payload = string(s.Content)
+	}
+
+	return event
+}
+
+// notifyWebhooks posts the webhook event in the background once the content of a resource changed
+func (c *ResourceCacher) notifyWebhooks(res *Resource) {
+	n := c.opts.Webhooks
+	if n == nil || !res.Changed() {
+		return
+	}
+
+	event := newWebhookEvent(res.Snapshot(), res.OldHash, n.IncludePayload)
+
+	res.inflight.Add(1)
+	go func() {
+		defer res.inflight.Done()
+
+		if err := n.Notify(context.Background(), event); err != nil {
+			c.opts.Logger.Warn("webhook failed", F("alias", event.Alias), F("error", err))
+		}
+	}()
+}
@@ -0,0 +1,132 @@
+package routing_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	secret := []byte("s3cret")
+
+	var attempts int32
+	events := make(chan routing.WebhookEvent, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first delivery fails and is retried
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(body)
+		if expected := "sha256=" + hex.EncodeToString(mac.Sum(nil)); r.Header.Get(routing.WebhookSignatureHeader) != expected {
+			t.Errorf("<webhook> signature not equal. expected %v obtained %v\n", expected, r.Header.Get(routing.WebhookSignatureHeader))
+		}
+
+		var event routing.WebhookEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("<webhook> unmarshal error: %s\n", err)
+		}
+		events <- event
+	}))
+	defer hook.Close()
+
+	var version int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&version) == 0 {
+			w.Write([]byte("first"))
+		} else {
+			w.Write([]byte("second"))
+		}
+	}))
+	defer upstream.Close()
+
+	c := routing.NewResourceCacher(routing.WithWebhooks(&routing.WebhookNotifier{
+		URLs:           []string{hook.URL},
+		Secret:         secret,
+		IncludePayload: true,
+		RetryBackoff:   10 * time.Millisecond,
+	}))
+
+	res, err := c.AddResource(&routing.Resource{
+		Alias:    "news",
+		Method:   http.MethodGet,
+		URL:      upstream.URL,
+		Interval: time.Hour,
+	}, nil)
+	if err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	// Skip the event of the initial fetch
+	select {
+	case <-events:
+	case <-time.After(time.Second):
+		t.Fatalf("<webhook> initial event not delivered\n")
+	}
+	oldHash := res.Hash
+
+	atomic.StoreInt32(&version, 1)
+	if err := res.Fetch(); err != nil {
+		t.Fatalf("fetch error: %s", err)
+	}
+
+	select {
+	case event := <-events:
+		if event.Alias != "news" || event.Hash != res.Hash || event.OldHash != oldHash || event.Size != 6 || event.Payload != "second" {
+			t.Errorf("<webhook> event not equal. obtained %+v\n", event)
+		}
+
+		if event.Timestamp.IsZero() {
+			t.Errorf("<webhook> timestamp missing\n")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("<webhook> event not delivered\n")
+	}
+
+	if a := atomic.LoadInt32(&attempts); a != 3 {
+		t.Errorf("<webhook> attempts not equal. expected %v obtained %v\n", 3, a)
+	}
+
+	// Unchanged content does not notify
+	if err := res.Fetch(); err != nil {
+		t.Fatalf("fetch error: %s", err)
+	}
+
+	select {
+	case event := <-events:
+		t.Errorf("<webhook> unexpected event %+v\n", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWebhookNotifierFailure(t *testing.T) {
+	var attempts int32
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer hook.Close()
+
+	n := &routing.WebhookNotifier{URLs: []string{hook.URL}, MaxRetries: 2, RetryBackoff: time.Millisecond}
+	if err := n.Notify(context.Background(), routing.WebhookEvent{Alias: "x"}); err == nil {
+		t.Errorf("<webhook> expected error\n")
+	}
+
+	if a := atomic.LoadInt32(&attempts); a != 3 {
+		t.Errorf("<webhook> attempts not equal. expected %v obtained %v\n", 3, a)
+	}
+}
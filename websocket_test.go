@@ -0,0 +1,30 @@
+package routing
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWSClientSlow(t *testing.T) {
+	server, peer := net.Pipe()
+	defer peer.Close()
+
+	client := &wsClient{
+		conn:  &wsConn{conn: server},
+		queue: make(chan []byte, 1),
+		done:  make(chan struct{}),
+	}
+	go client.write()
+
+	// The peer never reads: the first message stalls the writer, the second one fills the queue
+	for i := 0; i < 3; i++ {
+		client.push([]byte("update"))
+	}
+
+	select {
+	case <-client.done:
+	case <-time.After(time.Second):
+		t.Errorf("<client> not dropped with a full queue\n")
+	}
+}
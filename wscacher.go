@@ -0,0 +1,356 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWSPingInterval = 30 * time.Second
+	defaultWSQueueSize    = 64
+)
+
+// WSOptions augments Resource Cacher Options
+type WSOptions struct {
+	*Options
+
+	// PingInterval keeps idle connections alive through proxies (defaults to 30s)
+	PingInterval time.Duration
+	// QueueSize bounds the messages pending for a client, slower clients being disconnected (defaults to 64)
+	QueueSize int
+
+	// OnClientConnect is called when a WebSocket client connects
+	OnClientConnect func(remoteAddr string)
+	// OnClientDisconnect is called when a WebSocket client drops
+	OnClientDisconnect func(remoteAddr string)
+}
+
+// WSResourceCacher is a WebSocket variant of Resource Cacher. Clients subscribe to resources
+// with {"action": "subscribe", "aliases": [...]} messages (or ?alias= query parameters) and
+// receive their content in the CSSE {"alias", "payload"} envelope, binary contents being
+// sent in base64 as with PayloadAuto.
+type WSResourceCacher struct {
+	*ResourceCacher
+
+	wsOpts  *WSOptions
+	clients map[*wsClient]struct{}
+	mu      sync.RWMutex
+}
+
+// wsClient is a WebSocket connection along with its subscriptions
+type wsClient struct {
+	conn *wsConn
+	r    *http.Request
+	// aliases maps the subscriptions to the hash of the last content sent
+	aliases map[string]string
+	mu      sync.Mutex
+
+	// queue holds the messages pending for the writer goroutine
+	queue     chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// wsControl is a control message sent by WebSocket clients
+type wsControl struct {
+	Action  string   `json:"action"`
+	Aliases []string `json:"aliases"`
+}
+
+// wsError reports a rejected control message to a WebSocket client
+type wsError struct {
+	Error string `json:"error"`
+	Alias string `json:"alias,omitempty"`
+}
+
+// NewWSResourceCacher returns a new WebSocket resource cacher
+func NewWSResourceCacher(opts *WSOptions) *WSResourceCacher {
+	if opts == nil {
+		opts = &WSOptions{}
+	}
+
+	if opts.PingInterval == 0 {
+		opts.PingInterval = defaultWSPingInterval
+	}
+
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultWSQueueSize
+	}
+
+	c := &WSResourceCacher{
+		ResourceCacher: NewResourceCacher(opts.Options),
+		wsOpts:         opts,
+		clients:        make(map[*wsClient]struct{}),
+	}
+
+	c.clientCount = func(alias string) int {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+
+		count := 0
+		for client := range c.clients {
+			if client.subscribed(alias) {
+				count++
+			}
+		}
+
+		return count
+	}
+
+	c.AddPublisher(c)
+
+	c.OnStopped = func() {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+
+		for client := range c.clients {
+			client.close()
+		}
+	}
+
+	return c
+}
+
+// Publish implements Publisher, changed contents being sent to the subscribed clients
+func (c *WSResourceCacher) Publish(event Event) error {
+	alias := event.Snapshot.Alias
+
+	switch event.Type {
+	case EventUpdated:
+		if !event.Changed {
+			return nil
+		}
+
+		b, err := json.Marshal(wsMessage(event.Resource, event.Snapshot.Content))
+		if err != nil {
+			return err
+		}
+
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+
+		for client := range c.clients {
+			client.pushContent(alias, event.Snapshot.Hash, b)
+		}
+	case EventRemoved:
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+
+		for client := range c.clients {
+			client.unsubscribe(alias)
+		}
+	}
+
+	return nil
+}
+
+func (c *WSResourceCacher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		c.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	client := &wsClient{
+		conn:    conn,
+		r:       r,
+		aliases: make(map[string]string),
+		queue:   make(chan []byte, c.wsOpts.QueueSize),
+		done:    make(chan struct{}),
+	}
+
+	c.mu.Lock()
+	c.clients[client] = struct{}{}
+	c.mu.Unlock()
+
+	if c.wsOpts.OnClientConnect != nil {
+		c.wsOpts.OnClientConnect(r.RemoteAddr)
+	}
+
+	go client.write()
+	go c.ping(client)
+
+	if aliases := r.URL.Query()["alias"]; len(aliases) != 0 {
+		c.subscribe(client, aliases)
+	}
+
+	// Blocks until the client is gone
+	c.read(client)
+
+	client.close()
+
+	c.mu.Lock()
+	delete(c.clients, client)
+	c.mu.Unlock()
+
+	if c.wsOpts.OnClientDisconnect != nil {
+		c.wsOpts.OnClientDisconnect(r.RemoteAddr)
+	}
+}
+
+// read handles the control messages of a client until it disconnects
+func (c *WSResourceCacher) read(client *wsClient) {
+	for {
+		op, b, err := client.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		if op != wsOpText {
+			continue
+		}
+
+		var ctrl wsControl
+		if err := json.Unmarshal(b, &ctrl); err != nil {
+			client.send(wsError{Error: "invalid message"})
+			continue
+		}
+
+		switch ctrl.Action {
+		case "subscribe":
+			c.subscribe(client, ctrl.Aliases)
+		case "unsubscribe":
+			for _, alias := range ctrl.Aliases {
+				client.unsubscribe(alias)
+			}
+		default:
+			client.send(wsError{Error: "unknown action"})
+		}
+	}
+}
+
+// ping keeps the connection of a client alive until it is closed
+func (c *WSResourceCacher) ping(client *wsClient) {
+	ticker := time.NewTicker(c.wsOpts.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-client.done:
+			return
+		case <-ticker.C:
+			if err := client.conn.writeFrame(wsOpPing, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// subscribe checks the access of a client to resources, subscribing it and sending their current content
+func (c *WSResourceCacher) subscribe(client *wsClient, aliases []string) {
+	for _, alias := range aliases {
+		res, ok := c.GetResource(alias)
+		if !ok {
+			client.send(wsError{Error: "unknown alias", Alias: alias})
+			continue
+		}
+
+		if !res.IsOriginAllowed(client.r.Header.Get("Origin")) {
+			client.send(wsError{Error: "origin not allowed", Alias: alias})
+			continue
+		}
+
+		if err := c.authenticate(client.r, res); err != nil {
+			client.send(wsError{Error: err.Error(), Alias: alias})
+			continue
+		}
+
+		if status, reason := c.accessDenied(client.r, res); status != 0 {
+			client.send(wsError{Error: reason, Alias: alias})
+			continue
+		}
+
+		client.mu.Lock()
+		client.aliases[alias] = ""
+		client.mu.Unlock()
+
+		if s := res.Snapshot(); s.Hash != "" {
+			if b, err := json.Marshal(wsMessage(res, s.Content)); err == nil {
+				client.pushContent(alias, s.Hash, b)
+			}
+		}
+	}
+}
+
+// wsMessage returns the message carrying a content of a resource, in base64 when binary
+func wsMessage(res *Resource, content []byte) sseMessage {
+	return ssePayload(&SSEOptions{PayloadEncoding: PayloadAuto}, res, content)
+}
+
+// send queues a JSON message for the client
+func (client *wsClient) send(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	client.push(b)
+
+	return nil
+}
+
+// push queues a message without blocking, a client whose queue is full being disconnected
+func (client *wsClient) push(b []byte) {
+	select {
+	case client.queue <- b:
+	case <-client.done:
+	default:
+		// Closing sends a close frame, which may wait for a stalled write
+		go client.close()
+	}
+}
+
+// write sends the queued messages until the client is closed, closing it on write errors
+func (client *wsClient) write() {
+	for {
+		select {
+		case <-client.done:
+			return
+		case b := <-client.queue:
+			if err := client.conn.WriteText(b); err != nil {
+				client.close()
+				return
+			}
+		}
+	}
+}
+
+// close stops the writer of the client and closes its connection, ending its read loop
+func (client *wsClient) close() {
+	client.closeOnce.Do(func() {
+		close(client.done)
+		client.conn.Close()
+	})
+}
+
+// subscribed tells whether the client listens to an alias
+func (client *wsClient) subscribed(alias string) bool {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	_, ok := client.aliases[alias]
+	return ok
+}
+
+// pushContent queues the content of an alias the client subscribed to, unless already sent
+func (client *wsClient) pushContent(alias, hash string, b []byte) {
+	client.mu.Lock()
+	sent, ok := client.aliases[alias]
+	if !ok || sent == hash {
+		client.mu.Unlock()
+		return
+	}
+	client.aliases[alias] = hash
+	client.mu.Unlock()
+
+	client.push(b)
+}
+
+// unsubscribe stops sending the updates of an alias to the client
+func (client *wsClient) unsubscribe(alias string) {
+	client.mu.Lock()
+	delete(client.aliases, alias)
+	client.mu.Unlock()
+}
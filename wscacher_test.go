@@ -0,0 +1,209 @@
+package routing_test
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.lsl.digital/lardwaz/routing"
+)
+
+// wsTestClient is a minimal WebSocket client for tests
+type wsTestClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dialWS(t *testing.T, rawURL string) *wsTestClient {
+	u := strings.TrimPrefix(rawURL, "http://")
+	host, path := u, "/"
+	if i := strings.Index(u, "/"); i >= 0 {
+		host, path = u[:i], u[i:]
+	}
+
+	conn, err := net.Dial("tcp", host)
+	if err != nil {
+		t.Fatalf("dial error: %s", err)
+	}
+
+	io.WriteString(conn, "GET "+path+" HTTP/1.1\r\nHost: "+host+"\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("handshake error: %s", err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("<handshake> status not equal. expected %v obtained %v\n", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+
+	// Value from the RFC 6455 example
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("<handshake> accept not equal. expected %v obtained %v\n", "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", accept)
+	}
+
+	return &wsTestClient{conn: conn, br: br}
+}
+
+func (c *wsTestClient) send(v interface{}) {
+	payload, _ := json.Marshal(v)
+	mask := []byte{1, 2, 3, 4}
+
+	frame := []byte{0x81, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	c.conn.Write(frame)
+}
+
+func (c *wsTestClient) read(t *testing.T) map[string]string {
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	for {
+		var head [2]byte
+		if _, err := io.ReadFull(c.br, head[:]); err != nil {
+			t.Fatalf("read error: %s", err)
+		}
+
+		n := int(head[1] & 0x7f)
+		if n == 126 {
+			var ext [2]byte
+			io.ReadFull(c.br, ext[:])
+			n = int(binary.BigEndian.Uint16(ext[:]))
+		}
+
+		payload := make([]byte, n)
+		io.ReadFull(c.br, payload)
+
+		if head[0]&0x0f != 0x1 {
+			continue
+		}
+
+		var msg map[string]string
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			t.Fatalf("unmarshal error: %s", err)
+		}
+		return msg
+	}
+}
+
+func TestWSResourceCacher(t *testing.T) {
+	var version int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&version) == 0 {
+			w.Write([]byte(r.URL.Path + " v0"))
+		} else {
+			w.Write([]byte(r.URL.Path + " v1"))
+		}
+	}))
+	defer upstream.Close()
+
+	c := routing.NewWSResourceCacher(nil)
+	for _, alias := range []string{"a", "b"} {
+		if _, err := c.AddResource(&routing.Resource{
+			Alias:    alias,
+			Method:   http.MethodGet,
+			URL:      upstream.URL + "/" + alias,
+			Interval: time.Hour,
+		}, nil); err != nil {
+			t.Fatalf("add resource error: %s", err)
+		}
+	}
+
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	client := dialWS(t, srv.URL+"/?alias=a")
+	defer client.conn.Close()
+
+	if msg := client.read(t); msg["alias"] != "a" || msg["payload"] != "/a v0" {
+		t.Errorf("<ws> initial message not equal. obtained %v\n", msg)
+	}
+
+	client.send(map[string]interface{}{"action": "subscribe", "aliases": []string{"b", "missing"}})
+
+	if msg := client.read(t); msg["alias"] != "b" || msg["payload"] != "/b v0" {
+		t.Errorf("<ws> subscribe message not equal. obtained %v\n", msg)
+	}
+
+	if msg := client.read(t); msg["error"] != "unknown alias" || msg["alias"] != "missing" {
+		t.Errorf("<ws> error message not equal. obtained %v\n", msg)
+	}
+
+	client.send(map[string]interface{}{"action": "unsubscribe", "aliases": []string{"a"}})
+
+	// Wait for the unsubscription to be handled
+	deadline := time.Now().Add(time.Second)
+	for c.Status()[0].Clients != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	atomic.StoreInt32(&version, 1)
+	for _, alias := range []string{"a", "b"} {
+		if err := c.ForceRefresh(alias); err != nil {
+			t.Fatalf("refresh error: %s", err)
+		}
+	}
+
+	if msg := client.read(t); msg["alias"] != "b" || msg["payload"] != "/b v1" {
+		t.Errorf("<ws> update message not equal. obtained %v\n", msg)
+	}
+}
+
+func TestWSResourceCacherBinary(t *testing.T) {
+	content := []byte{0x89, 'P', 'N', 'G', 0xff, 0x00}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(content)
+	}))
+	defer upstream.Close()
+
+	c := routing.NewWSResourceCacher(nil)
+	if _, err := c.AddResource(&routing.Resource{
+		Alias:    "logo",
+		Method:   http.MethodGet,
+		URL:      upstream.URL,
+		Interval: time.Hour,
+	}, nil); err != nil {
+		t.Fatalf("add resource error: %s", err)
+	}
+
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	client := dialWS(t, srv.URL+"/?alias=logo")
+	defer client.conn.Close()
+
+	msg := client.read(t)
+	if msg["encoding"] != "base64" || msg["content_type"] != "image/png" {
+		t.Errorf("<ws> encoding not equal. expected base64 image/png obtained %v %v\n", msg["encoding"], msg["content_type"])
+	}
+
+	if expected := base64.StdEncoding.EncodeToString(content); msg["payload"] != expected {
+		t.Errorf("<ws> payload not equal. expected %v obtained %v\n", expected, msg["payload"])
+	}
+}
+
+func TestWSResourceCacherHandshake(t *testing.T) {
+	c := routing.NewWSResourceCacher(nil)
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("<response> status not equal. expected %v obtained %v\n", http.StatusBadRequest, rec.Code)
+	}
+}